@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	co "github.com/cofunclabs/cofunc"
+	"github.com/cofunclabs/cofunc/lint"
+)
+
+func checkflowls(names []string, asJSON bool) error {
+	issues := make([]lint.Issue, 0)
+	lookup := lint.ReadManifest()
+	for _, name := range names {
+		if !co.IsFlowl(name) {
+			return errors.New("file is not a flowl: " + name)
+		}
+		found, err := checkOneFlowl(name, lookup)
+		if err != nil {
+			return fmt.Errorf("%w: check '%s'", err, name)
+		}
+		issues = append(issues, found...)
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(issues); err != nil {
+			return err
+		}
+	} else {
+		for _, issue := range issues {
+			fmt.Fprintln(os.Stdout, issue.String())
+		}
+	}
+	if n := len(issues); n != 0 {
+		return fmt.Errorf("found %d problems", n)
+	}
+	return nil
+}
+
+func checkOneFlowl(name string, lookup lint.ManifestLookup) ([]lint.Issue, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return lint.Lint(name, f, lookup)
+}
@@ -41,28 +41,52 @@ func Execute() {
 
 func initCmd() {
 	{
-		var showAll bool
+		var showAll, checkManifest bool
 
 		parseCmd := &cobra.Command{
 			Use:          "parse [path to flowl file]",
 			Short:        "Parse a flowl",
-			Example:      "cofunc parse [-a] ./example.flowl",
+			Example:      "cofunc parse [-a] [-m] ./example.flowl",
 			SilenceUsage: true,
 			Args:         cobra.ExactArgs(1),
 			RunE: func(cmd *cobra.Command, args []string) error {
-				return parseflowl(args[0], showAll)
+				return parseflowl(args[0], showAll, checkManifest)
 			},
 		}
 		parseCmd.Flags().BoolVarP(&showAll, "all", "a", false, "Show run queue and blocks, only show run queue by default")
+		parseCmd.Flags().BoolVarP(&checkManifest, "manifest", "m", false, "Load the functions to check the arguments and return values by their manifests, the functions aren't run")
 		rootCmd.AddCommand(parseCmd)
 	}
 
 	{
-		var envs []string
+		var format, out string
+		graphCmd := &cobra.Command{
+			Use:          "graph [path to flowl file]",
+			Short:        "Export the graph of a flowl as Graphviz DOT or Mermaid",
+			Example:      "cofunc graph [-f dot|mermaid] [-o flow.dot] ./example.flowl",
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return graphflowl(args[0], format, out)
+			},
+		}
+		graphCmd.Flags().StringVarP(&format, "format", "f", "dot", "The format of the graph, 'dot' or 'mermaid'")
+		graphCmd.Flags().StringVarP(&out, "output", "o", "", "Write the graph to the file instead of stdout")
+		rootCmd.AddCommand(graphCmd)
+	}
+
+	{
+		var (
+			envs        []string
+			params      []string
+			paramsFile  string
+			dryRun      bool
+			returnsFile string
+		)
 		runCmd := &cobra.Command{
 			Use:          "run [path to flowl file] or [flow name or id]",
 			Short:        "Run a flowl",
-			Example:      "cofunc run ./example.flowl",
+			Example:      "cofunc run ./example.flowl --param version=1.0",
 			SilenceUsage: true,
 			Args:         cobra.ExactArgs(1),
 			RunE: func(cmd *cobra.Command, args []string) error {
@@ -72,19 +96,34 @@ func initCmd() {
 						os.Setenv(kv[0], kv[1])
 					}
 				}
-				return runflowl(nameid.NameOrID(args[0]))
+				values, err := readParams(params, paramsFile)
+				if err != nil {
+					return err
+				}
+				if dryRun {
+					return dryrunflowl(nameid.NameOrID(args[0]), values, returnsFile)
+				}
+				return runflowl(nameid.NameOrID(args[0]), values)
 			},
 		}
 		rootCmd.AddCommand(runCmd)
 		runCmd.Flags().StringSliceVarP(&envs, "env", "e", nil, "Set environment variables, e.g. -e FOO=bar -e BAZ=qux")
+		runCmd.Flags().StringArrayVarP(&params, "param", "p", nil, "Set the params of the flow, e.g. -p version=1.0 -p count=3")
+		runCmd.Flags().StringVar(&paramsFile, "params-file", "", "Read the params from a file that has a 'name=value' per line")
+		runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the plan of the nodes that would run with their arguments, the functions aren't run")
+		runCmd.Flags().StringVar(&returnsFile, "returns", "", "Read the canned return values for --dry-run from a JSON file, e.g. {\"t\": {\"now\": \"2022-10-01\"}}")
 	}
 
 	{
-		var envs []string
+		var (
+			envs       []string
+			params     []string
+			paramsFile string
+		)
 		prunCmd := &cobra.Command{
 			Use:          "prun [path to flowl file] or [flow name or id]",
 			Short:        "Prettily run a flowl",
-			Example:      "cofunc prun ./example.flowl",
+			Example:      "cofunc prun ./example.flowl --param version=1.0",
 			SilenceUsage: true,
 			Args:         cobra.ExactArgs(1),
 			RunE: func(cmd *cobra.Command, args []string) error {
@@ -94,12 +133,33 @@ func initCmd() {
 						os.Setenv(kv[0], kv[1])
 					}
 				}
+				values, err := readParams(params, paramsFile)
+				if err != nil {
+					return err
+				}
 				fullscreen := false
-				return prunflowl(nameid.NameOrID(args[0]), fullscreen)
+				return prunflowl(nameid.NameOrID(args[0]), fullscreen, values)
 			},
 		}
 		rootCmd.AddCommand(prunCmd)
 		prunCmd.Flags().StringSliceVarP(&envs, "env", "e", nil, "Set environment variables, e.g. -e FOO=bar -e BAZ=qux")
+		prunCmd.Flags().StringArrayVarP(&params, "param", "p", nil, "Set the params of the flow, e.g. -p version=1.0 -p count=3")
+		prunCmd.Flags().StringVar(&paramsFile, "params-file", "", "Read the params from a file that has a 'name=value' per line")
+	}
+
+	{
+		var verbose bool
+		testCmd := &cobra.Command{
+			Use:          "test [test spec files or directories]",
+			Short:        "Run the tests of flowls with the functions mocked",
+			Example:      "cofunc test [-v] ./build_test.yaml ./tests",
+			SilenceUsage: true,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return testflowl(args, verbose)
+			},
+		}
+		testCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Print the logs of the functions that aren't mocked for all test cases")
+		rootCmd.AddCommand(testCmd)
 	}
 
 	{
@@ -152,4 +212,146 @@ func initCmd() {
 		}
 		rootCmd.AddCommand(stdCmd)
 	}
+	{
+		var force bool
+		installCmd := &cobra.Command{
+			Use:          "install [path to function directory or tarball]",
+			Short:        "Install a shell/js/wasm function into the function repository",
+			Example:      "cofunc install ./echo.tar.gz",
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return installFunction(args[0], force)
+			},
+		}
+		installCmd.Flags().BoolVarP(&force, "force", "f", false, "Replace the installed function that has the same version")
+		rootCmd.AddCommand(installCmd)
+	}
+
+	{
+		uninstallCmd := &cobra.Command{
+			Use:          "uninstall [driver:name[@version]]",
+			Short:        "Uninstall a function from the function repository, all versions are removed if the version is omitted",
+			Example:      "cofunc uninstall shell:echo@1.0.0",
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return uninstallFunction(args[0])
+			},
+		}
+		rootCmd.AddCommand(uninstallCmd)
+	}
+
+	{
+		functionsCmd := &cobra.Command{
+			Use:          "functions",
+			Short:        "List all functions that installed in the function repository",
+			Example:      "cofunc functions",
+			SilenceUsage: true,
+			Args:         cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return listInstalledFunctions()
+			},
+		}
+		rootCmd.AddCommand(functionsCmd)
+	}
+
+	{
+		lockCmd := &cobra.Command{
+			Use:          "lock [path to flowl file]",
+			Short:        "Pin the versions of the functions that loaded by a flowl, the lock file is stored next to the flowl",
+			Example:      "cofunc lock ./example.flowl",
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return lockFlow(args[0])
+			},
+		}
+		rootCmd.AddCommand(lockCmd)
+	}
+
+	{
+		var asJSON bool
+		checkCmd := &cobra.Command{
+			Use:          "check [path to flowl file]...",
+			Short:        "Check flowl files for the potential problems",
+			Example:      "cofunc check [--json] ./example.flowl",
+			SilenceUsage: true,
+			Args:         cobra.MinimumNArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return checkflowls(args, asJSON)
+			},
+		}
+		checkCmd.Flags().BoolVar(&asJSON, "json", false, "Output the problems in JSON")
+		rootCmd.AddCommand(checkCmd)
+	}
+
+	{
+		var write, diff bool
+		fmtCmd := &cobra.Command{
+			Use:          "fmt [path to flowl file]...",
+			Short:        "Format flowl files in the canonical style",
+			Example:      "cofunc fmt [-w] [-d] ./example.flowl",
+			SilenceUsage: true,
+			Args:         cobra.MinimumNArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return fmtflowls(args, write, diff)
+			},
+		}
+		fmtCmd.Flags().BoolVarP(&write, "write", "w", false, "Write the result to the source file instead of stdout")
+		fmtCmd.Flags().BoolVarP(&diff, "diff", "d", false, "Display the diff instead of the formatted source")
+		rootCmd.AddCommand(fmtCmd)
+	}
+
+	{
+		secretCmd := &cobra.Command{
+			Use:   "secret",
+			Short: "Manage the secrets that can be referenced by '$(secret.NAME)', they are encrypted at rest",
+		}
+		secretCmd.AddCommand(&cobra.Command{
+			Use:          "set [name] [value]",
+			Short:        "Add or update a secret, the value is read from stdin if it's omitted",
+			Example:      "cofunc secret set GITHUB_TOKEN",
+			SilenceUsage: true,
+			Args:         cobra.RangeArgs(1, 2),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return setSecret(args[0], args[1:])
+			},
+		})
+		secretCmd.AddCommand(&cobra.Command{
+			Use:          "rm [name]",
+			Short:        "Remove a secret",
+			Example:      "cofunc secret rm GITHUB_TOKEN",
+			SilenceUsage: true,
+			Args:         cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return deleteSecret(args[0])
+			},
+		})
+		secretCmd.AddCommand(&cobra.Command{
+			Use:          "list",
+			Short:        "List the names of all secrets",
+			Example:      "cofunc secret list",
+			SilenceUsage: true,
+			Args:         cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return listSecrets()
+			},
+		})
+		rootCmd.AddCommand(secretCmd)
+	}
+
+	{
+		lspCmd := &cobra.Command{
+			Use:          "lsp",
+			Short:        "Start the language server of flowl, it communicates over stdio",
+			Example:      "cofunc lsp",
+			SilenceUsage: true,
+			Args:         cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return serveLSP()
+			},
+		}
+		rootCmd.AddCommand(lspCmd)
+	}
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cofunclabs/cofunc/functiondriver/go/spec"
+	"github.com/cofunclabs/cofunc/pkg/nameid"
+	"github.com/cofunclabs/cofunc/runtime"
+	"github.com/cofunclabs/cofunc/secret"
+	"github.com/cofunclabs/cofunc/service"
+)
+
+// dryrunflowl walks the flow without running the functions, then prints the plan of the nodes that would run
+// with their resolved arguments. The 'returnsFile' is a JSON file of the canned return values, e.g.
+// '{"t": {"now": "2022-10-01"}}', the keys are the names of the return variables.
+func dryrunflowl(nameorid nameid.NameOrID, params map[string]string, returnsFile string) error {
+	returns, err := readReturns(returnsFile)
+	if err != nil {
+		return err
+	}
+
+	svc := service.New()
+	defer svc.Shutdown(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fid, fp, err := addflowl(ctx, svc, nameorid)
+	if err != nil {
+		return err
+	}
+	plan, err := svc.DryRunFlow(ctx, fid, params, returns)
+	if plan != nil {
+		printPlan(plan, fp)
+	}
+	return err
+}
+
+func readReturns(file string) (map[string]map[string]string, error) {
+	returns := make(map[string]map[string]string)
+	if file == "" {
+		return returns, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]spec.Returns
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	for name, rets := range values {
+		flat, err := rets.Flatten()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		returns[name] = flat
+	}
+	return returns, nil
+}
+
+func printPlan(plan *runtime.Plan, name string) {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "dry-run plan of %s:\n", name)
+	for _, step := range plan.Steps() {
+		if step.Skipped {
+			fmt.Fprintf(&buf, "  Stage %d: %d %s (skipped, condition is false)\n", step.Step, step.Seq, step.Node)
+			continue
+		}
+		fmt.Fprintf(&buf, "  Stage %d: %d %s\n", step.Step, step.Seq, step.Node)
+		printKVs(&buf, "args", step.Args)
+		printKVs(&buf, "returns", step.Returns)
+	}
+	// The resolved arguments may contain the secrets
+	os.Stdout.Write(secret.Redact([]byte(buf.String())))
+}
+
+func printKVs(buf *strings.Builder, title string, kvs map[string]string) {
+	if len(kvs) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(kvs))
+	for k := range kvs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(buf, "    %s:\n", title)
+	for _, k := range keys {
+		fmt.Fprintf(buf, "      %s: %s\n", k, kvs[k])
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	co "github.com/cofunclabs/cofunc"
+	"github.com/cofunclabs/cofunc/parser"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+func fmtflowls(names []string, write, diff bool) error {
+	for _, name := range names {
+		if !co.IsFlowl(name) {
+			return errors.New("file is not a flowl: " + name)
+		}
+		if err := fmtOneFlowl(name, write, diff); err != nil {
+			return fmt.Errorf("%w: format '%s'", err, name)
+		}
+	}
+	return nil
+}
+
+func fmtOneFlowl(name string, write, diff bool) error {
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	out, err := parser.Format(src, parser.WithFile(name))
+	if err != nil {
+		return err
+	}
+	if diff {
+		if bytes.Equal(src, out) {
+			return nil
+		}
+		text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(src)),
+			B:        difflib.SplitLines(string(out)),
+			FromFile: name + ".orig",
+			ToFile:   name,
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stdout, text)
+	}
+	if write {
+		if bytes.Equal(src, out) {
+			return nil
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(name, out, info.Mode().Perm())
+	}
+	if !diff {
+		_, err = os.Stdout.Write(out)
+	}
+	return err
+}
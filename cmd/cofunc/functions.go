@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/cofunclabs/cofunc/service"
+)
+
+func installFunction(src string, force bool) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := service.New()
+	fi, err := svc.InstallFunction(ctx, src, force)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "installed %s:%s@%s (%s)\n", fi.Driver, fi.Name, fi.Version, fi.Checksum)
+	return nil
+}
+
+func uninstallFunction(target string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := service.New()
+	removed, err := svc.UninstallFunction(ctx, target)
+	if err != nil {
+		return err
+	}
+	for _, fi := range removed {
+		fmt.Fprintf(os.Stdout, "uninstalled %s:%s@%s\n", fi.Driver, fi.Name, fi.Version)
+	}
+	return nil
+}
+
+func listInstalledFunctions() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := service.New()
+	all, err := svc.ListInstalledFunctions(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Driver+":"+all[i].Name < all[j].Driver+":"+all[j].Name
+	})
+
+	versionStyle := lipgloss.NewStyle().Width(12)
+	// here is title
+	fmt.Fprintln(os.Stdout, "\n"+
+		colorGrey.Render(iconSpace.String()+
+			funcNameStyle.Render("FUNCTION NAME")+
+			versionStyle.Render("VERSION")+
+			"CHECKSUM"))
+
+	for _, f := range all {
+		icon, checksum := iconCircleOk, f.Checksum
+		if f.Modified {
+			icon, checksum = iconCircleFailed, colorRed.Render(f.Checksum+" (modified)")
+		}
+		s := icon.String() +
+			funcNameStyle.Foreground(lipgloss.Color("222")).Render(f.Driver+":"+f.Name) +
+			versionStyle.Render(f.Version) +
+			checksum
+		fmt.Fprintln(os.Stdout, s)
+	}
+	fmt.Fprintf(os.Stdout, "\n")
+	return nil
+}
+
+func lockFlow(path string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := service.New()
+	versions, err := svc.LockFlow(ctx, path)
+	if err != nil {
+		return err
+	}
+	var locations []string
+	for k := range versions {
+		locations = append(locations, k)
+	}
+	sort.Strings(locations)
+	for _, k := range locations {
+		fmt.Fprintf(os.Stdout, "locked %s@%s\n", k, versions[k])
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	co "github.com/cofunclabs/cofunc"
+	"github.com/cofunclabs/cofunc/runtime/actuator"
+)
+
+// graphflowl prints the graph of the flow in the format 'dot' or 'mermaid', it's written to the file if 'out'
+// isn't empty.
+func graphflowl(name, format, out string) error {
+	if !co.IsFlowl(name) {
+		return errors.New("file is not a flowl: " + name)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rq, _, err := actuator.New(f)
+	if err != nil {
+		return flowlError(name, err)
+	}
+	g := rq.Graph()
+	flow := co.FlowlPath2Name(name)
+
+	var text string
+	switch format {
+	case "dot":
+		text = g.DOT(flow)
+	case "mermaid":
+		text = g.Mermaid(flow)
+	default:
+		return fmt.Errorf("unknown graph format '%s', expect 'dot' or 'mermaid'", format)
+	}
+	if out != "" {
+		return os.WriteFile(out, []byte(text), 0644)
+	}
+	fmt.Print(text)
+	return nil
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/cofunclabs/cofunc/lint"
+	"github.com/cofunclabs/cofunc/lsp"
+)
+
+func serveLSP() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := lsp.New(lint.ReadManifest())
+	return server.Serve(ctx, os.Stdin, os.Stdout)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readParams reads the values of params from the params file and the '--param' options, the file has a
+// 'name=value' per line, the empty lines and the lines starting with '#' are ignored. The '--param' options
+// override the values in the file.
+func readParams(pairs []string, file string) (map[string]string, error) {
+	params := make(map[string]string)
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for n := 1; scanner.Scan(); n++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			name, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: param '%s' isn't 'name=value'", file, n, line)
+			}
+			params[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("param '%s' isn't 'name=value'", pair)
+		}
+		params[name] = value
+	}
+	return params, nil
+}
@@ -5,15 +5,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 
 	co "github.com/cofunclabs/cofunc"
 	"github.com/cofunclabs/cofunc/parser"
+	"github.com/cofunclabs/cofunc/pkg/nameid"
+	"github.com/cofunclabs/cofunc/repository"
+	"github.com/cofunclabs/cofunc/runtime"
 	"github.com/cofunclabs/cofunc/runtime/actuator"
 )
 
-func parseflowl(name string, all bool) error {
+func parseflowl(name string, all, checkManifest bool) error {
 	if !co.IsFlowl(name) {
 		return errors.New("file is not a flowl: " + name)
 	}
@@ -27,13 +31,76 @@ func parseflowl(name string, all bool) error {
 	}()
 	rq, ast, err := actuator.New(f)
 	if err != nil {
-		return err
+		return flowlError(name, err)
 	}
 	if all {
 		printAST(ast, name)
 	}
 	printRunQ(rq, name)
-	return nil
+	printParams(ast, name)
+	if !checkManifest {
+		return nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	// The required params are given the placeholders, so the flow can be initialized to check
+	placeholders := make(map[string]string)
+	for _, p := range ast.Params() {
+		if p.Required {
+			placeholders[p.Name] = paramPlaceholder(p.Type)
+		}
+	}
+	return checkflowl(f, name, placeholders)
+}
+
+// flowlError formats the errors of parsing a flowl file with the positions and the snippets of source
+func flowlError(name string, err error) error {
+	var list parser.ErrorList
+	if errors.As(err, &list) {
+		return errors.New(list.Format(name))
+	}
+	return err
+}
+
+// checkflowl initializes the flow to load the functions, so the arguments and the references to return values
+// can be checked by the manifests of functions, the flow isn't executed. Loading the functions has side effects,
+// e.g. downloading or compiling them, so it's only done by 'parse -m'. The functions are loaded by the versions
+// pinned in the lockfile of the flow, the secrets and the required environment variables aren't resolved.
+func checkflowl(rd io.Reader, name string, params map[string]string) error {
+	var opts []actuator.Option
+	lock, err := repository.ReadFlowLock(name)
+	if err != nil {
+		return err
+	}
+	if lock != nil {
+		if err := lock.Verify(); err != nil {
+			return err
+		}
+		opts = append(opts, actuator.WithPinnedVersions(lock.Versions()))
+	}
+
+	ctx := context.Background()
+	rt := runtime.New()
+	defer rt.Shutdown(ctx)
+
+	id := nameid.New(co.FlowlPath2Name(name))
+	if err := rt.ParseFlow(ctx, id, rd, opts...); err != nil {
+		return err
+	}
+	discard := runtime.WithCreateLogwriter(func(string, string) (io.Writer, error) {
+		return io.Discard, nil
+	})
+	if err := rt.InitFlow(ctx, id, discard, runtime.WithParams(params), runtime.WithPath(name), runtime.WithCheckOnly()); err != nil {
+		return err
+	}
+	return rt.FetchFlow(ctx, id, func(fb *runtime.FlowBody) error {
+		for _, w := range fb.Warnings() {
+			fmt.Fprintf(os.Stderr, "warning: %s: %s\n", name, w)
+		}
+		return nil
+	})
 }
 
 func printAST(ast *parser.AST, name string) {
@@ -44,6 +111,38 @@ func printAST(ast *parser.AST, name string) {
 	})
 }
 
+func printParams(ast *parser.AST, name string) {
+	params := ast.Params()
+	if len(params) == 0 {
+		return
+	}
+	fmt.Printf("params in %s:\n", name)
+	for _, p := range params {
+		s := p.Name + " " + p.Type
+		if p.Required {
+			s += " (required)"
+		} else if p.Type == parser.ParamString {
+			s += " = " + strconv.Quote(p.Default)
+		} else {
+			s += " = " + p.Default
+		}
+		if p.Desc != "" {
+			s += "  // " + p.Desc
+		}
+		fmt.Printf("  %s\n", s)
+	}
+}
+
+func paramPlaceholder(typ string) string {
+	switch typ {
+	case parser.ParamInt, parser.ParamFloat:
+		return "0"
+	case parser.ParamBool:
+		return "false"
+	}
+	return ""
+}
+
 func printRunQ(rq *actuator.RunQueue, name string) {
 	fmt.Printf("run queue in %s:\n", name)
 	i := 0
@@ -12,8 +12,9 @@ import (
 	"github.com/cofunclabs/cofunc/service/exported"
 )
 
-func prunflowl(nameorid nameid.NameOrID, fullscreen bool) error {
+func prunflowl(nameorid nameid.NameOrID, fullscreen bool, params map[string]string) error {
 	svc := service.New()
+	defer svc.Shutdown(context.Background())
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -37,15 +38,10 @@ func prunflowl(nameorid nameid.NameOrID, fullscreen bool) error {
 	} else {
 		fid = nameid.New(co.FlowlPath2Name(fp))
 	}
-	f, err := os.Open(fp)
-	if err != nil {
-		return err
-	}
-
-	if err := svc.AddFlow(ctx, fid, f); err != nil {
-		return err
+	if err := svc.AddFlow(ctx, fid, fp); err != nil {
+		return flowlError(fp, err)
 	}
-	if _, err := svc.ReadyFlow(ctx, fid, false); err != nil {
+	if _, err := svc.ReadyFlow(ctx, fid, false, params); err != nil {
 		return err
 	}
 
@@ -84,6 +80,7 @@ func prunflowl(nameorid nameid.NameOrID, fullscreen bool) error {
 	wg.Wait()
 
 	if lasterr != nil {
+		svc.Shutdown(context.Background())
 		os.Exit(-1)
 	}
 
@@ -117,10 +117,54 @@ func maxNameWidth(nodes []exported.NodeRunningInsight) int {
 		if w > max {
 			max = w + 2
 		}
+		// The nodes of the nested flow are indented
+		if w := maxNameWidth(n.Nodes) + 2; len(n.Nodes) != 0 && w > max {
+			max = w
+		}
 	}
 	return max
 }
 
+// writeNode writes a node line into the builder, the nodes of the nested flow are indented under the node.
+func (m runningModel) writeNode(builder *strings.Builder, n exported.NodeRunningInsight, nameMaxWidth int, indent string) {
+	name := indent + n.Name + " ➜ " + n.Function
+	if n.Status == "RUNNING" {
+		builder.WriteString(iconStyle.Render(m.spinner.View()) +
+			stepStyle.Render(strconv.Itoa(n.Step)) +
+			seqStyle.Render(strconv.Itoa(n.Seq)) +
+			runningNameStyle.Width(nameMaxWidth).Render(name) +
+			driverStyle.Render(n.Driver) +
+			runsStyle.Render(fmt.Sprintf("(%d)", n.Runs)) +
+			fmt.Sprintf("%dms", n.Duration) +
+			"\n")
+	} else if n.Status == "STOPPED" {
+		mark := iconOK
+		if n.LastError != nil {
+			mark = iconFailed
+		}
+		builder.WriteString(mark.String() +
+			stepStyle.Render(strconv.Itoa(n.Step)) +
+			seqStyle.Render(strconv.Itoa(n.Seq)) +
+			nameStyle.Width(nameMaxWidth).Render(name) +
+			driverStyle.Render(n.Driver) +
+			runsStyle.Render(fmt.Sprintf("(%d)", n.Runs)) +
+			fmt.Sprintf("%dms", n.Duration) +
+			"\n")
+	} else {
+		builder.WriteString(iconSpace.String() +
+			stepStyle.Render(strconv.Itoa(n.Step)) +
+			seqStyle.Render(strconv.Itoa(n.Seq)) +
+			nameStyle.Width(nameMaxWidth).Render(name) +
+			driverStyle.Render(n.Driver) +
+			runsStyle.Render(fmt.Sprintf("(%d)", n.Runs)) +
+			fmt.Sprintf("%dms", n.Duration) +
+			"\n")
+	}
+	for _, child := range n.Nodes {
+		m.writeNode(builder, child, nameMaxWidth, indent+"  ")
+	}
+}
+
 func (m runningModel) View() string {
 	var builder strings.Builder
 	builder.WriteString(fmt.Sprintf("  FLOW NAME: %s\n", m.fi.Name))
@@ -138,38 +182,7 @@ func (m runningModel) View() string {
 	builder.WriteString("\n")
 
 	for _, n := range m.fi.Nodes {
-		if n.Status == "RUNNING" {
-			builder.WriteString(iconStyle.Render(m.spinner.View()) +
-				stepStyle.Render(strconv.Itoa(n.Step)) +
-				seqStyle.Render(strconv.Itoa(n.Seq)) +
-				runningNameStyle.Width(nameMaxWidth).Render(n.Name+" ➜ "+n.Function) +
-				driverStyle.Render(n.Driver) +
-				runsStyle.Render(fmt.Sprintf("(%d)", n.Runs)) +
-				fmt.Sprintf("%dms", n.Duration) +
-				"\n")
-		} else if n.Status == "STOPPED" {
-			mark := iconOK
-			if n.LastError != nil {
-				mark = iconFailed
-			}
-			builder.WriteString(mark.String() +
-				stepStyle.Render(strconv.Itoa(n.Step)) +
-				seqStyle.Render(strconv.Itoa(n.Seq)) +
-				nameStyle.Width(nameMaxWidth).Render(n.Name+" ➜ "+n.Function) +
-				driverStyle.Render(n.Driver) +
-				runsStyle.Render(fmt.Sprintf("(%d)", n.Runs)) +
-				fmt.Sprintf("%dms", n.Duration) +
-				"\n")
-		} else {
-			builder.WriteString(iconSpace.String() +
-				stepStyle.Render(strconv.Itoa(n.Step)) +
-				seqStyle.Render(strconv.Itoa(n.Seq)) +
-				nameStyle.Width(nameMaxWidth).Render(n.Name+" ➜ "+n.Function) +
-				driverStyle.Render(n.Driver) +
-				runsStyle.Render(fmt.Sprintf("(%d)", n.Runs)) +
-				fmt.Sprintf("%dms", n.Duration) +
-				"\n")
-		}
+		m.writeNode(&builder, n, nameMaxWidth, "")
 	}
 
 	if m.done {
@@ -2,18 +2,33 @@ package main
 
 import (
 	"context"
-	"os"
 
 	co "github.com/cofunclabs/cofunc"
 	"github.com/cofunclabs/cofunc/pkg/nameid"
 	"github.com/cofunclabs/cofunc/service"
 )
 
-func runflowl(nameorid nameid.NameOrID) error {
+func runflowl(nameorid nameid.NameOrID, params map[string]string) error {
 	svc := service.New()
+	defer svc.Shutdown(context.Background())
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	fid, _, err := addflowl(ctx, svc, nameorid)
+	if err != nil {
+		return err
+	}
+	if _, err := svc.ReadyFlow(ctx, fid, true, params); err != nil {
+		return err
+	}
+	if err := svc.StartFlowOrEventFlow(ctx, fid); err != nil {
+		return err
+	}
+	return nil
+}
+
+// addflowl adds the flow into the service, it returns the id of the flow and the path of the flowl file.
+func addflowl(ctx context.Context, svc *service.SVC, nameorid nameid.NameOrID) (nameid.ID, string, error) {
 	var fid nameid.ID
 
 	// If the argument 'nameorid' not contains the suffix ".flowl", We will treat it as a flow name or id, so we will lookup the flowl source path through
@@ -23,30 +38,19 @@ func runflowl(nameorid nameid.NameOrID) error {
 	if !co.IsFlowl(fp) {
 		id, err := svc.LookupID(ctx, nameorid)
 		if err != nil {
-			return err
+			return nil, "", err
 		}
 		meta, err := svc.GetAvailableMeta(ctx, id)
 		if err != nil {
-			return err
+			return nil, "", err
 		}
 		fp = meta.Source
 		fid = id
 	} else {
 		fid = nameid.New(co.FlowlPath2Name(fp))
 	}
-	f, err := os.Open(fp)
-	if err != nil {
-		return err
-	}
-
-	if err := svc.AddFlow(ctx, fid, f); err != nil {
-		return err
+	if err := svc.AddFlow(ctx, fid, fp); err != nil {
+		return nil, "", flowlError(fp, err)
 	}
-	if _, err := svc.ReadyFlow(ctx, fid, true); err != nil {
-		return err
-	}
-	if err := svc.StartFlowOrEventFlow(ctx, fid); err != nil {
-		return err
-	}
-	return nil
+	return fid, fp, nil
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cofunclabs/cofunc/service"
+)
+
+// setSecret sets the secret, the value is read from the stdin if it isn't given, so it isn't saved in the
+// history of shell.
+func setSecret(name string, args []string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var value string
+	if len(args) != 0 {
+		value = args[0]
+	} else {
+		fmt.Fprintf(os.Stderr, "value of secret '%s': ", name)
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return errors.New("no value of secret is read from stdin")
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+
+	svc := service.New()
+	if err := svc.SetSecret(ctx, name, value); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "secret '%s' is set\n", name)
+	return nil
+}
+
+func deleteSecret(name string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := service.New()
+	if err := svc.DeleteSecret(ctx, name); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "secret '%s' is removed\n", name)
+	return nil
+}
+
+func listSecrets() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := service.New()
+	names, err := svc.ListSecrets(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Fprintln(os.Stdout, name)
+	}
+	return nil
+}
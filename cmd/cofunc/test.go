@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/cofunclabs/cofunc/flowtest"
+)
+
+// testflowl runs the test specs in the paths, the directories are searched for the '*_test.yaml' files. The logs
+// of the functions that aren't mocked are printed for the failed cases, or for all cases if 'verbose' is true.
+func testflowl(paths []string, verbose bool) error {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := flowtest.Find(paths...)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("no test spec found, the test spec files are named '*" + flowtest.SpecSuffix + "'")
+	}
+
+	passed, failed := 0, 0
+	for _, file := range files {
+		s, err := flowtest.Load(file)
+		if err != nil {
+			return err
+		}
+		for _, res := range flowtest.RunSpec(context.Background(), s) {
+			status := "PASS"
+			if res.Passed() {
+				passed++
+			} else {
+				status = "FAIL"
+				failed++
+			}
+			fmt.Printf("--- %s: %s/%s (%.2fs)\n", status, filepath.Base(file), res.Case, res.Duration.Seconds())
+			for _, failure := range res.Failures {
+				fmt.Printf("    %s\n", failure)
+			}
+			if (verbose || !res.Passed()) && res.Log != "" {
+				fmt.Printf("    log:\n        %s\n", strings.ReplaceAll(strings.TrimSpace(res.Log), "\n", "\n        "))
+			}
+		}
+	}
+	fmt.Printf("%d passed, %d failed\n", passed, failed)
+	if failed != 0 {
+		return fmt.Errorf("%d test cases failed", failed)
+	}
+	return nil
+}
@@ -59,6 +59,30 @@ func ShellDir() string {
 	return prettyDirPath(v)
 }
 
+// JsDir store all functions that's based on javascript driver.
+func JsDir() string {
+	v := filepath.Join(HomeDir(), "js")
+	return prettyDirPath(v)
+}
+
+// WasmDir store all functions that's based on wasm driver.
+func WasmDir() string {
+	v := filepath.Join(HomeDir(), "wasm")
+	return prettyDirPath(v)
+}
+
+// HttpDir store the configuration of the remote functions that's based on http driver.
+func HttpDir() string {
+	v := filepath.Join(HomeDir(), "http")
+	return prettyDirPath(v)
+}
+
+// SecretDir store the key and the encrypted secrets.
+func SecretDir() string {
+	v := filepath.Join(HomeDir(), "secrets")
+	return prettyDirPath(v)
+}
+
 func prettyDirPath(p string) string {
 	return filepath.Clean(p) + "/"
 }
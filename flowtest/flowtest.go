@@ -0,0 +1,308 @@
+package flowtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	co "github.com/cofunclabs/cofunc"
+	"github.com/cofunclabs/cofunc/pkg/nameid"
+	"github.com/cofunclabs/cofunc/repository"
+	"github.com/cofunclabs/cofunc/runtime"
+	"github.com/cofunclabs/cofunc/runtime/actuator"
+)
+
+// Result is the result of a test case
+type Result struct {
+	Case string
+	// Failures are the expectations that aren't met, the case passed if it's empty
+	Failures []string
+	// Log is the output of the functions that aren't mocked
+	Log      string
+	Duration time.Duration
+}
+
+// Passed returns true if all expectations of the case are met.
+func (r *Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+func (r *Result) fail(format string, args ...interface{}) {
+	r.Failures = append(r.Failures, fmt.Sprintf(format, args...))
+}
+
+// RunSpec runs the cases of the spec one by one.
+func RunSpec(ctx context.Context, s *Spec) []Result {
+	var results []Result
+	for i := range s.Cases {
+		results = append(results, RunCase(ctx, s, &s.Cases[i]))
+	}
+	return results
+}
+
+// RunCase runs the flow once with the mocks of the case, then checks the expectations. The environment variables
+// of the process are changed during the case, so the cases can't run in parallel.
+func RunCase(ctx context.Context, s *Spec, c *Case) (res Result) {
+	res.Case = c.Name
+	begin := time.Now()
+	defer func() {
+		res.Duration = time.Since(begin)
+	}()
+	defer setenv(c.Env)()
+
+	path := s.FlowPath()
+	source, err := os.ReadFile(path)
+	if err != nil {
+		res.fail("%s", err)
+		return
+	}
+	var opts []actuator.Option
+	lock, err := repository.ReadFlowLock(path)
+	if err != nil {
+		res.fail("%s", err)
+		return
+	}
+	if lock != nil {
+		opts = append(opts, actuator.WithPinnedVersions(lock.Versions()))
+	}
+
+	rt := runtime.New()
+	defer rt.Shutdown(context.Background())
+	id := nameid.New(co.FlowlPath2Name(path))
+	if err := rt.ParseFlow(ctx, id, bytes.NewReader(source), opts...); err != nil {
+		res.fail("parse %s: %s", path, err)
+		return
+	}
+
+	// The nodes referenced by the case must be in the flow, a misspelled mock would run the function really
+	known := make(map[string]bool)
+	rt.FetchFlow(ctx, id, func(fb *runtime.FlowBody) error {
+		for _, name := range fb.NodeNames() {
+			known[name] = true
+		}
+		return nil
+	})
+	for _, name := range c.nodes() {
+		if !known[name] {
+			res.fail("node '%s' isn't in the flow", name)
+		}
+	}
+	if !res.Passed() {
+		return
+	}
+
+	var (
+		log  lockedBuffer
+		plan = &runtime.Plan{}
+	)
+	createLogwriter := func(fileid, desc string) (io.Writer, error) {
+		return &log, nil
+	}
+	err = rt.InitFlow(ctx, id,
+		runtime.WithParams(c.Params),
+		runtime.WithPath(path),
+		runtime.WithCreateLogwriter(createLogwriter),
+		runtime.WithStub(plan, c.stub()),
+	)
+	vars := make(map[string]string)
+	if err == nil {
+		err = rt.ExecFlow(ctx, id)
+		rt.FetchFlow(ctx, id, func(fb *runtime.FlowBody) error {
+			for name := range c.Expect.Vars {
+				vars[name] = fb.GetVarValue(name)
+			}
+			return nil
+		})
+	}
+	res.Log = log.String()
+	c.check(&res, err, plan.Steps(), vars)
+	return
+}
+
+// Run runs the test specs as the subtests of 't', the paths are the spec files or the directories that contain
+// the '*_test.yaml' files, e.g.
+//
+//	func TestFlows(t *testing.T) {
+//		flowtest.Run(t, "testdata")
+//	}
+func Run(t *testing.T, paths ...string) {
+	t.Helper()
+	files, err := Find(paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no test spec found in %s", quote(paths))
+	}
+	for _, file := range files {
+		s, err := Load(file)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			for i := range s.Cases {
+				c := &s.Cases[i]
+				t.Run(c.Name, func(t *testing.T) {
+					res := RunCase(context.Background(), s, c)
+					for _, failure := range res.Failures {
+						t.Error(failure)
+					}
+					if !res.Passed() && res.Log != "" {
+						t.Log(res.Log)
+					}
+				})
+			}
+		})
+	}
+}
+
+// stub returns the mocked results for the nodes in 'Mocks', the other nodes run their functions really.
+func (c *Case) stub() actuator.Stub {
+	return func(ctx context.Context, n *actuator.TaskNode, args map[string]string) (map[string]string, error) {
+		m, ok := c.Mocks[n.Name()]
+		if !ok {
+			return n.Driver().Run(ctx, args)
+		}
+		if m.Delay > 0 {
+			select {
+			case <-time.After(m.Delay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if m.Error != "" {
+			return nil, errors.New(m.Error)
+		}
+		return m.returns()
+	}
+}
+
+func (c *Case) check(res *Result, err error, steps []runtime.PlanStep, vars map[string]string) {
+	e := c.Expect
+	status := StatusSucceeded
+	if err != nil {
+		status = StatusFailed
+	}
+	// The flow is expected to succeed by default, unless the error is expected
+	expected := e.Status
+	if expected == "" {
+		expected = StatusSucceeded
+		if e.Error != "" {
+			expected = StatusFailed
+		}
+	}
+	if status != expected {
+		if err != nil {
+			res.fail("status is '%s', expect '%s': %s", status, expected, err)
+		} else {
+			res.fail("status is '%s', expect '%s'", status, expected)
+		}
+	}
+	if e.Error != "" && err != nil && !strings.Contains(err.Error(), e.Error) {
+		res.fail("error '%s' doesn't contain '%s'", err, e.Error)
+	}
+
+	var (
+		ran  []string
+		args = make(map[string][]map[string]string)
+	)
+	for _, step := range steps {
+		if step.Skipped {
+			continue
+		}
+		ran = append(ran, step.Name)
+		args[step.Name] = append(args[step.Name], step.Args)
+	}
+	if e.Ran != nil && strings.Join(ran, "\n") != strings.Join(e.Ran, "\n") {
+		res.fail("ran %s, expect %s", quote(ran), quote(e.Ran))
+	}
+	for _, name := range e.NotRan {
+		if len(args[name]) != 0 {
+			res.fail("node '%s' ran %d times, expect not to run", name, len(args[name]))
+		}
+	}
+
+	for _, name := range sortedKeys(e.Args) {
+		runs := args[name]
+		for i, want := range e.Args[name] {
+			if i >= len(runs) {
+				res.fail("node '%s' ran %d times, expect the arguments of %d runs", name, len(runs), len(e.Args[name]))
+				break
+			}
+			for _, k := range sortedKeys(want) {
+				got, ok := runs[i][k]
+				if !ok {
+					res.fail("node '%s' run %d: argument '%s' is missing", name, i+1, k)
+				} else if got != want[k] {
+					res.fail("node '%s' run %d: argument '%s' is '%s', expect '%s'", name, i+1, k, got, want[k])
+				}
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(e.Vars) {
+		if got := vars[name]; got != e.Vars[name] {
+			res.fail("variable '%s' is '%s', expect '%s'", name, got, e.Vars[name])
+		}
+	}
+}
+
+// setenv sets the environment variables, the returned function restores them.
+func setenv(env map[string]string) func() {
+	type saved struct {
+		value string
+		ok    bool
+	}
+	olds := make(map[string]saved, len(env))
+	for k, v := range env {
+		old, ok := os.LookupEnv(k)
+		olds[k] = saved{old, ok}
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k, old := range olds {
+			if old.ok {
+				os.Setenv(k, old.value)
+			} else {
+				os.Unsetenv(k)
+			}
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lockedBuffer is written by the functions that run in parallel
+type lockedBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,68 @@
+package flowtest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	Run(t, "testdata")
+}
+
+func TestRunCase(t *testing.T) {
+	s, err := Load(filepath.Join("testdata", "build_test.yaml"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	c := s.Cases[0]
+	c.Mocks = map[string]Mock{
+		"go_build": {Returns: map[string]interface{}{"outcome": "bin/app"}},
+	}
+	res := RunCase(context.Background(), s, &c)
+	assert.False(t, res.Passed())
+	assert.Equal(t, []string{
+		"node 'print' run 1: argument '_' is 'darwin 1 ', expect 'darwin 1 bin/app'",
+		"node 'print' run 2: argument '_' is 'darwin 2 ', expect 'darwin 2 bin/app'",
+	}, res.Failures)
+	assert.Contains(t, res.Log, "darwin 1")
+	// the environment variables are restored
+	assert.Equal(t, "", os.Getenv("BUILD"))
+
+	c = s.Cases[1]
+	c.Mocks = map[string]Mock{"go_buidl": {}}
+	res = RunCase(context.Background(), s, &c)
+	assert.Equal(t, []string{"node 'go_buidl' isn't in the flow"}, res.Failures)
+
+	c = s.Cases[2]
+	c.Expect = Expect{}
+	res = RunCase(context.Background(), s, &c)
+	if assert.Len(t, res.Failures, 1) {
+		assert.Contains(t, res.Failures[0], "status is 'failed', expect 'succeeded'")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	testcases := map[string]string{
+		"flow: a.flowl\n":                                                     "no cases",
+		"cases:\n  - name: a\n":                                               "'flow' is empty",
+		"flow: a.flowl\ncases:\n  - params: {}\n":                             "case 1: 'name' is empty",
+		"flow: a.flowl\ncases:\n  - name: a\n  - name: a\n":                   "case 'a': duplicated",
+		"flow: a.flowl\ncases:\n  - name: a\n    expect:\n      status: ok\n": "status 'ok'",
+	}
+	for data, msg := range testcases {
+		path := filepath.Join(dir, "a"+SpecSuffix)
+		assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+		_, err := Load(path)
+		assert.ErrorIs(t, err, ErrSpecIllegal)
+		assert.ErrorContains(t, err, msg)
+	}
+
+	files, err := Find("testdata")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join("testdata", "build_test.yaml")}, files)
+}
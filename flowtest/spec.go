@@ -0,0 +1,221 @@
+// Package flowtest tests flows without the side effects of functions. A test spec is a YAML file named
+// '*_test.yaml', its cases run the flow with the selected functions mocked by the fixed return values, errors
+// and delays, then assert on which nodes ran, with what arguments, the final values of variables and the flow
+// status. The specs are run by 'cofunc test' or by 'go test' through 'flowtest.Run'.
+//
+// An example of the spec:
+//
+//	flow: build.flowl
+//	cases:
+//	  - name: build on linux
+//	    params:
+//	      target: linux
+//	    env:
+//	      BUILD: "true"
+//	    mocks:
+//	      go_build:
+//	        returns:
+//	          outcome: bin/app
+//	        delay: 10ms
+//	    expect:
+//	      status: succeeded
+//	      ran: [go_build, print]
+//	      args:
+//	        print:
+//	          - _: bin/app
+//	      vars:
+//	        bins.outcome: bin/app
+package flowtest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cofunclabs/cofunc/functiondriver/go/spec"
+	"gopkg.in/yaml.v3"
+)
+
+// SpecSuffix is the suffix of the test spec files
+const SpecSuffix = "_test.yaml"
+
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+var ErrSpecIllegal = errors.New("test spec illegal")
+
+// Spec is a test spec of a flow, it has some test cases that run the flow.
+type Spec struct {
+	// Flow is the path of the flowl file, the relative path is relative to the directory of the spec file
+	Flow  string `yaml:"flow"`
+	Cases []Case `yaml:"cases"`
+
+	// path is the path of the spec file
+	path string
+}
+
+// Path returns the path of the spec file.
+func (s *Spec) Path() string {
+	return s.path
+}
+
+// FlowPath returns the path of the flowl file that the spec tests.
+func (s *Spec) FlowPath() string {
+	if filepath.IsAbs(s.Flow) {
+		return s.Flow
+	}
+	return filepath.Join(filepath.Dir(s.path), s.Flow)
+}
+
+// Case is a test case, it runs the flow once.
+type Case struct {
+	Name string `yaml:"name"`
+	// Params are the values of the params of the flow
+	Params map[string]string `yaml:"params"`
+	// Env are the environment variables set during the case, they are restored after the case
+	Env map[string]string `yaml:"env"`
+	// Mocks replace the functions of the nodes, the key is the name of the node, e.g. 'print' of 'co print' or
+	// 'build' of 'fn build = go_build'. The nodes that aren't mocked run their functions really.
+	Mocks  map[string]Mock `yaml:"mocks"`
+	Expect Expect          `yaml:"expect"`
+}
+
+// Mock is the fixed result of a mocked function.
+type Mock struct {
+	// Returns are the return values, the values that aren't strings are encoded as JSON, so they can be accessed
+	// by the path in flowl, e.g. '$(out.artifacts[0].path)'
+	Returns map[string]interface{} `yaml:"returns"`
+	// Error makes the function fail with the error message
+	Error string `yaml:"error"`
+	// Delay makes the function return after the duration, e.g. '100ms'
+	Delay time.Duration `yaml:"delay"`
+}
+
+// Expect is the expectations of a test case, the empty fields aren't checked.
+type Expect struct {
+	// Status is the status of the flow, 'succeeded' or 'failed'
+	Status string `yaml:"status"`
+	// Error is a substring of the error of the failed flow
+	Error string `yaml:"error"`
+	// Ran are the names of the nodes that ran in order, a node in a loop appears at every iteration, the nodes
+	// at the same step are ordered by their positions in the flow
+	Ran []string `yaml:"ran"`
+	// NotRan are the names of the nodes that didn't run
+	NotRan []string `yaml:"not_ran"`
+	// Args are the arguments of every run of the nodes in order, only the given arguments are compared
+	Args map[string][]map[string]string `yaml:"args"`
+	// Vars are the final values of the global variables, the field of the return values can be given by
+	// 'var.field'
+	Vars map[string]string `yaml:"vars"`
+}
+
+// Load reads and validates the test spec file.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Spec
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%w: %s: %s", ErrSpecIllegal, path, err)
+	}
+	s.path = path
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %s", ErrSpecIllegal, path, err)
+	}
+	return &s, nil
+}
+
+// Find returns the test spec files in the paths, the directories are searched for the '*_test.yaml' files,
+// but not recursively.
+func Find(paths ...string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(p, "*"+SpecSuffix))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+func (s *Spec) validate() error {
+	if s.Flow == "" {
+		return errors.New("'flow' is empty")
+	}
+	if len(s.Cases) == 0 {
+		return errors.New("no cases")
+	}
+	names := make(map[string]bool)
+	for i := range s.Cases {
+		c := &s.Cases[i]
+		if c.Name == "" {
+			return fmt.Errorf("case %d: 'name' is empty", i+1)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("case '%s': duplicated", c.Name)
+		}
+		names[c.Name] = true
+
+		switch c.Expect.Status {
+		case "", StatusSucceeded, StatusFailed:
+		default:
+			return fmt.Errorf("case '%s': status '%s' isn't '%s' or '%s'", c.Name, c.Expect.Status, StatusSucceeded, StatusFailed)
+		}
+		for node, m := range c.Mocks {
+			if _, err := m.returns(); err != nil {
+				return fmt.Errorf("case '%s': mock '%s': %s", c.Name, node, err)
+			}
+		}
+	}
+	return nil
+}
+
+// returns flattens the return values of the mock.
+func (m *Mock) returns() (map[string]string, error) {
+	return spec.Returns(m.Returns).Flatten()
+}
+
+// nodes returns the names of nodes that the case references.
+func (c *Case) nodes() []string {
+	set := make(map[string]bool)
+	for name := range c.Mocks {
+		set[name] = true
+	}
+	for _, name := range append(c.Expect.Ran, c.Expect.NotRan...) {
+		set[name] = true
+	}
+	for name := range c.Expect.Args {
+		set[name] = true
+	}
+	var names []string
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func quote(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = "'" + name + "'"
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -6,9 +6,14 @@ import (
 	"path"
 	"strings"
 
+	flowdriver "github.com/cofunclabs/cofunc/functiondriver/flow"
 	godriver "github.com/cofunclabs/cofunc/functiondriver/go"
+	httpdriver "github.com/cofunclabs/cofunc/functiondriver/http"
+	jsdriver "github.com/cofunclabs/cofunc/functiondriver/js"
 	shelldriver "github.com/cofunclabs/cofunc/functiondriver/shell"
+	wasmdriver "github.com/cofunclabs/cofunc/functiondriver/wasm"
 	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/pkg/semver"
 	"github.com/cofunclabs/cofunc/service/resource"
 )
 
@@ -44,6 +49,30 @@ func New(l Location) Driver {
 		} else {
 			dr = d
 		}
+	case jsdriver.Name:
+		if d := jsdriver.New(l.FuncName, l.FuncPath, l.Version); d == nil {
+			return nil
+		} else {
+			dr = d
+		}
+	case wasmdriver.Name:
+		if d := wasmdriver.New(l.FuncName, l.FuncPath, l.Version); d == nil {
+			return nil
+		} else {
+			dr = d
+		}
+	case flowdriver.Name:
+		if d := flowdriver.New(l.FuncName, l.FuncPath, l.Version); d == nil {
+			return nil
+		} else {
+			dr = d
+		}
+	case httpdriver.Name:
+		if d := httpdriver.New(l.FuncName, l.FuncPath, l.Version); d == nil {
+			return nil
+		} else {
+			dr = d
+		}
 	}
 	return dr
 }
@@ -55,16 +84,17 @@ type Location struct {
 	Version    string
 }
 
+// NewLocation parses the location in 'load' statement, e.g. 'shell:tools/echo@^1.2', the version is a constraint
+// that's resolved by the driver, it's 'latest' when omitted.
 func NewLocation(s string) Location {
 	fields := strings.Split(s, ":")
 	dname, fpath := fields[0], fields[1]
 
-	fname := path.Base(fields[1])
-	version := "latest"
-	if names := strings.Split(fname, "@"); len(names) == 2 {
-		fname = names[0]
-		version = names[1]
+	version := semver.Latest
+	if i := strings.LastIndex(fpath, "@"); i != -1 {
+		fpath, version = fpath[:i], fpath[i+1:]
 	}
+	fname := path.Base(fpath)
 
 	loc := Location{
 		DriverName: dname,
@@ -76,7 +106,10 @@ func NewLocation(s string) Location {
 }
 
 func (l Location) String() string {
-	return l.DriverName + ":" + l.FuncPath
+	if l.Version == semver.Latest {
+		return l.DriverName + ":" + l.FuncPath
+	}
+	return l.DriverName + ":" + l.FuncPath + "@" + l.Version
 }
 
 type LocationStore map[string]Location
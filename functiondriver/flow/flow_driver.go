@@ -0,0 +1,147 @@
+package flowdriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	co "github.com/cofunclabs/cofunc"
+	"github.com/cofunclabs/cofunc/config"
+	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/service/resource"
+)
+
+const Name = "flow"
+
+// returnsArg is a reserved argument, its value is a comma-separated list of the global variables of the nested
+// flow, which will be returned as the return values. All global variables are returned if it's not specified.
+const returnsArg = "_returns"
+
+// FlowDriver is used to call another flowl source file as a function, the flow will run as a nested flow inside
+// the parent flow. All flowl source files must be stored in the $COFUNC_HOME/flowls directory.
+type FlowDriver struct {
+	fpath   string
+	fname   string
+	version string
+	// manifest is generated by the global variables of the nested flow
+	manifest *manifest.Manifest
+	// flow is the nested flow that added into the runtime
+	flow resource.NestedFlow
+	// resources contains some services that can be used by the driver.
+	resources resource.Resources
+}
+
+// New creates a new FlowDriver instance to call a nested flow.
+func New(fname, fpath, version string) *FlowDriver {
+	return &FlowDriver{
+		fname:   fname,
+		fpath:   co.TruncFlowl(fpath),
+		version: version,
+	}
+}
+
+// Load parses the flowl source file, and adds it into the runtime as a nested flow of the current flow.
+func (d *FlowDriver) Load(ctx context.Context, resources resource.Resources) error {
+	if resources.FlowRunner == nil {
+		return errors.New("flow driver load: flow runner is not available")
+	}
+	path := filepath.Join(config.FlowSourceDir(), d.fpath+".flowl")
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%w: flow driver load", err)
+	}
+	defer file.Close()
+
+	flow, err := resources.FlowRunner.AddFlow(ctx, d.fpath, path, file)
+	if err != nil {
+		return err
+	}
+
+	_manifest := &manifest.Manifest{
+		Name:        d.fname,
+		Description: flow.Desc(),
+		Driver:      Name,
+		Entrypoint:  d.fpath + ".flowl",
+		Args:        map[string]string{},
+	}
+	for _, name := range flow.Globals() {
+		desc := manifest.UsageDesc{
+			Name: name,
+			Desc: "global variable of the flow",
+		}
+		_manifest.Usage.Args = append(_manifest.Usage.Args, desc)
+		_manifest.Usage.ReturnValues = append(_manifest.Usage.ReturnValues, desc)
+	}
+	d.manifest = _manifest
+	d.flow = flow
+	d.resources = resources
+	return nil
+}
+
+// Run executes the nested flow, the 'args' will be set to the global variables of the nested flow, the values of
+// the global variables will be returned after the nested flow finished.
+func (d *FlowDriver) Run(ctx context.Context, args map[string]string) (map[string]string, error) {
+	globals := d.flow.Globals()
+	returns := globals
+	vars := make(map[string]string)
+	for k, v := range args {
+		if k == returnsArg {
+			returns = splitNames(v)
+			continue
+		}
+		vars[k] = v
+	}
+	for _, name := range returns {
+		if !contains(globals, name) {
+			return nil, fmt.Errorf("not a global variable of the flow '%s': %s", d.fpath, name)
+		}
+	}
+	for name := range vars {
+		if !contains(globals, name) {
+			return nil, fmt.Errorf("not a global variable of the flow '%s': %s", d.fpath, name)
+		}
+	}
+	return d.flow.Run(ctx, vars, returns)
+}
+
+// StopAndRelease is used to stop and release the all resources.
+func (d *FlowDriver) StopAndRelease(ctx context.Context) error {
+	return nil
+}
+
+// FunctionName returns the name of the nested flow.
+func (d *FlowDriver) FunctionName() string {
+	return d.fname
+}
+
+// Name returns the name of the flow driver.
+func (d *FlowDriver) Name() string {
+	return Name
+}
+
+// Manifest returns the manifest of the nested flow.
+func (d *FlowDriver) Manifest() manifest.Manifest {
+	return *d.manifest
+}
+
+func splitNames(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package flowdriver_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cofunclabs/cofunc/pkg/nameid"
+	"github.com/cofunclabs/cofunc/runtime"
+	"github.com/cofunclabs/cofunc/runtime/actuator"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFlow(t *testing.T, home, name, data string) string {
+	path := filepath.Join(home, "flowls", name+".flowl")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	return path
+}
+
+// parseFlow parses and initializes the flow of the file, the 'stub' runs the nested flows
+func parseFlow(t *testing.T, rt *runtime.Runtime, path string, stub actuator.Stub) (nameid.ID, error) {
+	ctx := context.Background()
+	id := nameid.New(filepath.Base(path))
+	rd, err := os.Open(path)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer rd.Close()
+	if err := rt.ParseFlow(ctx, id, rd); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	discard := runtime.WithCreateLogwriter(func(string, string) (io.Writer, error) {
+		return io.Discard, nil
+	})
+	opts := []runtime.FlowOption{runtime.WithPath(path), discard}
+	if stub != nil {
+		opts = append(opts, runtime.WithStub(&runtime.Plan{}, stub))
+	}
+	return id, rt.InitFlow(ctx, id, opts...)
+}
+
+func TestRepeatedRuns(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("COFUNC_HOME", home)
+
+	writeFlow(t, home, "greet", `
+var name = "world"
+var count = 0
+var greeting = "hello $(name)"
+count <- $(count) + 1
+`)
+	path := writeFlow(t, home, "main", `
+load "flow:greet"
+
+co greet {
+	"name": "cofunc"
+	"_returns": "greeting, count"
+}
+`)
+
+	// The second run leaves out the 'name', it has the declared default value
+	var (
+		runs    int
+		returns []map[string]string
+	)
+	stub := func(ctx context.Context, n *actuator.TaskNode, args map[string]string) (map[string]string, error) {
+		runs++
+		if runs == 2 {
+			delete(args, "name")
+		}
+		ret, err := n.Driver().Run(ctx, args)
+		returns = append(returns, ret)
+		return ret, err
+	}
+	rt := runtime.New()
+	defer rt.Shutdown(context.Background())
+	id, err := parseFlow(t, rt, path, stub)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, rt.MustReady(context.Background(), id))
+		assert.NoError(t, rt.ExecFlow(context.Background(), id))
+	}
+	assert.Equal(t, []map[string]string{
+		{"greeting": "hello cofunc", "count": "1"},
+		{"greeting": "hello world", "count": "1"},
+	}, returns)
+}
+
+func TestUnknownGlobal(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("COFUNC_HOME", home)
+
+	writeFlow(t, home, "greet", `var name = "world"`)
+	path := writeFlow(t, home, "main", `
+load "flow:greet"
+
+co greet {
+	"nope": "x"
+}
+`)
+	rt := runtime.New()
+	defer rt.Shutdown(context.Background())
+	id, err := parseFlow(t, rt, path, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = rt.ExecFlow(context.Background(), id)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not a global variable of the flow 'greet': nope")
+	}
+}
+
+func TestCycle(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("COFUNC_HOME", home)
+
+	a := writeFlow(t, home, "a", `
+load "flow:b"
+co b
+`)
+	writeFlow(t, home, "b", `
+load "flow:a"
+co a
+`)
+	rt := runtime.New()
+	defer rt.Shutdown(context.Background())
+	_, err := parseFlow(t, rt, a, nil)
+	assert.ErrorIs(t, err, runtime.ErrFlowHasCycle)
+	// The cycle is detected when 'b' loads 'a', 'b' isn't loaded again
+	if assert.Error(t, err) {
+		assert.Equal(t, 1, strings.Count(err.Error(), "b.flowl"), err.Error())
+	}
+
+	// The top flow in another directory isn't the same flow as the nested one with the same name
+	other := t.TempDir()
+	path := filepath.Join(other, "b.flowl")
+	if err := os.WriteFile(path, []byte("load \"flow:greet\"\nco greet\n"), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	writeFlow(t, home, "greet", `var name = "world"`)
+	_, err = parseFlow(t, rt, path, nil)
+	assert.NoError(t, err)
+}
@@ -3,9 +3,11 @@ package godriver
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/cofunclabs/cofunc/functiondriver/go/spec"
 	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/pkg/semver"
 	"github.com/cofunclabs/cofunc/service/resource"
 	"github.com/cofunclabs/cofunc/std"
 )
@@ -45,6 +47,9 @@ func (d *GoDriver) Load(ctx context.Context, resources resource.Resources) error
 	if mf == nil || ep == nil {
 		return errors.New("in std, not found function's manifest or entrypoint: " + d.path)
 	}
+	if err := d.checkVersion(mf); err != nil {
+		return err
+	}
 	d.manifest = mf
 	d.entrypoint = ep
 	if create != nil {
@@ -100,6 +105,22 @@ func (d *GoDriver) Manifest() manifest.Manifest {
 	return *d.manifest
 }
 
+// checkVersion checks the version of the function satisfies the version constraint in 'load' statement.
+func (d *GoDriver) checkVersion(mf *manifest.Manifest) error {
+	c, err := semver.ParseConstraint(d.version)
+	if err != nil {
+		return err
+	}
+	v, err := semver.Parse(mf.Version)
+	if err != nil {
+		return fmt.Errorf("%w: manifest of function '%s'", err, d.fname)
+	}
+	if !c.Match(v) {
+		return fmt.Errorf("%w: function '%s' version '%s', installed versions: %s", manifest.ErrVersionNotFound, d.fname, c, mf.Version)
+	}
+	return nil
+}
+
 func (d *GoDriver) mergeArgs(args map[string]string) map[string]string {
 	merged := make(map[string]string)
 	for k, v := range d.manifest.Args {
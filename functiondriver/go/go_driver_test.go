@@ -5,6 +5,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/cofunclabs/cofunc/manifest"
 	"github.com/cofunclabs/cofunc/service/resource"
 	"github.com/stretchr/testify/assert"
 )
@@ -42,3 +43,20 @@ func TestRun(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "ok", out["status"])
 }
+
+func TestLoadVersion(t *testing.T) {
+	{
+		dr := New("print", "print", "^0.1")
+		err := dr.Load(context.Background(), resource.Resources{
+			Logwriter: os.Stdout,
+		})
+		assert.NoError(t, err)
+	}
+	{
+		dr := New("print", "print", "^2.0")
+		err := dr.Load(context.Background(), resource.Resources{
+			Logwriter: os.Stdout,
+		})
+		assert.ErrorIs(t, err, manifest.ErrVersionNotFound)
+	}
+}
@@ -2,12 +2,14 @@ package spec
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
+	"fmt"
 	"reflect"
 	"runtime"
 	"strconv"
-	"strings"
+	"time"
 
+	"github.com/cofunclabs/cofunc/manifest"
 	"github.com/cofunclabs/cofunc/pkg/stringutil"
 	"github.com/cofunclabs/cofunc/service/resource"
 )
@@ -16,6 +18,7 @@ const (
 	isString ArgValType = iota
 	isInt
 	isBool
+	isDuration
 )
 
 type ArgValType int
@@ -23,9 +26,12 @@ type ArgValType int
 // EntrypointArgs is the 'Args' argument of the entrypoint
 type EntrypointArgs map[string]string
 
+// GetURL returns the url value, it returns an error if the value isn't a valid url.
 func (e EntrypointArgs) GetURL(name string) (string, error) {
 	s := e.GetString(name)
-	// TODO: check s
+	if _, err := manifest.ParseURL(s); err != nil {
+		return "", fmt.Errorf("%w: '%s'", err, name)
+	}
 	return s, nil
 }
 
@@ -57,11 +63,20 @@ func (e EntrypointArgs) GetBool(name string) (bool, error) {
 	return v.(bool), nil
 }
 
-// Get returns the value of the key 'name' in map, if the 'name' not existed, return nil.
+func (e EntrypointArgs) GetDuration(name string) (time.Duration, error) {
+	v, err := e.Get(name, isDuration)
+	if err != nil {
+		return 0, err
+	}
+	return v.(time.Duration), nil
+}
+
+// Get returns the value of the key 'name' in map, it returns an error if the 'name' not existed or the value
+// can't be converted to the type.
 func (e EntrypointArgs) Get(name string, typ ArgValType) (interface{}, error) {
 	v, ok := e[name]
 	if !ok {
-		return nil, errors.New("not found")
+		return nil, fmt.Errorf("%w: '%s'", manifest.ErrMissingArgument, name)
 	}
 	switch typ {
 	case isString:
@@ -69,18 +84,23 @@ func (e EntrypointArgs) Get(name string, typ ArgValType) (interface{}, error) {
 	case isInt:
 		n, err := strconv.ParseInt(v, 10, 64)
 		if err != nil {
-			return n, nil
+			return nil, fmt.Errorf("%w: '%s', '%s' isn't an int", manifest.ErrInvalidArgument, name, v)
 		}
+		return n, nil
 	case isBool:
-		if s := strings.ToLower(v); s == "true" || s == "yes" || s == "1" {
-			return true, nil
-		} else if s := strings.ToLower(v); s == "false" || s == "no" || s == "0" {
-			return false, nil
-		} else {
-			return nil, errors.New("invalid bool value")
+		b, err := manifest.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: '%s'", err, name)
+		}
+		return b, nil
+	case isDuration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: '%s', '%s' isn't a duration", manifest.ErrInvalidArgument, name, v)
 		}
+		return d, nil
 	}
-	return nil, nil
+	return nil, fmt.Errorf("%w: '%s', unknown type", manifest.ErrInvalidArgument, name)
 }
 
 // EntrypointBundle
@@ -93,6 +113,43 @@ type EntrypointBundle struct {
 // EntrypointFunc defines the entrypoint type of the function
 type EntrypointFunc func(context.Context, EntrypointBundle, EntrypointArgs) (map[string]string, error)
 
+// Returns is the return values of StructuredEntrypointFunc, a value can be a string, number, bool, nil or the
+// slices and maps of them, they are JSON-compatible.
+type Returns map[string]interface{}
+
+// Flatten converts the return values to the flat map, the strings are kept, the other values are encoded as JSON,
+// so they can be accessed by the path in flowl.
+func (r Returns) Flatten() (map[string]string, error) {
+	flat := make(map[string]string, len(r))
+	for k, v := range r {
+		if s, ok := v.(string); ok {
+			flat[k] = s
+			continue
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("return value '%s': %w", k, err)
+		}
+		flat[k] = string(data)
+	}
+	return flat, nil
+}
+
+// StructuredEntrypointFunc defines the entrypoint type of the function that returns the nested values, they are
+// accessed by the path in flowl, e.g. '$(out.artifacts[0].path)'.
+type StructuredEntrypointFunc func(context.Context, EntrypointBundle, EntrypointArgs) (Returns, error)
+
+// Flat converts the structured entrypoint to the EntrypointFunc, its return values are flattened by 'Returns.Flatten'.
+func Flat(f StructuredEntrypointFunc) EntrypointFunc {
+	return func(ctx context.Context, bundle EntrypointBundle, args EntrypointArgs) (map[string]string, error) {
+		rets, err := f(ctx, bundle, args)
+		if err != nil {
+			return nil, err
+		}
+		return rets.Flatten()
+	}
+}
+
 // CreateCustomFunc can be used to create a custom object for the function
 // The custom object must implement the 'Close' method, godriver can use this method to
 // close or release the custom object.
@@ -101,7 +158,8 @@ type Customer interface {
 	Close() error
 }
 
-// Func2Name returns the name of the function 'f', it contains the full package name.
-func Func2Name(f EntrypointFunc) string {
+// Func2Name returns the name of the function 'f', it contains the full package name. The 'f' is an EntrypointFunc
+// or a StructuredEntrypointFunc.
+func Func2Name(f interface{}) string {
 	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
 }
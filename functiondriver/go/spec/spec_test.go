@@ -3,7 +3,9 @@ package spec
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/cofunclabs/cofunc/manifest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,3 +18,58 @@ func TestFunc2Name(t *testing.T) {
 	name := Func2Name(f)
 	assert.Equal(t, "github.com/cofunclabs/cofunc/functiondriver/go/spec.IsAFunction", name)
 }
+
+func TestEntrypointArgs(t *testing.T) {
+	args := EntrypointArgs{
+		"int":      "10",
+		"badint":   "ten",
+		"bool":     "yes",
+		"duration": "1s",
+		"url":      "http://localhost:8080",
+	}
+	n, err := args.GetInt("int")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+
+	_, err = args.GetInt("badint")
+	assert.ErrorIs(t, err, manifest.ErrInvalidArgument)
+
+	_, err = args.GetInt("notexisted")
+	assert.ErrorIs(t, err, manifest.ErrMissingArgument)
+
+	b, err := args.GetBool("bool")
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	d, err := args.GetDuration("duration")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, d)
+
+	_, err = args.GetURL("url")
+	assert.NoError(t, err)
+	_, err = args.GetURL("int")
+	assert.ErrorIs(t, err, manifest.ErrInvalidArgument)
+}
+
+func IsAStructuredFunction(ctx context.Context, bundle EntrypointBundle, args EntrypointArgs) (Returns, error) {
+	return Returns{
+		"s":    "a,b",
+		"n":    3,
+		"list": []interface{}{map[string]interface{}{"path": "bin/a"}},
+	}, nil
+}
+
+func TestStructuredEntrypoint(t *testing.T) {
+	assert.Equal(t, "github.com/cofunclabs/cofunc/functiondriver/go/spec.IsAStructuredFunction", Func2Name(IsAStructuredFunction))
+
+	rets, err := Flat(IsAStructuredFunction)(context.Background(), EntrypointBundle{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"s":    "a,b",
+		"n":    "3",
+		"list": `[{"path":"bin/a"}]`,
+	}, rets)
+
+	_, err = Returns{"ch": make(chan int)}.Flatten()
+	assert.Error(t, err)
+}
@@ -0,0 +1,350 @@
+package httpdriver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cofunclabs/cofunc/config"
+	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/pkg/semver"
+	"github.com/cofunclabs/cofunc/service/resource"
+)
+
+const Name = "http"
+
+// endpointsFile is the configuration file of all remote functions, it's stored in $COFUNC_HOME/http directory.
+const endpointsFile = "endpoints.json"
+
+// Endpoint is the configuration of a remote function, e.g.
+//
+//	{
+//	    "builder@v1": {
+//	        "url": "http://127.0.0.1:8080/builder",
+//	        "manifest_url": "http://127.0.0.1:8080/builder/manifest",
+//	        "headers": {"Authorization": "Bearer xxx"}
+//	    }
+//	}
+type Endpoint struct {
+	URL         string            `json:"url"`
+	ManifestURL string            `json:"manifest_url"`
+	Headers     map[string]string `json:"headers"`
+}
+
+// message is a message that the remote function responds, a response can contain multiple messages when
+// it's streaming, the 'log' will be written into the log writer, the 'returns' will be merged into the
+// return values.
+type message struct {
+	Log     string                 `json:"log"`
+	Returns map[string]interface{} `json:"returns"`
+	Error   string                 `json:"error"`
+}
+
+// HttpDriver is used to call the remote functions through HTTP. The endpoints of the remote functions must be
+// configured in the $COFUNC_HOME/http/endpoints.json file, the key is the 'name@version' or 'name' for the
+// unversioned endpoint.
+type HttpDriver struct {
+	fpath   string
+	fname   string
+	version string
+	// manifest be defined by function, it's fetched from the 'manifest_url' of the endpoint
+	manifest *manifest.Manifest
+	endpoint Endpoint
+	client   *http.Client
+	// resources contains some services that can be used by driver self.
+	resources resource.Resources
+}
+
+// New creates a new HttpDriver instance to call remote functions.
+func New(fname, fpath, version string) *HttpDriver {
+	return &HttpDriver{
+		fname:   fname,
+		fpath:   fpath,
+		version: version,
+		client:  &http.Client{},
+	}
+}
+
+// Load finds the endpoint of the remote function, and fetches the manifest of the function if the 'manifest_url'
+// of the endpoint is configured.
+func (d *HttpDriver) Load(ctx context.Context, resources resource.Resources) error {
+	endpoint, err := d.findEndpoint()
+	if err != nil {
+		return err
+	}
+
+	_manifest := manifest.Manifest{
+		Name:   d.fname,
+		Driver: Name,
+	}
+	if endpoint.ManifestURL != "" {
+		req, err := d.newRequest(ctx, http.MethodGet, endpoint.ManifestURL, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("%w: http driver fetch manifest", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("http driver fetch manifest: %s", resp.Status)
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&_manifest); err != nil {
+			return fmt.Errorf("%w: http driver decode manifest", err)
+		}
+	}
+
+	d.manifest = &_manifest
+	d.endpoint = endpoint
+	d.resources = resources
+	return nil
+}
+
+// Run posts the args as a JSON object to the endpoint, e.g. {"args": {"k": "v"}}, the response can be a JSON
+// object, or a stream of JSON objects that's newline-delimited or server-sent events, so that the remote function
+// is able to send the log lines back while it's running.
+func (d *HttpDriver) Run(ctx context.Context, args map[string]string) (map[string]string, error) {
+	printer, ok := d.resources.Logwriter.(resource.LogStdoutPrinter)
+	if ok {
+		defer func() {
+			printer.PrintSummary()
+			printer.Reset()
+		}()
+		printer.PrintTitle()
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"args": d.mergeArgs(args),
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := d.newRequest(ctx, http.MethodPost, d.endpoint.URL, d.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream, application/x-ndjson, application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, d.convertError(ctx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		content, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("http function '%s' responds %s: %s", d.fname, resp.Status, strings.TrimSpace(string(content)))
+	}
+
+	retValues := make(map[string]string)
+	handle := func(msg message) error {
+		if msg.Log != "" {
+			log := msg.Log
+			if !strings.HasSuffix(log, "\n") {
+				log += "\n"
+			}
+			io.WriteString(d.resources.Logwriter, log)
+		}
+		for k, v := range msg.Returns {
+			s, err := toString(v)
+			if err != nil {
+				return fmt.Errorf("%w: convert return value '%s'", err, k)
+			}
+			retValues[k] = s
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("http function '%s': %s", d.fname, msg.Error)
+		}
+		return nil
+	}
+
+	mediatype, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediatype == "text/event-stream" {
+		err = readEvents(resp.Body, handle)
+	} else {
+		err = readObjects(resp.Body, handle)
+	}
+	if err != nil {
+		return nil, d.convertError(ctx, err)
+	}
+	return retValues, nil
+}
+
+// StopAndRelease closes the idle connections of the http client.
+func (d *HttpDriver) StopAndRelease(ctx context.Context) error {
+	d.client.CloseIdleConnections()
+	return nil
+}
+
+// FunctionName returns the name of the remote function.
+func (d *HttpDriver) FunctionName() string {
+	return d.fname
+}
+
+// Name returns the name of the http driver.
+func (d *HttpDriver) Name() string {
+	return Name
+}
+
+// Manifest returns the manifest of the remote function.
+func (d *HttpDriver) Manifest() manifest.Manifest {
+	return *d.manifest
+}
+
+// findEndpoint finds the endpoint by 'name@version' first, then by the highest version that satisfies the version
+// constraint, the endpoint 'name' is used only if the version constraint is 'latest' and no versions are matched.
+func (d *HttpDriver) findEndpoint() (Endpoint, error) {
+	file, err := os.Open(filepath.Join(config.HttpDir(), endpointsFile))
+	if err != nil {
+		return Endpoint{}, fmt.Errorf("%w: http driver load", err)
+	}
+	defer file.Close()
+
+	var endpoints map[string]Endpoint
+	if err := json.NewDecoder(file).Decode(&endpoints); err != nil {
+		return Endpoint{}, fmt.Errorf("%w: http driver decode endpoints", err)
+	}
+	c, err := semver.ParseConstraint(d.version)
+	if err != nil {
+		return Endpoint{}, err
+	}
+
+	key, found := d.fname+"@"+d.version, false
+	if _, found = endpoints[key]; !found {
+		var versions []string
+		for k := range endpoints {
+			if strings.HasPrefix(k, d.fname+"@") {
+				versions = append(versions, strings.TrimPrefix(k, d.fname+"@"))
+			}
+		}
+		var v string
+		if v, found = c.Highest(versions); found {
+			key = d.fname + "@" + v
+		} else if _, found = endpoints[d.fname]; found && c.IsLatest() {
+			key = d.fname
+		} else {
+			semver.Sort(versions)
+			installed := "none"
+			if len(versions) != 0 {
+				installed = strings.Join(versions, ", ")
+			}
+			return Endpoint{}, fmt.Errorf("%w: http function '%s' version '%s', configured versions: %s", manifest.ErrVersionNotFound, d.fname, c, installed)
+		}
+	}
+	endpoint := endpoints[key]
+	if endpoint.URL == "" {
+		return Endpoint{}, fmt.Errorf("not found url of http function: %s", key)
+	}
+	return endpoint, nil
+}
+
+func (d *HttpDriver) newRequest(ctx context.Context, method, url string, endpoint Endpoint, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range endpoint.Headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+func (d *HttpDriver) mergeArgs(args map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for k, v := range d.manifest.Args {
+		merged[k] = v
+	}
+	for k, v := range args {
+		merged[k] = v
+	}
+	return merged
+}
+
+// convertError returns the error of the context if the context is done, it makes the cancellation
+// can be detected by the caller.
+func (d *HttpDriver) convertError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// readObjects reads a stream of JSON objects, a single JSON object is a stream that has only one object.
+func readObjects(rd io.Reader, handle func(message) error) error {
+	decoder := json.NewDecoder(rd)
+	for {
+		var msg message
+		err := decoder.Decode(&msg)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: decode response", err)
+		}
+		if err := handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// readEvents reads the server-sent events, the data of every event is a JSON object.
+func readEvents(rd io.Reader, handle func(message) error) error {
+	var data []string
+	dispatch := func() error {
+		if len(data) == 0 {
+			return nil
+		}
+		var msg message
+		err := json.Unmarshal([]byte(strings.Join(data, "\n")), &msg)
+		data = data[0:0]
+		if err != nil {
+			return fmt.Errorf("%w: decode event", err)
+		}
+		return handle(msg)
+	}
+
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if err := dispatch(); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "data:") {
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return dispatch()
+}
+
+// toString keeps the string value as it is, and converts other values to JSON.
+func toString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+}
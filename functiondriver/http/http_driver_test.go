@@ -0,0 +1,197 @@
+package httpdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/service/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func installEndpoints(t *testing.T, endpoints map[string]Endpoint) {
+	home := t.TempDir()
+	os.Setenv("COFUNC_HOME", home)
+	t.Cleanup(func() {
+		os.Unsetenv("COFUNC_HOME")
+	})
+
+	dir := filepath.Join(home, "http")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	b, err := json.Marshal(endpoints)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(dir, endpointsFile), b, 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+}
+
+func newTestingServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/manifest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "builder", "description": "a remote builder", "args": {"target": "all"}}`)
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer testing" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Args map[string]string `json:"args"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"log": "building %s", "returns": {"target": "%s", "count": 2}}`, body.Args["message"], body.Args["target"])
+	})
+	mux.HandleFunc("/ndjson", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"log": "line 1"}`)
+		w.(http.Flusher).Flush()
+		fmt.Fprintln(w, `{"log": "line 2"}`)
+		fmt.Fprintln(w, `{"returns": {"status": "ok"}}`)
+	})
+	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"log\": \"line 1\"}\n\n")
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, ": comment\ndata: {\"log\": \"line 2\",\ndata: \"returns\": {\"status\": \"ok\"}}\n\n")
+	})
+	mux.HandleFunc("/failed", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"log": "building", "error": "build failed"}`)
+	})
+	mux.HandleFunc("/block", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		fmt.Fprintln(w, `{"log": "waiting"}`)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestHttpDriver(t *testing.T) {
+	server := newTestingServer()
+	defer server.Close()
+	installEndpoints(t, map[string]Endpoint{
+		"builder@v1": {
+			URL:         server.URL + "/json",
+			ManifestURL: server.URL + "/manifest",
+			Headers:     map[string]string{"Authorization": "Bearer testing"},
+		},
+		"lines": {
+			URL: server.URL + "/ndjson",
+		},
+		"streamer": {
+			URL: server.URL + "/sse",
+		},
+		"failed": {
+			URL: server.URL + "/failed",
+		},
+	})
+	ctx := context.Background()
+
+	{
+		var buf bytes.Buffer
+		driver := New("builder", "builder", "v1")
+		if err := driver.Load(ctx, resource.Resources{Logwriter: &buf}); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		assert.Equal(t, "a remote builder", driver.Manifest().Description)
+		rets, err := driver.Run(ctx, map[string]string{"message": "cofunc"})
+		assert.NoError(t, err)
+		assert.Equal(t, "building cofunc", strings.TrimSpace(buf.String()))
+		assert.Equal(t, "all", rets["target"])
+		assert.Equal(t, "2", rets["count"])
+	}
+
+	{
+		// The highest version that satisfies the constraint
+		var buf bytes.Buffer
+		driver := New("builder", "builder", "^1.0")
+		if err := driver.Load(ctx, resource.Resources{Logwriter: &buf}); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		rets, err := driver.Run(ctx, map[string]string{"message": "cofunc"})
+		assert.NoError(t, err)
+		assert.Equal(t, "all", rets["target"])
+
+		err = New("builder", "builder", "^2.0").Load(ctx, resource.Resources{Logwriter: &buf})
+		assert.ErrorIs(t, err, manifest.ErrVersionNotFound)
+	}
+
+	{
+		// The endpoint without version
+		var buf bytes.Buffer
+		driver := New("lines", "lines", "latest")
+		if err := driver.Load(ctx, resource.Resources{Logwriter: &buf}); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		rets, err := driver.Run(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "line 1\nline 2", strings.TrimSpace(buf.String()))
+		assert.Equal(t, "ok", rets["status"])
+	}
+
+	{
+		var buf bytes.Buffer
+		driver := New("streamer", "streamer", "latest")
+		if err := driver.Load(ctx, resource.Resources{Logwriter: &buf}); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		rets, err := driver.Run(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "line 1\nline 2", strings.TrimSpace(buf.String()))
+		assert.Equal(t, "ok", rets["status"])
+	}
+
+	{
+		var buf bytes.Buffer
+		driver := New("failed", "failed", "latest")
+		if err := driver.Load(ctx, resource.Resources{Logwriter: &buf}); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		_, err := driver.Run(ctx, nil)
+		assert.Error(t, err)
+		assert.Equal(t, "building", strings.TrimSpace(buf.String()))
+	}
+
+	{
+		driver := New("unknown", "unknown", "latest")
+		err := driver.Load(ctx, resource.Resources{Logwriter: os.Stdout})
+		assert.Error(t, err)
+	}
+}
+
+func TestHttpDriverCancel(t *testing.T) {
+	server := newTestingServer()
+	defer server.Close()
+	installEndpoints(t, map[string]Endpoint{
+		"block": {
+			URL: server.URL + "/block",
+		},
+	})
+
+	var buf bytes.Buffer
+	driver := New("block", "block", "latest")
+	if err := driver.Load(context.Background(), resource.Resources{Logwriter: &buf}); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer driver.StopAndRelease(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err := driver.Run(ctx, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "waiting", strings.TrimSpace(buf.String()))
+}
@@ -0,0 +1,210 @@
+package jsdriver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cofunclabs/cofunc/config"
+	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/service/resource"
+	"github.com/dop251/goja"
+)
+
+const Name = "js"
+
+// mainFunc is the name of the function that must be defined by the javascript function, it's the entry
+// of the function.
+const mainFunc = "main"
+
+// JsDriver is used to execute javascript functions by an embedded javascript engine, so no external runtime
+// is required. All javascript functions must be stored in $COFUNC_HOME/js directory, the JsDriver is able to
+// find and load them.
+type JsDriver struct {
+	fpath   string
+	fname   string
+	version string
+	// manifest be defined by function
+	manifest *manifest.Manifest
+	// program is the compiled entrypoint script of the function
+	program *goja.Program
+	// resources contains some services that can be used by the driver and function.
+	resources resource.Resources
+}
+
+// New creates a new JsDriver instance to execute javascript functions.
+func New(fname, fpath, version string) *JsDriver {
+	return &JsDriver{
+		fname:   fname,
+		fpath:   fpath,
+		version: version,
+	}
+}
+
+// Load loads the javascript function from $COFUNC_HOME/js directory, the version of the function is resolved
+// by the version constraint, then compiles the entrypoint script.
+func (d *JsDriver) Load(ctx context.Context, resources resource.Resources) error {
+	functionDir, _manifest, err := manifest.Resolve(config.JsDir(), d.fpath, d.version)
+	if err != nil {
+		return fmt.Errorf("%w: js driver load", err)
+	}
+	if _manifest.Entrypoint == "" {
+		return fmt.Errorf("not found entrypoint in js function: %s", d.fname)
+	}
+	script := filepath.Join(functionDir, _manifest.Entrypoint)
+	src, err := os.ReadFile(script)
+	if err != nil {
+		return fmt.Errorf("%w: read entrypoint script", err)
+	}
+	program, err := goja.Compile(script, string(src), false)
+	if err != nil {
+		return fmt.Errorf("%w: compile entrypoint script", err)
+	}
+
+	d.manifest = _manifest
+	d.program = program
+	d.resources = resources
+	return nil
+}
+
+// Run executes the javascript function, the function's 'main' will be called with the 'args', the object
+// returned by 'main' will be converted to the return values.
+// Every execution runs in a new javascript runtime, so no state is kept between executions.
+func (d *JsDriver) Run(ctx context.Context, args map[string]string) (map[string]string, error) {
+	printer, ok := d.resources.Logwriter.(resource.LogStdoutPrinter)
+	if ok {
+		defer func() {
+			printer.PrintSummary()
+			printer.Reset()
+		}()
+		printer.PrintTitle()
+	}
+	merged := d.mergeArgs(args)
+
+	vm := goja.New()
+	if err := vm.Set("args", merged); err != nil {
+		return nil, err
+	}
+	if err := vm.Set("log", d.newLogObject(vm)); err != nil {
+		return nil, err
+	}
+
+	// Interrupt the javascript runtime when the context is canceled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			vm.Interrupt(ctx.Err())
+		case <-done:
+		}
+	}()
+
+	if _, err := vm.RunProgram(d.program); err != nil {
+		return nil, d.convertError(err)
+	}
+	entry, ok := goja.AssertFunction(vm.Get(mainFunc))
+	if !ok {
+		return nil, fmt.Errorf("not found function '%s' in js function: %s", mainFunc, d.fname)
+	}
+	ret, err := entry(goja.Undefined(), vm.Get("args"))
+	if err != nil {
+		return nil, d.convertError(err)
+	}
+	return toReturnValues(ret)
+}
+
+// StopAndRelease is used to stop and release the all resources.
+func (d *JsDriver) StopAndRelease(ctx context.Context) error {
+	d.program = nil
+	return nil
+}
+
+// FunctionName returns the name of the javascript function.
+func (d *JsDriver) FunctionName() string {
+	return d.fname
+}
+
+// Name returns the name of the javascript driver.
+func (d *JsDriver) Name() string {
+	return Name
+}
+
+// Manifest returns the manifest of the javascript function.
+func (d *JsDriver) Manifest() manifest.Manifest {
+	return *d.manifest
+}
+
+func (d *JsDriver) mergeArgs(args map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for k, v := range d.manifest.Args {
+		merged[k] = v
+	}
+	for k, v := range args {
+		merged[k] = v
+	}
+	return merged
+}
+
+// newLogObject creates the 'log' object of the javascript runtime, it writes the content into the log writer.
+func (d *JsDriver) newLogObject(vm *goja.Runtime) *goja.Object {
+	write := func(newline bool) func(goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
+			var values []interface{}
+			for _, arg := range call.Arguments {
+				values = append(values, arg.String())
+			}
+			if newline {
+				fmt.Fprintln(d.resources.Logwriter, values...)
+			} else {
+				fmt.Fprint(d.resources.Logwriter, values...)
+			}
+			return goja.Undefined()
+		}
+	}
+	obj := vm.NewObject()
+	obj.Set("print", write(false))
+	obj.Set("println", write(true))
+	return obj
+}
+
+func (d *JsDriver) convertError(err error) error {
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		if v, ok := interrupted.Value().(error); ok {
+			return v
+		}
+	}
+	return fmt.Errorf("%w: js function '%s'", err, d.fname)
+}
+
+// toReturnValues converts the value returned by the 'main' function to the return values, string values are
+// kept as it is, other values are converted to JSON.
+func toReturnValues(v goja.Value) (map[string]string, error) {
+	retValues := make(map[string]string)
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return retValues, nil
+	}
+	exported, ok := v.Export().(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the return value of js function must be an object, actual '%s'", v.ExportType())
+	}
+	for k, val := range exported {
+		switch val := val.(type) {
+		case nil:
+			continue
+		case string:
+			retValues[k] = val
+		default:
+			b, err := json.Marshal(val)
+			if err != nil {
+				return nil, fmt.Errorf("%w: convert return value '%s'", err, k)
+			}
+			retValues[k] = string(b)
+		}
+	}
+	return retValues, nil
+}
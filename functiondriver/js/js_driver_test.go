@@ -0,0 +1,58 @@
+package jsdriver
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cofunclabs/cofunc/service/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsDriver(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	os.Setenv("COFUNC_HOME", filepath.Join(wd, "testdata"))
+	defer os.Unsetenv("COFUNC_HOME")
+
+	var buf bytes.Buffer
+	ctx := context.Background()
+
+	driver := New("hello", "hello", "latest")
+	if err := driver.Load(ctx, resource.Resources{
+		Logwriter: &buf,
+	}); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	rets, err := driver.Run(ctx, map[string]string{"message": "testing js driver"})
+	assert.NoError(t, err)
+	assert.Equal(t, "hello testing js driver", strings.TrimSpace(buf.String()))
+	assert.Equal(t, "testing js driver", rets["message"])
+	assert.Equal(t, "17", rets["length"])
+}
+
+func TestJsDriverCancel(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	os.Setenv("COFUNC_HOME", filepath.Join(wd, "testdata"))
+	defer os.Unsetenv("COFUNC_HOME")
+
+	driver := New("loop", "loop", "latest")
+	if err := driver.Load(context.Background(), resource.Resources{
+		Logwriter: os.Stdout,
+	}); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err = driver.Run(ctx, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
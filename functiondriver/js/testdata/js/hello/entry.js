@@ -0,0 +1,7 @@
+function main(args) {
+    log.println(args.greeting, args.message)
+    return {
+        "message": args.message,
+        "length": args.message.length
+    }
+}
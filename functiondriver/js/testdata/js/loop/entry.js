@@ -0,0 +1,4 @@
+function main(args) {
+    for (;;) {
+    }
+}
@@ -2,7 +2,6 @@ package shelldriver
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -23,6 +22,8 @@ type ShellDriver struct {
 	fpath   string
 	fname   string
 	version string
+	// dir is the directory of the function version that be resolved
+	dir string
 	// manifest be defined by function
 	manifest *manifest.Manifest
 	// resources contains some services that can be used by driver self. the shell function
@@ -39,18 +40,13 @@ func New(fname, fpath, version string) *ShellDriver {
 	}
 }
 
-// Load loads the shell script function from $COFUNC_HOME/shell directory.
+// Load loads the shell script function from $COFUNC_HOME/shell directory, the version of the function is
+// resolved by the version constraint.
 func (d *ShellDriver) Load(ctx context.Context, resources resource.Resources) error {
-	functionDir := filepath.Join(config.ShellDir(), d.fpath)
-	mfPath := filepath.Join(functionDir, "manifest.json")
-	file, err := os.Open(mfPath)
+	functionDir, _manifest, err := manifest.Resolve(config.ShellDir(), d.fpath, d.version)
 	if err != nil {
 		return fmt.Errorf("%w: shell driver load", err)
 	}
-	var _manifest manifest.Manifest
-	if err := json.NewDecoder(file).Decode(&_manifest); err != nil {
-		return fmt.Errorf("%w: shell driver decode manifest", err)
-	}
 
 	if _manifest.Entrypoint == "" {
 		return fmt.Errorf("not found entrypoint in shell function: %s", d.fname)
@@ -60,7 +56,8 @@ func (d *ShellDriver) Load(ctx context.Context, resources resource.Resources) er
 		return fmt.Errorf("%w: not found entrypoint program", err)
 	}
 
-	d.manifest = &_manifest
+	d.dir = functionDir
+	d.manifest = _manifest
 	d.resources = resources
 
 	return nil
@@ -77,11 +74,10 @@ func (d *ShellDriver) Run(ctx context.Context, args map[string]string) (map[stri
 		printer.PrintTitle()
 	}
 	merged := d.mergeArgs(args)
-	functionDir := filepath.Join(config.ShellDir(), d.fpath)
-	program := filepath.Join(functionDir, d.manifest.Entrypoint)
+	program := filepath.Join(d.dir, d.manifest.Entrypoint)
 
 	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", program)
-	cmd.Dir = functionDir
+	cmd.Dir = d.dir
 	cmd.Env = append(cmd.Env, d.toEnv(merged)...)
 
 	retValues := make(map[string]string)
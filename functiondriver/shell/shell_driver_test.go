@@ -23,7 +23,7 @@ func TestShellDriver(t *testing.T) {
 	var buf bytes.Buffer
 	ctx := context.Background()
 
-	driver := New("echo", "echo", "lastest")
+	driver := New("echo", "echo", "latest")
 	if err := driver.Load(ctx, resource.Resources{
 		Logwriter: &buf,
 	}); err != nil {
@@ -0,0 +1,196 @@
+package wasmdriver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cofunclabs/cofunc/config"
+	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/pkg/output"
+	"github.com/cofunclabs/cofunc/service/resource"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+const Name = "wasm"
+
+const (
+	// defaultMemoryLimit is the max memory in MiB that a wasm function can use by default.
+	defaultMemoryLimit = 64
+	// pagesPerMiB is the number of wasm pages in 1MiB, the size of a wasm page is 64KiB.
+	pagesPerMiB = 16
+	// returnPrefix is the prefix of the line in stdout that be used to set a return value,
+	// e.g. '::return::key=value'
+	returnPrefix = "::return::"
+)
+
+// WasmDriver is used to execute WebAssembly functions in a sandbox through a WASI runtime. All wasm functions
+// must be stored in $COFUNC_HOME/wasm directory, the WasmDriver is able to find and load them.
+type WasmDriver struct {
+	fpath   string
+	fname   string
+	version string
+	// manifest be defined by function
+	manifest *manifest.Manifest
+	// runtime is the WASI runtime that the function is running in.
+	runtime wazero.Runtime
+	// compiled is the compiled wasm module of the function.
+	compiled wazero.CompiledModule
+	// resources contains some services that can be used by driver self. the wasm function
+	// inability to use any service.
+	resources resource.Resources
+}
+
+// New creates a new WasmDriver instance to execute wasm functions.
+func New(fname, fpath, version string) *WasmDriver {
+	return &WasmDriver{
+		fname:   fname,
+		fpath:   fpath,
+		version: version,
+	}
+}
+
+// Load loads the wasm function from $COFUNC_HOME/wasm directory, the version of the function is resolved by
+// the version constraint, then compiles the module with the memory limit of the function.
+func (d *WasmDriver) Load(ctx context.Context, resources resource.Resources) error {
+	functionDir, _manifest, err := manifest.Resolve(config.WasmDir(), d.fpath, d.version)
+	if err != nil {
+		return fmt.Errorf("%w: wasm driver load", err)
+	}
+	if _manifest.Entrypoint == "" {
+		return fmt.Errorf("not found entrypoint in wasm function: %s", d.fname)
+	}
+	module, err := os.ReadFile(filepath.Join(functionDir, _manifest.Entrypoint))
+	if err != nil {
+		return fmt.Errorf("%w: read entrypoint module", err)
+	}
+
+	limit := _manifest.MemoryLimit
+	if limit <= 0 {
+		limit = defaultMemoryLimit
+	}
+	rtconfig := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(limit * pagesPerMiB)).
+		WithCloseOnContextDone(true)
+	rt := wazero.NewRuntimeWithConfig(ctx, rtconfig)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		rt.Close(ctx)
+		return fmt.Errorf("%w: instantiate wasi", err)
+	}
+	compiled, err := rt.CompileModule(ctx, module)
+	if err != nil {
+		rt.Close(ctx)
+		return fmt.Errorf("%w: compile wasm module", err)
+	}
+
+	d.manifest = _manifest
+	d.runtime = rt
+	d.compiled = compiled
+	d.resources = resources
+	return nil
+}
+
+// Run executes the wasm function, Please note that 'args' will be converted to environment variables, and
+// also be passed to the stdin of the function as a JSON object.
+// The stdout and stderr of the function will be written into the log writer, a line in stdout that has the
+// prefix '::return::' will be parsed as a return value, e.g. '::return::key=value'.
+func (d *WasmDriver) Run(ctx context.Context, args map[string]string) (map[string]string, error) {
+	printer, ok := d.resources.Logwriter.(resource.LogStdoutPrinter)
+	if ok {
+		defer func() {
+			printer.PrintSummary()
+			printer.Reset()
+		}()
+		printer.PrintTitle()
+	}
+	merged := d.mergeArgs(args)
+	stdin, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+
+	retValues := make(map[string]string)
+	stdout := &output.Output{
+		HandleFunc: func(line []byte) {
+			s := string(line)
+			if strings.HasPrefix(s, returnPrefix) {
+				kv := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(s, returnPrefix)), "=", 2)
+				if len(kv) == 2 {
+					retValues[kv[0]] = kv[1]
+				}
+				return
+			}
+			d.resources.Logwriter.Write(line)
+		},
+	}
+	stderr := &output.Output{
+		W: d.resources.Logwriter,
+	}
+
+	modconfig := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(d.fname).
+		WithStdin(bytes.NewReader(stdin)).
+		WithStdout(stdout).
+		WithStderr(stderr)
+	for _, env := range d.toEnv(merged) {
+		modconfig = modconfig.WithEnv(env[0], env[1])
+	}
+
+	mod, err := d.runtime.InstantiateModule(ctx, d.compiled, modconfig)
+	stdout.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := mod.Close(ctx); err != nil {
+		return nil, err
+	}
+	return retValues, nil
+}
+
+// StopAndRelease closes the WASI runtime, all compiled modules will be released.
+func (d *WasmDriver) StopAndRelease(ctx context.Context) error {
+	if d.runtime != nil {
+		return d.runtime.Close(ctx)
+	}
+	return nil
+}
+
+// FunctionName returns the name of the wasm function.
+func (d *WasmDriver) FunctionName() string {
+	return d.fname
+}
+
+// Name returns the name of the wasm driver.
+func (d *WasmDriver) Name() string {
+	return Name
+}
+
+// Manifest returns the manifest of the wasm function.
+func (d *WasmDriver) Manifest() manifest.Manifest {
+	return *d.manifest
+}
+
+func (d *WasmDriver) mergeArgs(args map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for k, v := range d.manifest.Args {
+		merged[k] = v
+	}
+	for k, v := range args {
+		merged[k] = v
+	}
+	return merged
+}
+
+func (d *WasmDriver) toEnv(args map[string]string) [][2]string {
+	var envs [][2]string
+	for k, v := range args {
+		envs = append(envs, [2]string{"COFUNC_" + strings.ToUpper(k), v})
+	}
+	return envs
+}
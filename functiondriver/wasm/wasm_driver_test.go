@@ -0,0 +1,280 @@
+package wasmdriver
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cofunclabs/cofunc/service/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+// The testing modules are assembled by hand, so that we don't need a wasm toolchain to run the testing.
+
+func leb128(n uint32) []byte {
+	var b []byte
+	for {
+		c := byte(n & 0x7f)
+		n >>= 7
+		if n != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if n == 0 {
+			return b
+		}
+	}
+}
+
+func wasmName(s string) []byte {
+	return append(leb128(uint32(len(s))), s...)
+}
+
+func wasmSection(id byte, content ...byte) []byte {
+	b := []byte{id}
+	b = append(b, leb128(uint32(len(content)))...)
+	return append(b, content...)
+}
+
+func wasmModule(sections ...[]byte) []byte {
+	b := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	for _, s := range sections {
+		b = append(b, s...)
+	}
+	return b
+}
+
+// echoModule writes the 'message' into stdout with fd_write
+func echoModule(message string) []byte {
+	var imports []byte
+	imports = append(imports, 0x01)
+	imports = append(imports, wasmName("wasi_snapshot_preview1")...)
+	imports = append(imports, wasmName("fd_write")...)
+	imports = append(imports, 0x00, 0x00)
+
+	var exports []byte
+	exports = append(exports, 0x02)
+	exports = append(exports, wasmName("memory")...)
+	exports = append(exports, 0x02, 0x00)
+	exports = append(exports, wasmName("_start")...)
+	exports = append(exports, 0x00, 0x01)
+
+	// fd_write(1, iovec=0, 1, nwritten=100)
+	body := []byte{0x00, 0x41, 0x01, 0x41, 0x00, 0x41, 0x01, 0x41, 0xe4, 0x00, 0x10, 0x00, 0x1a, 0x0b}
+	code := []byte{0x01}
+	code = append(code, leb128(uint32(len(body)))...)
+	code = append(code, body...)
+
+	// iovec{buf: 8, len: len(message)} + message
+	segment := []byte{0x08, 0x00, 0x00, 0x00}
+	segment = append(segment, byte(len(message)), 0x00, 0x00, 0x00)
+	segment = append(segment, message...)
+	data := []byte{0x01, 0x00, 0x41, 0x00, 0x0b}
+	data = append(data, leb128(uint32(len(segment)))...)
+	data = append(data, segment...)
+
+	return wasmModule(
+		wasmSection(0x01, 0x02, 0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f, 0x60, 0x00, 0x00),
+		wasmSection(0x02, imports...),
+		wasmSection(0x03, 0x01, 0x01),
+		wasmSection(0x05, 0x01, 0x00, 0x01),
+		wasmSection(0x07, exports...),
+		wasmSection(0x0a, code...),
+		wasmSection(0x0b, data...),
+	)
+}
+
+func sleb128(n int32) []byte {
+	var b []byte
+	for {
+		c := byte(n & 0x7f)
+		n >>= 7
+		if (n == 0 && c&0x40 == 0) || (n == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+// splitModule writes every part into stdout with fd_write, all parts are stored into the same buffer of the
+// memory, so the next part overwrites the previous one
+func splitModule(parts ...string) []byte {
+	var imports []byte
+	imports = append(imports, 0x01)
+	imports = append(imports, wasmName("wasi_snapshot_preview1")...)
+	imports = append(imports, wasmName("fd_write")...)
+	imports = append(imports, 0x00, 0x00)
+
+	var exports []byte
+	exports = append(exports, 0x02)
+	exports = append(exports, wasmName("memory")...)
+	exports = append(exports, 0x02, 0x00)
+	exports = append(exports, wasmName("_start")...)
+	exports = append(exports, 0x00, 0x01)
+
+	body := []byte{0x00}
+	for _, part := range parts {
+		// i32.store8(8+i, part[i])
+		for i := 0; i < len(part); i++ {
+			body = append(body, 0x41)
+			body = append(body, sleb128(int32(8+i))...)
+			body = append(body, 0x41)
+			body = append(body, sleb128(int32(part[i]))...)
+			body = append(body, 0x3a, 0x00, 0x00)
+		}
+		// i32.store(4, len(part)), the length of the iovec
+		body = append(body, 0x41, 0x04, 0x41)
+		body = append(body, sleb128(int32(len(part)))...)
+		body = append(body, 0x36, 0x02, 0x00)
+		// fd_write(1, iovec=0, 1, nwritten=100)
+		body = append(body, 0x41, 0x01, 0x41, 0x00, 0x41, 0x01, 0x41, 0xe4, 0x00, 0x10, 0x00, 0x1a)
+	}
+	body = append(body, 0x0b)
+	code := []byte{0x01}
+	code = append(code, leb128(uint32(len(body)))...)
+	code = append(code, body...)
+
+	// iovec{buf: 8}
+	data := []byte{0x01, 0x00, 0x41, 0x00, 0x0b, 0x04, 0x08, 0x00, 0x00, 0x00}
+
+	return wasmModule(
+		wasmSection(0x01, 0x02, 0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f, 0x60, 0x00, 0x00),
+		wasmSection(0x02, imports...),
+		wasmSection(0x03, 0x01, 0x01),
+		wasmSection(0x05, 0x01, 0x00, 0x01),
+		wasmSection(0x07, exports...),
+		wasmSection(0x0a, code...),
+		wasmSection(0x0b, data...),
+	)
+}
+
+// loopModule runs an infinite loop
+func loopModule(pages byte) []byte {
+	var exports []byte
+	exports = append(exports, 0x01)
+	exports = append(exports, wasmName("_start")...)
+	exports = append(exports, 0x00, 0x00)
+
+	body := []byte{0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b}
+	code := []byte{0x01}
+	code = append(code, leb128(uint32(len(body)))...)
+	code = append(code, body...)
+
+	return wasmModule(
+		wasmSection(0x01, 0x01, 0x60, 0x00, 0x00),
+		wasmSection(0x03, 0x01, 0x00),
+		wasmSection(0x05, 0x01, 0x00, pages),
+		wasmSection(0x07, exports...),
+		wasmSection(0x0a, code...),
+	)
+}
+
+func installModule(t *testing.T, home, name string, module []byte, memoryLimit string) {
+	dir := filepath.Join(home, "wasm", name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	mf := `{"name": "` + name + `", "driver": "wasm", "entrypoint": "main.wasm"` + memoryLimit + `}`
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(mf), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.wasm"), module, 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+}
+
+func TestWasmDriver(t *testing.T) {
+	home := t.TempDir()
+	os.Setenv("COFUNC_HOME", home)
+	defer os.Unsetenv("COFUNC_HOME")
+	installModule(t, home, "echo", echoModule("testing wasm driver\n::return::status=ok\n"), "")
+
+	var buf bytes.Buffer
+	ctx := context.Background()
+
+	driver := New("echo", "echo", "latest")
+	if err := driver.Load(ctx, resource.Resources{
+		Logwriter: &buf,
+	}); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer driver.StopAndRelease(ctx)
+
+	rets, err := driver.Run(ctx, map[string]string{"message": "hello"})
+	assert.NoError(t, err)
+	assert.Equal(t, "testing wasm driver", strings.TrimSpace(buf.String()))
+	assert.Equal(t, "ok", rets["status"])
+
+	// run again with a new instance of the module
+	buf.Reset()
+	_, err = driver.Run(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "testing wasm driver", strings.TrimSpace(buf.String()))
+}
+
+func TestWasmDriverSplitLine(t *testing.T) {
+	home := t.TempDir()
+	os.Setenv("COFUNC_HOME", home)
+	defer os.Unsetenv("COFUNC_HOME")
+	// The lines are split across the writes, and the guest reuses the buffer of every write
+	installModule(t, home, "split", splitModule("testing ", "wasm driver\n::return::sta", "tus=ok\n"), "")
+
+	var buf bytes.Buffer
+	ctx := context.Background()
+
+	driver := New("split", "split", "latest")
+	if err := driver.Load(ctx, resource.Resources{
+		Logwriter: &buf,
+	}); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer driver.StopAndRelease(ctx)
+
+	rets, err := driver.Run(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "testing wasm driver", strings.TrimSpace(buf.String()))
+	assert.Equal(t, "ok", rets["status"])
+}
+
+func TestWasmDriverCancel(t *testing.T) {
+	home := t.TempDir()
+	os.Setenv("COFUNC_HOME", home)
+	defer os.Unsetenv("COFUNC_HOME")
+	installModule(t, home, "loop", loopModule(1), "")
+
+	driver := New("loop", "loop", "latest")
+	if err := driver.Load(context.Background(), resource.Resources{
+		Logwriter: os.Stdout,
+	}); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer driver.StopAndRelease(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err := driver.Run(ctx, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWasmDriverMemoryLimit(t *testing.T) {
+	home := t.TempDir()
+	os.Setenv("COFUNC_HOME", home)
+	defer os.Unsetenv("COFUNC_HOME")
+	// 32 pages is 2MiB, but the limit is 1MiB
+	installModule(t, home, "bigmem", loopModule(32), `, "memory_limit": 1`)
+
+	ctx := context.Background()
+	driver := New("bigmem", "bigmem", "latest")
+	err := driver.Load(ctx, resource.Resources{
+		Logwriter: os.Stdout,
+	})
+	if err == nil {
+		defer driver.StopAndRelease(ctx)
+		_, err = driver.Run(ctx, nil)
+	}
+	assert.Error(t, err)
+}
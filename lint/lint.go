@@ -0,0 +1,339 @@
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cofunclabs/cofunc/config"
+	"github.com/cofunclabs/cofunc/functiondriver"
+	godriver "github.com/cofunclabs/cofunc/functiondriver/go"
+	jsdriver "github.com/cofunclabs/cofunc/functiondriver/js"
+	shelldriver "github.com/cofunclabs/cofunc/functiondriver/shell"
+	wasmdriver "github.com/cofunclabs/cofunc/functiondriver/wasm"
+	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/parser"
+	"github.com/cofunclabs/cofunc/std"
+)
+
+// The rules of the linter
+const (
+	RuleUnusedLoad       = "unused-load"
+	RuleUnusedFn         = "unused-fn"
+	RuleUnusedVar        = "unused-var"
+	RuleUnusedReturn     = "unused-return"
+	RuleShadowedFunction = "shadowed-function"
+	RuleUnreachableCase  = "unreachable-case"
+	RuleUnknownArg       = "unknown-arg"
+	// RuleSyntax is the errors of parsing
+	RuleSyntax = "syntax"
+)
+
+// Issue is a problem found by the linter
+type Issue struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Col     int    `json:"col,omitempty"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// String returns the issue in the format 'file:line: message' or 'file:line:col: message'
+func (i Issue) String() string {
+	if i.Col != 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", i.File, i.Line, i.Col, i.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Message)
+}
+
+// ManifestLookup returns the manifest of a loaded function, it returns nil if the manifest is unknown, then
+// the arguments of the function aren't checked.
+type ManifestLookup func(loc functiondriver.Location) *manifest.Manifest
+
+// functionDirs are the directories that the manifests of the installed functions are read from.
+var functionDirs = map[string]func() string{
+	shelldriver.Name: config.ShellDir,
+	jsdriver.Name:    config.JsDir,
+	wasmdriver.Name:  config.WasmDir,
+}
+
+// ReadManifest returns a ManifestLookup that reads the manifest of the function without loading it by the driver,
+// so the linter doesn't fetch, compile or start anything. The manifests of the http functions are fetched from
+// the remote endpoints and the nested flows have no manifest, they are unknown.
+func ReadManifest() ManifestLookup {
+	return func(loc functiondriver.Location) *manifest.Manifest {
+		if loc.DriverName == godriver.Name {
+			mf, _, _ := std.Lookup(loc.FuncName)
+			if mf == nil {
+				return nil
+			}
+			copied := *mf
+			return &copied
+		}
+		dir, ok := functionDirs[loc.DriverName]
+		if !ok {
+			return nil
+		}
+		_, mf, err := manifest.Resolve(dir(), loc.FuncPath, loc.Version)
+		if err != nil {
+			return nil
+		}
+		return mf
+	}
+}
+
+// Lint parses the flowl source and checks it, the errors of parsing are reported as the issues of 'RuleSyntax',
+// then the source isn't checked.
+func Lint(file string, rd io.Reader, lookup ManifestLookup) ([]Issue, error) {
+	ast, err := parser.New(rd, parser.WithFile(file))
+	if err != nil {
+		var list parser.ErrorList
+		if !errors.As(err, &list) {
+			return nil, err
+		}
+		var issues []Issue
+		for _, e := range list {
+			issues = append(issues, Issue{
+				File:    file,
+				Line:    e.Line,
+				Col:     e.Col,
+				Rule:    RuleSyntax,
+				Message: e.Err.Error(),
+			})
+		}
+		return issues, nil
+	}
+	return Check(file, ast, lookup), nil
+}
+
+// Check checks the AST of a flow, the issues are sorted by the line number.
+func Check(file string, ast *parser.AST, lookup ManifestLookup) []Issue {
+	l := &linter{
+		file:     file,
+		lookup:   lookup,
+		loads:    make(map[string]*parser.Block),
+		fns:      make(map[string]*parser.Block),
+		imported: make(map[string]bool),
+	}
+	ast.Foreach(func(b *parser.Block) error {
+		if b.IsImport() || b.InImport() {
+			// the imported files are checked separately, only their fns are used to resolve the function names
+			if b.IsFn() {
+				l.fns[b.Target1().String()] = b
+				l.imported[b.Target1().String()] = true
+			}
+			return nil
+		}
+		switch {
+		case b.IsLoad():
+			loc := functiondriver.NewLocation(b.Target1().String())
+			l.loads[loc.FuncName] = b
+		case b.IsFn():
+			l.fns[b.Target1().String()] = b
+		case b.IsCo():
+			l.cos = append(l.cos, b)
+		case b.IsSwitch():
+			l.switches = append(l.switches, b)
+		}
+		for _, decl := range b.VarDecls() {
+			l.decls = append(l.decls, declaration{b, decl})
+		}
+		return nil
+	})
+
+	l.checkFunctions()
+	l.checkVars(ast.VarRefs())
+	l.checkSwitches()
+	l.checkArgs()
+
+	sort.Slice(l.issues, func(i, j int) bool {
+		if l.issues[i].Line != l.issues[j].Line {
+			return l.issues[i].Line < l.issues[j].Line
+		}
+		return l.issues[i].Message < l.issues[j].Message
+	})
+	return l.issues
+}
+
+type declaration struct {
+	scope *parser.Block
+	parser.VarDecl
+}
+
+type linter struct {
+	file   string
+	lookup ManifestLookup
+	issues []Issue
+
+	// loads stores the 'load' blocks, the key is the function name
+	loads map[string]*parser.Block
+	// fns stores the 'fn' blocks, the key is the name of fn
+	fns map[string]*parser.Block
+	// imported stores the names of fns that defined in the imported files
+	imported map[string]bool
+	cos      []*parser.Block
+	switches []*parser.Block
+	decls    []declaration
+}
+
+func (l *linter) report(line int, rule, format string, args ...interface{}) {
+	l.issues = append(l.issues, Issue{
+		File:    l.file,
+		Line:    line,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// coNames returns the names of functions or fns that called by the 'co' block
+func coNames(b *parser.Block) []string {
+	if !b.Target1().IsEmpty() {
+		return []string{b.Target1().String()}
+	}
+	if list, ok := b.Body().(*parser.ListBody); ok {
+		// the names are identifiers, they have no variables to calculate
+		names, _ := list.ToSlice()
+		return names
+	}
+	return nil
+}
+
+// function returns the function name that called by the 'co' name, the name may be a fn or a function
+func (l *linter) function(name string) string {
+	if fn, ok := l.fns[name]; ok {
+		return fn.Target2().String()
+	}
+	return name
+}
+
+func (l *linter) checkFunctions() {
+	usedFns := make(map[string]bool)
+	usedFunctions := make(map[string]bool)
+	for _, co := range l.cos {
+		for _, name := range coNames(co) {
+			if _, ok := l.fns[name]; ok {
+				usedFns[name] = true
+			}
+			usedFunctions[l.function(name)] = true
+		}
+	}
+
+	for name, fn := range l.fns {
+		if l.imported[name] {
+			continue
+		}
+		if !usedFns[name] {
+			l.report(fn.Line(), RuleUnusedFn, "fn '%s' is never used by co", name)
+		}
+		if _, ok := l.loads[name]; ok && name != fn.Target2().String() {
+			l.report(fn.Line(), RuleShadowedFunction, "fn '%s' shadows the loaded function '%s'", name, name)
+		}
+	}
+	for name, load := range l.loads {
+		if !usedFunctions[name] {
+			l.report(load.Line(), RuleUnusedLoad, "function '%s' is loaded but never used", load.Target1().String())
+		}
+	}
+}
+
+func (l *linter) checkVars(refs []parser.VarRef) {
+	type key struct {
+		scope *parser.Block
+		name  string
+	}
+	read := make(map[key]bool)
+	for _, ref := range refs {
+		read[key{ref.Scope, ref.Var}] = true
+	}
+
+	returns := make(map[key]bool)
+	for _, co := range l.cos {
+		name := co.Target2().String()
+		if name == "" {
+			continue
+		}
+		k := key{co.VarScope(name), name}
+		returns[k] = true
+		if !read[k] {
+			l.report(co.Line(), RuleUnusedReturn, "return variable '%s' is never consumed", name)
+		}
+	}
+	for _, decl := range l.decls {
+		k := key{decl.scope, decl.Name}
+		if !read[k] && !returns[k] {
+			l.report(decl.Line, RuleUnusedVar, "variable '%s' is declared but never read", decl.Name)
+		}
+	}
+}
+
+func (l *linter) checkSwitches() {
+	for _, sw := range l.switches {
+		var hasDefault bool
+		for _, c := range sw.Child() {
+			if c.IsDefault() {
+				hasDefault = true
+				continue
+			}
+			if c.IsCase() && hasDefault {
+				l.report(c.Line(), RuleUnreachableCase, "unreachable case after default")
+			}
+		}
+	}
+}
+
+func (l *linter) checkArgs() {
+	if l.lookup == nil {
+		return
+	}
+	manifests := make(map[string]*manifest.Manifest)
+	getManifest := func(fname string) *manifest.Manifest {
+		if mf, ok := manifests[fname]; ok {
+			return mf
+		}
+		var mf *manifest.Manifest
+		if load, ok := l.loads[fname]; ok {
+			mf = l.lookup(functiondriver.NewLocation(load.Target1().String()))
+		}
+		manifests[fname] = mf
+		return mf
+	}
+	check := func(fname string, args *parser.MapBody) {
+		mf := getManifest(fname)
+		if mf == nil || len(mf.Usage.Args) == 0 {
+			// the arguments aren't described
+			return
+		}
+		known := make(map[string]bool)
+		for _, desc := range mf.Usage.Args {
+			known[desc.Name] = true
+		}
+		for k := range mf.Args {
+			known[k] = true
+		}
+		for _, k := range args.Keys() {
+			// the keys start with '_' are reserved
+			if k.HasVar() || strings.HasPrefix(k.String(), "_") || known[k.String()] {
+				continue
+			}
+			l.report(k.Line(), RuleUnknownArg, "unknown argument '%s' of function '%s'", k.String(), fname)
+		}
+	}
+
+	for _, co := range l.cos {
+		if args, ok := co.Body().(*parser.MapBody); ok {
+			check(l.function(co.Target1().String()), args)
+		}
+	}
+	for name, fn := range l.fns {
+		if l.imported[name] {
+			continue
+		}
+		for _, c := range fn.Child() {
+			if args, ok := c.Body().(*parser.MapBody); ok && c.IsArgs() {
+				check(fn.Target2().String(), args)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cofunclabs/cofunc/functiondriver"
+	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	const testingdata string = `
+load "go:print"
+load "go:time"
+load "go:sleep"
+load "go:command"
+
+var unused = "1"
+var counter = 0
+var out
+var ignored
+
+fn sleep = command {
+}
+
+fn p = print {
+	args = {
+		"_": "$(counter)"
+	}
+}
+
+fn t = time {
+	args = {
+		"formt": "YYYY"
+	}
+}
+
+co p
+co time -> out {
+	"format": "YYYY-MM-DD hh:mm:ss"
+	"timestamp": "true"
+}
+co print -> ignored
+switch {
+	default {
+		co print
+	}
+	case $(counter) > 1 {
+		co print
+	}
+}
+	`
+	lookup := func(loc functiondriver.Location) *manifest.Manifest {
+		if loc.FuncName != "time" {
+			return nil
+		}
+		return &manifest.Manifest{
+			Name: "time",
+			Usage: manifest.Usage{
+				Args: []manifest.UsageDesc{{Name: "format"}, {Name: "get_timestamp"}},
+			},
+		}
+	}
+	issues, err := Lint("testing.flowl", strings.NewReader(testingdata), lookup)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	var lines []string
+	for _, issue := range issues {
+		lines = append(lines, issue.String())
+	}
+	assert.Equal(t, []string{
+		"testing.flowl:4: function 'go:sleep' is loaded but never used",
+		"testing.flowl:5: function 'go:command' is loaded but never used",
+		"testing.flowl:7: variable 'unused' is declared but never read",
+		"testing.flowl:12: fn 'sleep' is never used by co",
+		"testing.flowl:12: fn 'sleep' shadows the loaded function 'sleep'",
+		"testing.flowl:21: fn 't' is never used by co",
+		"testing.flowl:23: unknown argument 'formt' of function 'time'",
+		"testing.flowl:28: return variable 'out' is never consumed",
+		"testing.flowl:30: unknown argument 'timestamp' of function 'time'",
+		"testing.flowl:32: return variable 'ignored' is never consumed",
+		"testing.flowl:37: unreachable case after default",
+	}, lines)
+}
+
+func TestReadManifest(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("COFUNC_HOME", home)
+	// The entrypoint doesn't exist, the manifest is read without loading the function
+	dir := filepath.Join(home, "shell", "echo@1.0.0")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	mf := `{"name": "echo", "driver": "shell", "entrypoint": "echo.sh"}`
+	if err := os.WriteFile(filepath.Join(dir, manifest.FileName), []byte(mf), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	lookup := ReadManifest()
+	if m := lookup(functiondriver.NewLocation("shell:echo@^1.0")); assert.NotNil(t, m) {
+		assert.Equal(t, "echo", m.Name)
+		assert.Equal(t, "1.0.0", m.Version)
+	}
+	if m := lookup(functiondriver.NewLocation("go:print")); assert.NotNil(t, m) {
+		assert.Equal(t, "print", m.Name)
+	}
+	assert.Nil(t, lookup(functiondriver.NewLocation("shell:echo@^2.0")))
+	assert.Nil(t, lookup(functiondriver.NewLocation("http:echo")))
+	assert.Nil(t, lookup(functiondriver.NewLocation("flow:echo")))
+}
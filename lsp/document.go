@@ -0,0 +1,374 @@
+package lsp
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cofunclabs/cofunc/functiondriver"
+	"github.com/cofunclabs/cofunc/lint"
+	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/parser"
+)
+
+// document is a flowl source that opened in the editor
+type document struct {
+	uri string
+	// encoding is the position encoding negotiated with the client
+	encoding string
+	lines    []string
+	// idx is built from the last AST that parsed successfully, it's kept when the source has syntax errors,
+	// so the completion and navigation still work while editing.
+	idx         *index
+	diagnostics []Diagnostic
+}
+
+func newDocument(uri, encoding string) *document {
+	return &document{
+		uri:      uri,
+		encoding: encoding,
+		idx:      newIndex(nil),
+	}
+}
+
+// update parses the new text of the document, then checks it by the linter if parsed successfully.
+func (d *document) update(text string, lookup lint.ManifestLookup) {
+	d.lines = strings.Split(text, "\n")
+	ast, err := parser.New(strings.NewReader(text), parser.WithFile(uriPath(d.uri)))
+	if err != nil {
+		d.diagnostics = d.errorDiagnostics(err)
+		return
+	}
+	d.idx = newIndex(ast)
+	d.diagnostics = []Diagnostic{}
+	for _, issue := range lint.Check(d.uri, ast, lookup) {
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Range:    d.toClient(d.lineRange(issue.Line)),
+			Severity: SeverityWarning,
+			Code:     issue.Rule,
+			Source:   "cofunc",
+			Message:  issue.Message,
+		})
+	}
+}
+
+func (d *document) errorDiagnostics(err error) []Diagnostic {
+	var list parser.ErrorList
+	if !errors.As(err, &list) {
+		list = parser.ErrorList{&parser.Error{Line: 1, Err: err}}
+	}
+	diagnostics := []Diagnostic{}
+	for _, e := range list {
+		rng := d.lineRange(e.Line)
+		if e.Col > 0 && e.Col-1 < rng.End.Character {
+			rng.Start.Character = e.Col - 1
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.toClient(rng),
+			Severity: SeverityError,
+			Source:   "cofunc",
+			Message:  e.Err.Error(),
+		})
+	}
+	return diagnostics
+}
+
+// uriPath returns the file path of the 'file' URI, the relative imports are resolved from its directory, it returns
+// empty for the other schemes.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return u.Path
+}
+
+// line returns the text of the line, the 'ln' is one-based
+func (d *document) line(ln int) string {
+	if ln < 1 || ln > len(d.lines) {
+		return ""
+	}
+	return strings.TrimRight(d.lines[ln-1], "\r")
+}
+
+// fromClient converts the character of the position from the code units of the client to the byte offset
+func (d *document) fromClient(pos Position) Position {
+	if d.encoding == PositionEncodingUTF8 {
+		return pos
+	}
+	text := d.line(pos.Line + 1)
+	units := 0
+	for i, r := range text {
+		if units >= pos.Character {
+			pos.Character = i
+			return pos
+		}
+		units += utf16Units(r)
+	}
+	pos.Character = len(text)
+	return pos
+}
+
+// toClient converts the characters of the range from the byte offsets to the code units of the client
+func (d *document) toClient(rng Range) Range {
+	if d.encoding == PositionEncodingUTF8 {
+		return rng
+	}
+	convert := func(pos Position) Position {
+		text := d.line(pos.Line + 1)
+		if pos.Character > len(text) {
+			pos.Character = len(text)
+		}
+		units := 0
+		for _, r := range text[:pos.Character] {
+			units += utf16Units(r)
+		}
+		pos.Character = units
+		return pos
+	}
+	return Range{Start: convert(rng.Start), End: convert(rng.End)}
+}
+
+// utf16Units returns the number of UTF-16 code units of the rune, the runes outside the BMP are encoded as
+// surrogate pairs
+func utf16Units(r rune) int {
+	if r > 0xFFFF && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
+
+// lineRange returns the range of the whole line, the 'ln' is one-based
+func (d *document) lineRange(ln int) Range {
+	if ln < 1 {
+		ln = 1
+	}
+	return Range{
+		Start: Position{Line: ln - 1},
+		End:   Position{Line: ln - 1, Character: len(d.line(ln))},
+	}
+}
+
+// wordRange returns the range of the first occurrence of the word in the line, it returns the range of the
+// whole line if not found.
+func (d *document) wordRange(ln int, word string) Range {
+	text := d.line(ln)
+	for start := 0; start < len(text); {
+		i := strings.Index(text[start:], word)
+		if i == -1 {
+			break
+		}
+		i += start
+		end := i + len(word)
+		if (i == 0 || !isWordChar(text[i-1])) && (end == len(text) || !isWordChar(text[end])) {
+			return Range{
+				Start: Position{Line: ln - 1, Character: i},
+				End:   Position{Line: ln - 1, Character: end},
+			}
+		}
+		start = end
+	}
+	return d.lineRange(ln)
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// The kinds of the word at a position
+const (
+	wordIdent = iota
+	// wordVar is a variable in '$()'
+	wordVar
+	// wordField is a field of variable in '$()', e.g. 'now' in '$(out.now)'
+	wordField
+	// wordArg is a key of args, e.g. 'msg' in '"msg": "hello"'
+	wordArg
+)
+
+type word struct {
+	kind int
+	text string
+	// v is the variable of the field
+	v   string
+	rng Range
+}
+
+// wordAt returns the word at the position, it returns nil if there is no word.
+func (d *document) wordAt(pos Position) *word {
+	text := d.line(pos.Line + 1)
+	if pos.Character > len(text) {
+		return nil
+	}
+	start, end := pos.Character, pos.Character
+	for start > 0 && isWordChar(text[start-1]) {
+		start--
+	}
+	for end < len(text) && isWordChar(text[end]) {
+		end++
+	}
+	if start == end {
+		return nil
+	}
+	w := &word{
+		kind: wordIdent,
+		text: text[start:end],
+		rng: Range{
+			Start: Position{Line: pos.Line, Character: start},
+			End:   Position{Line: pos.Line, Character: end},
+		},
+	}
+	before, after := text[:start], text[end:]
+	switch {
+	case strings.HasSuffix(before, "$("):
+		w.kind = wordVar
+		if v, field, ok := strings.Cut(w.text, "."); ok {
+			if pos.Character > start+len(v) {
+				w.kind, w.v, w.text = wordField, v, field
+				w.rng.Start.Character = start + len(v) + 1
+			} else {
+				w.text = v
+				w.rng.End.Character = start + len(v)
+			}
+		}
+	case strings.TrimSpace(before) == `"` && strings.HasPrefix(after, `"`) && strings.HasPrefix(strings.TrimSpace(after[1:]), ":"):
+		w.kind = wordArg
+	}
+	return w
+}
+
+// The patterns of the lines that open blocks, the lines may end with a comment
+var (
+	coOpenPattern   = regexp.MustCompile(`^\s*co\s+([a-zA-Z0-9_]+)(\s*->\s*[a-zA-Z0-9_]+)?\s*{\s*(//.*|/\*.*)?$`)
+	fnOpenPattern   = regexp.MustCompile(`^\s*fn\s+[a-zA-Z0-9_]+\s*=\s*([a-zA-Z0-9_]+)\s*{\s*(//.*|/\*.*)?$`)
+	argsOpenPattern = regexp.MustCompile(`^\s*args\s*=\s*{\s*(//.*|/\*.*)?$`)
+)
+
+// enclosingFunction returns the name of function whose arguments are at the line, it returns empty if the
+// line isn't in the arguments. It's resolved by the text, because the source may not be parsed while editing.
+func (d *document) enclosingFunction(ln int) string {
+	var (
+		depth  int
+		inArgs bool
+	)
+	for i := ln - 1; i >= 1; i-- {
+		text := d.line(i)
+		depth += strings.Count(text, "}") - strings.Count(text, "{")
+		if depth >= 0 {
+			continue
+		}
+		switch {
+		case argsOpenPattern.MatchString(text) && !inArgs:
+			inArgs = true
+			depth = 0
+		case inArgs:
+			if m := fnOpenPattern.FindStringSubmatch(text); m != nil {
+				return m[1]
+			}
+			return ""
+		default:
+			if m := coOpenPattern.FindStringSubmatch(text); m != nil {
+				return d.idx.function(m[1])
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// index stores the definitions in the AST
+type index struct {
+	// loads stores the 'load' blocks, the key is the function name
+	loads map[string]*parser.Block
+	// fns stores the 'fn' blocks, the key is the name of fn
+	fns   map[string]*parser.Block
+	decls map[string][]parser.VarDecl
+	// returns stores the function that returns to the variable, the key is the variable name
+	returns map[string]string
+	names   []string
+}
+
+func newIndex(ast *parser.AST) *index {
+	x := &index{
+		loads:   make(map[string]*parser.Block),
+		fns:     make(map[string]*parser.Block),
+		decls:   make(map[string][]parser.VarDecl),
+		returns: make(map[string]string),
+	}
+	if ast == nil {
+		return x
+	}
+	ast.Foreach(func(b *parser.Block) error {
+		switch {
+		case b.IsLoad():
+			loc := functiondriver.NewLocation(b.Target1().String())
+			x.loads[loc.FuncName] = b
+		case b.IsFn():
+			x.fns[b.Target1().String()] = b
+		}
+		if b.IsImport() || b.InImport() {
+			// the variables of the imported files are accessed by '$(namespace.name)'
+			return nil
+		}
+		for _, decl := range b.VarDecls() {
+			if _, ok := x.decls[decl.Name]; !ok {
+				x.names = append(x.names, decl.Name)
+			}
+			x.decls[decl.Name] = append(x.decls[decl.Name], decl)
+		}
+		return nil
+	})
+	ast.Foreach(func(b *parser.Block) error {
+		if b.IsCo() && !b.Target2().IsEmpty() {
+			x.returns[b.Target2().String()] = x.function(b.Target1().String())
+		}
+		return nil
+	})
+	return x
+}
+
+// importOf returns the 'import' block in the document that imports the block, it returns nil if the block is
+// defined in the document.
+func importOf(b *parser.Block) *parser.Block {
+	var imp *parser.Block
+	for p := b.Parent(); p != nil; p = p.Parent() {
+		if p.IsImport() {
+			imp = p
+		}
+	}
+	return imp
+}
+
+// function returns the function name of the name, the name may be a fn or a function
+func (x *index) function(name string) string {
+	if fn, ok := x.fns[name]; ok {
+		return fn.Target2().String()
+	}
+	return name
+}
+
+// manifest returns the manifest of the function, the name may be a fn or a function
+func (x *index) manifest(name string, lookup lint.ManifestLookup) *manifest.Manifest {
+	load, ok := x.loads[x.function(name)]
+	if !ok || lookup == nil {
+		return nil
+	}
+	return lookup(functiondriver.NewLocation(load.Target1().String()))
+}
+
+// declaration returns the nearest declaration of the variable before the line
+func (x *index) declaration(name string, ln int) (parser.VarDecl, bool) {
+	decls, ok := x.decls[name]
+	if !ok {
+		return parser.VarDecl{}, false
+	}
+	decl := decls[0]
+	for _, d := range decls[1:] {
+		if d.Line <= ln {
+			decl = d
+		}
+	}
+	return decl, true
+}
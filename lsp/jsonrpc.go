@@ -0,0 +1,145 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The error codes defined by JSON-RPC and LSP
+const (
+	codeParseError     = -32700
+	codeInvalidParams  = -32602
+	codeMethodNotFound = -32601
+	codeInvalidRequest = -32600
+)
+
+var ErrHeaderIllegal = errors.New("header illegal")
+
+// request is a request or a notification from the client, the notification has no id.
+type request struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+func (r *request) isNotification() bool {
+	return r.ID == nil
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type response struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	// Result is 'null' rather than omitted when the result is nil, but it's omitted when there is an error.
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *responseError  `json:"error,omitempty"`
+}
+
+type notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// conn reads and writes the messages that framed by the 'Content-Length' header
+type conn struct {
+	rd *bufio.Reader
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newConn(rd io.Reader, w io.Writer) *conn {
+	return &conn{
+		rd: bufio.NewReader(rd),
+		w:  w,
+	}
+}
+
+func (c *conn) read() ([]byte, error) {
+	length := -1
+	for {
+		line, err := c.rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: '%s'", ErrHeaderIllegal, line)
+		}
+		if strings.EqualFold(strings.TrimSpace(k), "Content-Length") {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("%w: '%s'", ErrHeaderIllegal, line)
+			}
+			length = n
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("%w: missing 'Content-Length'", ErrHeaderIllegal)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.rd, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (c *conn) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
+
+func (c *conn) reply(id *json.RawMessage, result interface{}) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return c.replyError(id, codeInvalidRequest, err.Error())
+	}
+	return c.write(&response{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result:  data,
+	})
+}
+
+func (c *conn) replyError(id *json.RawMessage, code int, message string) error {
+	return c.write(&response{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: &responseError{
+			Code:    code,
+			Message: message,
+		},
+	})
+}
+
+func (c *conn) notify(method string, params interface{}) error {
+	return c.write(&notification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+}
@@ -0,0 +1,480 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cofunclabs/cofunc/functiondriver"
+	"github.com/cofunclabs/cofunc/lint"
+	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/parser"
+	"github.com/cofunclabs/cofunc/std"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrMethodNotFound = errors.New("method not found")
+	ErrInvalidParams  = errors.New("invalid params")
+	ErrShutdown       = errors.New("server is shutdown")
+)
+
+// Server is the language server of flowl, it talks with the editor by the JSON-RPC over a stream, e.g. stdio.
+// The documents are parsed and checked by the linter on every change, the problems are published as diagnostics.
+type Server struct {
+	conn   *conn
+	docs   map[string]*document
+	lookup lint.ManifestLookup
+	// encoding is the position encoding negotiated in 'initialize'
+	encoding string
+	shutdown bool
+}
+
+// New creates a language server, the 'lookup' is used to get the manifests of the loaded functions, the
+// manifests are cached until the server exits.
+func New(lookup lint.ManifestLookup) *Server {
+	return &Server{
+		docs:     make(map[string]*document),
+		lookup:   cacheLookup(lookup),
+		encoding: PositionEncodingUTF16,
+	}
+}
+
+func cacheLookup(lookup lint.ManifestLookup) lint.ManifestLookup {
+	if lookup == nil {
+		return nil
+	}
+	cache := make(map[string]*manifest.Manifest)
+	return func(loc functiondriver.Location) *manifest.Manifest {
+		if mf, ok := cache[loc.String()]; ok {
+			return mf
+		}
+		mf := lookup(loc)
+		cache[loc.String()] = mf
+		return mf
+	}
+}
+
+// Serve reads the messages from 'rd' and writes the responses to 'w' until the 'exit' notification is received
+// or the 'rd' is closed.
+func (s *Server) Serve(ctx context.Context, rd io.Reader, w io.Writer) error {
+	s.conn = newConn(rd, w)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			if err := s.conn.replyError(nil, codeParseError, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(ctx, &req); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(ctx context.Context, req *request) error {
+	result, err := s.dispatch(ctx, req)
+	if req.isNotification() {
+		if err != nil && !errors.Is(err, ErrMethodNotFound) {
+			logrus.Warnf("lsp: %s: %s", req.Method, err)
+		}
+		return nil
+	}
+	if err != nil {
+		code := codeInvalidRequest
+		switch {
+		case errors.Is(err, ErrMethodNotFound):
+			code = codeMethodNotFound
+		case errors.Is(err, ErrInvalidParams):
+			code = codeInvalidParams
+		}
+		return s.conn.replyError(req.ID, code, err.Error())
+	}
+	return s.conn.reply(req.ID, result)
+}
+
+func (s *Server) dispatch(ctx context.Context, req *request) (interface{}, error) {
+	if s.shutdown {
+		return nil, ErrShutdown
+	}
+	switch req.Method {
+	case "initialize":
+		var params InitializeParams
+		if err := decode(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.initialize(params)
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := decode(req.Params, &params); err != nil {
+			return nil, err
+		}
+		doc := newDocument(params.TextDocument.URI, s.encoding)
+		s.docs[doc.uri] = doc
+		return nil, s.update(doc, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := decode(req.Params, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n != 0 {
+			return nil, s.update(doc, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := decode(req.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+	case "textDocument/completion":
+		return withPosition(s, req, s.completion)
+	case "textDocument/hover":
+		return withPosition(s, req, s.hover)
+	case "textDocument/definition":
+		return withPosition(s, req, s.definition)
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := decode(req.Params, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.symbols(doc), nil
+	}
+	return nil, fmt.Errorf("%w: '%s'", ErrMethodNotFound, req.Method)
+}
+
+func withPosition[T any](s *Server, req *request, do func(*document, Position) T) (interface{}, error) {
+	var params TextDocumentPositionParams
+	if err := decode(req.Params, &params); err != nil {
+		return nil, err
+	}
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return do(doc, doc.fromClient(params.Position)), nil
+}
+
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidParams, err)
+	}
+	return nil
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, fmt.Errorf("%w: document '%s' isn't opened", ErrInvalidParams, uri)
+	}
+	return doc, nil
+}
+
+func (s *Server) update(doc *document, text string) error {
+	doc.update(text, s.lookup)
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Diagnostics: doc.diagnostics,
+	})
+}
+
+// initialize negotiates the position encoding, the columns of flowl source are byte offsets, so 'utf-8' is
+// preferred if the client supports it, otherwise the positions are converted from and to 'utf-16'.
+func (s *Server) initialize(params InitializeParams) (interface{}, error) {
+	s.encoding = PositionEncodingUTF16
+	for _, encoding := range params.Capabilities.General.PositionEncodings {
+		if encoding == PositionEncodingUTF8 {
+			s.encoding = PositionEncodingUTF8
+		}
+	}
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			PositionEncoding: s.encoding,
+			TextDocumentSync: textDocumentSyncFull,
+			CompletionProvider: &CompletionOptions{
+				TriggerCharacters: []string{"$", "(", ".", `"`},
+			},
+			HoverProvider:          true,
+			DefinitionProvider:     true,
+			DocumentSymbolProvider: true,
+		},
+		ServerInfo: ServerInfo{
+			Name: "cofunc",
+		},
+	}, nil
+}
+
+var (
+	fieldPrefixPattern = regexp.MustCompile(`\$\(([a-zA-Z0-9_]+)\.[a-zA-Z0-9_]*$`)
+	varPrefixPattern   = regexp.MustCompile(`\$\([a-zA-Z0-9_]*$`)
+	loadPrefixPattern  = regexp.MustCompile(`^\s*load\s+"[^"]*$`)
+)
+
+func (s *Server) completion(doc *document, pos Position) []CompletionItem {
+	text := doc.line(pos.Line + 1)
+	if pos.Character < len(text) {
+		text = text[:pos.Character]
+	}
+	items := []CompletionItem{}
+	switch {
+	case fieldPrefixPattern.MatchString(text):
+		v := fieldPrefixPattern.FindStringSubmatch(text)[1]
+		fname, ok := doc.idx.returns[v]
+		if !ok {
+			break
+		}
+		if mf := doc.idx.manifest(fname, s.lookup); mf != nil {
+			for _, desc := range mf.Usage.ReturnValues {
+				items = append(items, CompletionItem{
+					Label:         desc.Name,
+					Kind:          CompletionKindField,
+					Detail:        "return value of " + fname,
+					Documentation: desc.Desc,
+				})
+			}
+		}
+	case varPrefixPattern.MatchString(text):
+		for _, name := range doc.idx.names {
+			items = append(items, CompletionItem{
+				Label: name,
+				Kind:  CompletionKindVariable,
+			})
+		}
+	case loadPrefixPattern.MatchString(text):
+		for _, mf := range std.ListAll() {
+			items = append(items, CompletionItem{
+				Label:         mf.Driver + ":" + mf.Name,
+				Kind:          CompletionKindModule,
+				Documentation: mf.Description,
+			})
+		}
+	case doc.enclosingFunction(pos.Line+1) != "":
+		fname := doc.enclosingFunction(pos.Line + 1)
+		if mf := doc.idx.manifest(fname, s.lookup); mf != nil {
+			for _, desc := range mf.Usage.Args {
+				items = append(items, CompletionItem{
+					Label:         desc.Name,
+					Kind:          CompletionKindProperty,
+					Detail:        "argument of " + fname,
+					Documentation: desc.Desc,
+				})
+			}
+		}
+	case !strings.Contains(strings.TrimSpace(text), " "):
+		for _, kw := range parser.Keywords() {
+			items = append(items, CompletionItem{
+				Label: kw,
+				Kind:  CompletionKindKeyword,
+			})
+		}
+	default:
+		for _, name := range sortedKeys(doc.idx.loads) {
+			items = append(items, CompletionItem{
+				Label:  name,
+				Kind:   CompletionKindFunction,
+				Detail: doc.idx.loads[name].Target1().String(),
+			})
+		}
+		for _, name := range sortedKeys(doc.idx.fns) {
+			items = append(items, CompletionItem{
+				Label:  name,
+				Kind:   CompletionKindFunction,
+				Detail: "fn " + name + " = " + doc.idx.function(name),
+			})
+		}
+	}
+	return items
+}
+
+func (s *Server) hover(doc *document, pos Position) *Hover {
+	w := doc.wordAt(pos)
+	if w == nil {
+		return nil
+	}
+	var value string
+	switch w.kind {
+	case wordVar:
+		value = "```flowl\nvar " + w.text + "\n```"
+		if fname, ok := doc.idx.returns[w.text]; ok {
+			value += "\nThe return values of '" + fname + "'"
+		}
+	case wordField:
+		fname := doc.idx.returns[w.v]
+		if desc := usageDesc(doc.idx.manifest(fname, s.lookup), w.text, true); desc != nil {
+			value = "`" + w.text + "`: " + desc.Desc
+		}
+	case wordArg:
+		fname := doc.enclosingFunction(pos.Line + 1)
+		if desc := usageDesc(doc.idx.manifest(fname, s.lookup), w.text, false); desc != nil {
+			value = "`" + w.text + "`: " + desc.Desc
+		}
+	default:
+		if _, ok := doc.idx.loads[doc.idx.function(w.text)]; ok {
+			value = manifestMarkdown(w.text, doc.idx.manifest(w.text, s.lookup))
+		}
+	}
+	if value == "" {
+		return nil
+	}
+	rng := doc.toClient(w.rng)
+	return &Hover{
+		Contents: MarkupContent{
+			Kind:  "markdown",
+			Value: value,
+		},
+		Range: &rng,
+	}
+}
+
+func usageDesc(mf *manifest.Manifest, name string, ret bool) *manifest.UsageDesc {
+	if mf == nil {
+		return nil
+	}
+	descs := mf.Usage.Args
+	if ret {
+		descs = mf.Usage.ReturnValues
+	}
+	for i := range descs {
+		if descs[i].Name == name {
+			return &descs[i]
+		}
+	}
+	return nil
+}
+
+func manifestMarkdown(name string, mf *manifest.Manifest) string {
+	var builder strings.Builder
+	builder.WriteString("**" + name + "**")
+	if mf == nil {
+		return builder.String()
+	}
+	if mf.Name != name {
+		builder.WriteString(" → **" + mf.Name + "**")
+	}
+	builder.WriteString("\n\n" + mf.Description + "\n")
+	write := func(title string, descs []manifest.UsageDesc) {
+		if len(descs) == 0 {
+			return
+		}
+		builder.WriteString("\n" + title + ":\n")
+		for _, desc := range descs {
+			builder.WriteString("- `" + desc.Name + "`: " + desc.Desc + "\n")
+		}
+	}
+	write("Arguments", mf.Usage.Args)
+	write("Return values", mf.Usage.ReturnValues)
+	return builder.String()
+}
+
+func (s *Server) definition(doc *document, pos Position) *Location {
+	w := doc.wordAt(pos)
+	if w == nil {
+		return nil
+	}
+	location := func(ln int, name string) *Location {
+		return &Location{
+			URI:   doc.uri,
+			Range: doc.toClient(doc.wordRange(ln, name)),
+		}
+	}
+	switch w.kind {
+	case wordVar:
+		if decl, ok := doc.idx.declaration(w.text, pos.Line+1); ok {
+			return location(decl.Line, decl.Name)
+		}
+	case wordIdent:
+		if fn, ok := doc.idx.fns[w.text]; ok {
+			if imp := importOf(fn); imp != nil {
+				return location(imp.Line(), imp.Target1().String())
+			}
+			return location(fn.Line(), w.text)
+		}
+		if load, ok := doc.idx.loads[w.text]; ok {
+			if imp := importOf(load); imp != nil {
+				return location(imp.Line(), imp.Target1().String())
+			}
+			return location(load.Line(), load.Target1().String())
+		}
+		if decl, ok := doc.idx.declaration(w.text, pos.Line+1); ok {
+			return location(decl.Line, decl.Name)
+		}
+	}
+	return nil
+}
+
+func (s *Server) symbols(doc *document) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	add := func(ln int, name, detail string, kind int) {
+		symbols = append(symbols, DocumentSymbol{
+			Name:           name,
+			Detail:         detail,
+			Kind:           kind,
+			Range:          doc.toClient(doc.lineRange(ln)),
+			SelectionRange: doc.toClient(doc.wordRange(ln, name)),
+		})
+	}
+	for name, load := range doc.idx.loads {
+		if importOf(load) == nil {
+			add(load.Line(), name, load.Target1().String(), SymbolKindModule)
+		}
+	}
+	for name, fn := range doc.idx.fns {
+		if importOf(fn) == nil {
+			add(fn.Line(), name, fn.Target2().String(), SymbolKindFunction)
+		}
+	}
+	for _, decls := range doc.idx.decls {
+		for _, decl := range decls {
+			add(decl.Line, decl.Name, "", SymbolKindVariable)
+		}
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].Range.Start.Line < symbols[j].Range.Start.Line
+	})
+	return symbols
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
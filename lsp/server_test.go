@@ -0,0 +1,250 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/cofunclabs/cofunc/functiondriver"
+	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/std"
+	"github.com/stretchr/testify/assert"
+)
+
+type testingClient struct {
+	t   *testing.T
+	w   io.Writer
+	rd  *conn
+	seq int
+}
+
+func (c *testingClient) send(method string, params interface{}, notify bool) {
+	msg := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	}
+	if !notify {
+		c.seq++
+		msg["id"] = c.seq
+	}
+	body, err := json.Marshal(msg)
+	assert.NoError(c.t, err)
+	fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// recv reads a message from the server, and decodes the result or params into 'v'
+func (c *testingClient) recv(v interface{}) map[string]json.RawMessage {
+	data, err := c.rd.read()
+	assert.NoError(c.t, err)
+	var msg map[string]json.RawMessage
+	assert.NoError(c.t, json.Unmarshal(data, &msg))
+	if v != nil {
+		raw, ok := msg["result"]
+		if !ok {
+			raw = msg["params"]
+		}
+		assert.NoError(c.t, json.Unmarshal(raw, v))
+	}
+	return msg
+}
+
+func (c *testingClient) call(method string, params interface{}, result interface{}) {
+	c.send(method, params, false)
+	c.recv(result)
+}
+
+func position(uri string, line, char int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: char},
+	}
+}
+
+func labels(items []CompletionItem) []string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Label)
+	}
+	return names
+}
+
+func TestServer(t *testing.T) {
+	const uri = "file:///testing.flowl"
+	const testingdata string = `load "go:print"
+load "go:time"
+
+var out
+
+fn p = print {
+	args = {
+		"_": "$(out.now)"
+	}
+}
+
+co time -> out { // the current time
+	"format": "YYYY"
+}
+co p
+`
+	lookup := func(loc functiondriver.Location) *manifest.Manifest {
+		mf, _, _ := std.Lookup(loc.FuncName)
+		return mf
+	}
+
+	inrd, inw := io.Pipe()
+	outrd, outw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- New(lookup).Serve(context.Background(), inrd, outw)
+	}()
+	client := &testingClient{t: t, w: inw, rd: &conn{rd: bufio.NewReader(outrd)}}
+
+	var initResult InitializeResult
+	client.call("initialize", map[string]interface{}{}, &initResult)
+	assert.True(t, initResult.Capabilities.HoverProvider)
+	client.send("initialized", map[string]interface{}{}, true)
+
+	// syntax error
+	var diags PublishDiagnosticsParams
+	client.send("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "flowl", Text: "load \"go:print\"\nco\n"},
+	}, true)
+	client.recv(&diags)
+	if assert.Len(t, diags.Diagnostics, 1) {
+		assert.Equal(t, SeverityError, diags.Diagnostics[0].Severity)
+		assert.Equal(t, 1, diags.Diagnostics[0].Range.Start.Line)
+	}
+
+	// lint issues
+	client.send("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: testingdata + "var unused\n"}},
+	}, true)
+	client.recv(&diags)
+	if assert.Len(t, diags.Diagnostics, 1) {
+		assert.Equal(t, SeverityWarning, diags.Diagnostics[0].Severity)
+		assert.Equal(t, "unused-var", diags.Diagnostics[0].Code)
+		assert.Equal(t, 15, diags.Diagnostics[0].Range.Start.Line)
+	}
+
+	client.send("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 3},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: testingdata}},
+	}, true)
+	client.recv(&diags)
+	assert.Len(t, diags.Diagnostics, 0)
+
+	// completion
+	var items []CompletionItem
+	client.call("textDocument/completion", position(uri, 14, 0), &items)
+	assert.Contains(t, labels(items), "co")
+	assert.Contains(t, labels(items), "switch")
+
+	client.call("textDocument/completion", position(uri, 14, 4), &items)
+	assert.Equal(t, []string{"print", "time", "p"}, labels(items))
+
+	client.call("textDocument/completion", position(uri, 12, 1), &items)
+	assert.Equal(t, []string{"format", "get_timestamp"}, labels(items))
+
+	client.call("textDocument/completion", position(uri, 7, 15), &items)
+	assert.Contains(t, labels(items), "now")
+	assert.Contains(t, labels(items), "year")
+
+	client.call("textDocument/completion", position(uri, 7, 11), &items)
+	assert.Equal(t, []string{"out"}, labels(items))
+
+	// hover
+	var hover *Hover
+	client.call("textDocument/hover", position(uri, 11, 4), &hover)
+	if assert.NotNil(t, hover) {
+		assert.Contains(t, hover.Contents.Value, "**time**")
+		assert.Contains(t, hover.Contents.Value, "`get_timestamp`")
+	}
+	client.call("textDocument/hover", position(uri, 14, 3), &hover)
+	if assert.NotNil(t, hover) {
+		assert.Contains(t, hover.Contents.Value, "**p** → **print**")
+	}
+	client.call("textDocument/hover", position(uri, 12, 3), &hover)
+	if assert.NotNil(t, hover) {
+		assert.Contains(t, hover.Contents.Value, "`format`")
+	}
+	client.call("textDocument/hover", position(uri, 7, 15), &hover)
+	if assert.NotNil(t, hover) {
+		assert.Equal(t, "`now`: Current time", hover.Contents.Value)
+	}
+	client.call("textDocument/hover", position(uri, 3, 0), &hover)
+	assert.Nil(t, hover)
+
+	// definition
+	var loc *Location
+	client.call("textDocument/definition", position(uri, 7, 11), &loc)
+	if assert.NotNil(t, loc) {
+		assert.Equal(t, Range{Start: Position{3, 4}, End: Position{3, 7}}, loc.Range)
+	}
+	client.call("textDocument/definition", position(uri, 14, 3), &loc)
+	if assert.NotNil(t, loc) {
+		assert.Equal(t, Range{Start: Position{5, 3}, End: Position{5, 4}}, loc.Range)
+	}
+	client.call("textDocument/definition", position(uri, 11, 4), &loc)
+	if assert.NotNil(t, loc) {
+		assert.Equal(t, Range{Start: Position{1, 6}, End: Position{1, 13}}, loc.Range)
+	}
+
+	// symbols
+	var symbols []DocumentSymbol
+	client.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols)
+	var names []string
+	for _, s := range symbols {
+		names = append(names, s.Name)
+	}
+	assert.ElementsMatch(t, []string{"print", "time", "out", "p"}, names)
+
+	// unknown method
+	client.send("textDocument/rename", position(uri, 0, 0), false)
+	msg := client.recv(nil)
+	assert.Contains(t, string(msg["error"]), "-32601")
+
+	client.call("shutdown", nil, nil)
+	client.send("exit", nil, true)
+	assert.NoError(t, <-done)
+}
+
+func TestPositionEncoding(t *testing.T) {
+	const testingdata string = `load "go:print"
+
+var out
+co print {
+	"😀é": "$(out)"
+}
+`
+	encoding := func(encodings ...string) string {
+		var params InitializeParams
+		params.Capabilities.General.PositionEncodings = encodings
+		result, err := New(nil).initialize(params)
+		assert.NoError(t, err)
+		return result.(InitializeResult).Capabilities.PositionEncoding
+	}
+	assert.Equal(t, PositionEncodingUTF16, encoding())
+	assert.Equal(t, PositionEncodingUTF16, encoding("utf-32", PositionEncodingUTF16))
+	assert.Equal(t, PositionEncodingUTF8, encoding(PositionEncodingUTF16, PositionEncodingUTF8))
+
+	// The emoji is 2 code units in UTF-16 but 4 bytes, the 'é' is 1 code unit but 2 bytes
+	doc := newDocument("file:///testing.flowl", PositionEncodingUTF16)
+	doc.update(testingdata, nil)
+	pos := doc.fromClient(Position{Line: 4, Character: 11})
+	assert.Equal(t, Position{Line: 4, Character: 14}, pos)
+	w := doc.wordAt(pos)
+	if assert.NotNil(t, w) {
+		assert.Equal(t, "out", w.text)
+		assert.Equal(t, Range{Start: Position{4, 11}, End: Position{4, 14}}, doc.toClient(w.rng))
+	}
+	assert.Equal(t, Range{Start: Position{4, 0}, End: Position{4, 16}}, doc.toClient(doc.lineRange(5)))
+
+	doc.encoding = PositionEncodingUTF8
+	assert.Equal(t, pos, doc.fromClient(pos))
+	assert.Equal(t, w.rng, doc.toClient(w.rng))
+}
@@ -1,8 +1,11 @@
 package manifest
 
 type Manifest struct {
-	Category    string `json:"category"`
-	Name        string `json:"name"`
+	Category string `json:"category"`
+	Name     string `json:"name"`
+	// Version is a semantic version of the function, e.g. '1.2.0', it's used to resolve the version
+	// constraint in the 'load' statement, e.g. 'load "shell:echo@^1.2"'
+	Version     string `json:"version,omitempty"`
 	Description string `json:"description"`
 	Driver      string `json:"driver"`
 	// Note: You don't need to specify the Entrypoint field, When develop a new std function.
@@ -11,7 +14,10 @@ type Manifest struct {
 	Args           map[string]string `json:"args"`
 	RetryOnFailure int               `json:"retry_on_failure"`
 	IgnoreFailure  bool              `json:"ignore_failure"`
-	Usage          Usage             `json:"usage"`
+	// MemoryLimit is the max memory in MiB that the function can use, it's only available for the sandboxed
+	// drivers, e.g. wasm.
+	MemoryLimit int   `json:"memory_limit,omitempty"`
+	Usage       Usage `json:"usage"`
 }
 
 type Usage struct {
@@ -20,7 +26,16 @@ type Usage struct {
 }
 
 type UsageDesc struct {
-	Name           string   `json:"name"`
+	Name string `json:"name"`
+	// OptionalValues restricts the value of the argument, it's not restricted if it's empty.
 	OptionalValues []string `json:"optional_values"`
 	Desc           string   `json:"desc"`
+	// Type is the type of the argument value, the default is 'string'.
+	Type ArgType `json:"type,omitempty"`
+	// Required means the argument must be specified, or it has a default value.
+	Required bool `json:"required,omitempty"`
+	// Default is used when the argument isn't specified.
+	Default string `json:"default,omitempty"`
+	// Pattern is a regular expression that the argument value must match.
+	Pattern string `json:"pattern,omitempty"`
 }
@@ -0,0 +1,123 @@
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cofunclabs/cofunc/pkg/semver"
+)
+
+// FileName is the name of the manifest file in the directory of a function.
+const FileName = "manifest.json"
+
+var ErrVersionNotFound = errors.New("not found the version of function")
+
+// LoadFile reads and decodes the manifest file.
+func LoadFile(path string) (*Manifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var mf Manifest
+	if err := json.NewDecoder(file).Decode(&mf); err != nil {
+		return nil, fmt.Errorf("%w: decode manifest", err)
+	}
+	return &mf, nil
+}
+
+// Resolve finds the directory of the function that satisfies the version constraint, then loads the manifest
+// of the function. Multiple versions of a function can be installed side by side, the layout is:
+//
+//	<root>/<fpath>@<version>/manifest.json
+//	<root>/<fpath>/manifest.json
+//
+// The version of the second one is the 'version' field of the manifest, if it's empty, the function is
+// unversioned and only be chosen by the 'latest' constraint when no other versions are installed.
+func Resolve(root, fpath, constraint string) (string, *Manifest, error) {
+	c, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return "", nil, err
+	}
+	dirs, unversioned, err := InstalledVersions(root, fpath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var versions []string
+	for v := range dirs {
+		versions = append(versions, v)
+	}
+	if v, ok := c.Highest(versions); ok {
+		dir := dirs[v]
+		mf, err := LoadFile(filepath.Join(dir, FileName))
+		if err != nil {
+			return "", nil, err
+		}
+		if mf.Version == "" {
+			mf.Version = v
+		}
+		return dir, mf, nil
+	}
+	if c.IsLatest() && unversioned != "" {
+		mf, err := LoadFile(filepath.Join(unversioned, FileName))
+		if err != nil {
+			return "", nil, err
+		}
+		return unversioned, mf, nil
+	}
+
+	semver.Sort(versions)
+	if unversioned != "" {
+		versions = append(versions, "unversioned")
+	}
+	installed := "none"
+	if len(versions) != 0 {
+		installed = strings.Join(versions, ", ")
+	}
+	return "", nil, fmt.Errorf("%w: function '%s' version '%s', installed versions: %s", ErrVersionNotFound, fpath, c, installed)
+}
+
+// InstalledVersions returns the directories of all installed versions of the function, the key is the version.
+// The directory of the unversioned function is returned separately.
+func InstalledVersions(root, fpath string) (map[string]string, string, error) {
+	base := filepath.Join(root, fpath)
+	parent, name := filepath.Dir(base), filepath.Base(base)
+
+	dirs := make(map[string]string)
+	entries, err := os.ReadDir(parent)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, "", err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), name+"@") {
+			continue
+		}
+		v := strings.TrimPrefix(entry.Name(), name+"@")
+		if _, err := semver.Parse(v); err != nil {
+			continue
+		}
+		dirs[v] = filepath.Join(parent, entry.Name())
+	}
+
+	var unversioned string
+	if _, err := os.Stat(filepath.Join(base, FileName)); err == nil {
+		mf, err := LoadFile(filepath.Join(base, FileName))
+		if err != nil {
+			return nil, "", err
+		}
+		if mf.Version == "" {
+			unversioned = base
+		} else if _, err := semver.Parse(mf.Version); err != nil {
+			return nil, "", fmt.Errorf("%w: manifest of function '%s'", err, fpath)
+		} else if _, ok := dirs[mf.Version]; !ok {
+			dirs[mf.Version] = base
+		}
+	}
+	return dirs, unversioned, nil
+}
@@ -0,0 +1,65 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func installManifest(t *testing.T, dir, version string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	mf := `{"name": "echo", "version": "` + version + `", "entrypoint": "entry.sh"}`
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(mf), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+}
+
+func TestResolve(t *testing.T) {
+	root := t.TempDir()
+	installManifest(t, filepath.Join(root, "tools", "echo@1.0.0"), "")
+	installManifest(t, filepath.Join(root, "tools", "echo@1.2.0"), "1.2.0")
+	installManifest(t, filepath.Join(root, "tools", "echo"), "2.0.0")
+	installManifest(t, filepath.Join(root, "hello"), "")
+
+	cases := []struct {
+		fpath      string
+		constraint string
+		dir        string
+		version    string
+	}{
+		{"tools/echo", "latest", "tools/echo", "2.0.0"},
+		{"tools/echo", "^1", "tools/echo@1.2.0", "1.2.0"},
+		{"tools/echo", "1.0", "tools/echo@1.0.0", "1.0.0"},
+		{"tools/echo", ">=1.1", "tools/echo", "2.0.0"},
+		{"hello", "latest", "hello", ""},
+		{"hello", "", "hello", ""},
+	}
+	for _, c := range cases {
+		dir, mf, err := Resolve(root, c.fpath, c.constraint)
+		if !assert.NoError(t, err, c.constraint) {
+			continue
+		}
+		assert.Equal(t, filepath.Join(root, c.dir), dir)
+		assert.Equal(t, c.version, mf.Version)
+	}
+
+	{
+		_, _, err := Resolve(root, "tools/echo", "^3")
+		assert.ErrorIs(t, err, ErrVersionNotFound)
+		assert.Contains(t, err.Error(), "installed versions: 1.0.0, 1.2.0, 2.0.0")
+	}
+	{
+		_, _, err := Resolve(root, "hello", "1.0")
+		assert.ErrorIs(t, err, ErrVersionNotFound)
+		assert.Contains(t, err.Error(), "installed versions: unversioned")
+	}
+	{
+		_, _, err := Resolve(root, "notfound", "latest")
+		assert.ErrorIs(t, err, ErrVersionNotFound)
+		assert.Contains(t, err.Error(), "installed versions: none")
+	}
+}
@@ -0,0 +1,181 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cofunclabs/cofunc/pkg/stringutil"
+)
+
+var (
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrMissingArgument = errors.New("missing argument")
+)
+
+// ArgType is the type of the argument value, the value of the argument is always a string, the type is used
+// to validate the value.
+type ArgType string
+
+const (
+	TypeString   = ArgType("string")
+	TypeInt      = ArgType("int")
+	TypeBool     = ArgType("bool")
+	TypeDuration = ArgType("duration")
+	// TypeList is a list separated by ',', every element is a string
+	TypeList = ArgType("list")
+	TypeURL  = ArgType("url")
+)
+
+// ParseBool parses the bool value of the argument, it accepts 'true', 'yes', '1', 'false', 'no' and '0'.
+func ParseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "1":
+		return true, nil
+	case "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: '%s' isn't a bool", ErrInvalidArgument, s)
+}
+
+// ParseURL parses the url value of the argument, the scheme and host must be specified.
+func ParseURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%w: '%s' isn't an url", ErrInvalidArgument, s)
+	}
+	return u, nil
+}
+
+// Validate checks the manifest itself, e.g. the type and pattern of the arguments.
+func (m *Manifest) Validate() error {
+	for _, desc := range m.Usage.Args {
+		switch desc.Type {
+		case "", TypeString, TypeInt, TypeBool, TypeDuration, TypeList, TypeURL:
+		default:
+			return fmt.Errorf("%w: unknown type '%s' of the argument '%s' in manifest of function '%s'", ErrInvalidArgument, desc.Type, desc.Name, m.Name)
+		}
+		if desc.Pattern != "" {
+			if _, err := regexp.Compile(desc.Pattern); err != nil {
+				return fmt.Errorf("%w: pattern of the argument '%s' in manifest of function '%s': %s", ErrInvalidArgument, desc.Name, m.Name, err)
+			}
+		}
+		if desc.Default != "" {
+			if err := desc.Validate(desc.Default); err != nil {
+				return fmt.Errorf("%w: default value in manifest of function '%s'", err, m.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyDefaults returns a new map that contains the arguments and the default values of the arguments that
+// not specified, the default values are from 'Args' and 'Usage.Args'.
+func (m *Manifest) ApplyDefaults(args map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, desc := range m.Usage.Args {
+		if desc.Default != "" {
+			merged[desc.Name] = desc.Default
+		}
+	}
+	for k, v := range m.Args {
+		merged[k] = v
+	}
+	for k, v := range args {
+		merged[k] = v
+	}
+	return merged
+}
+
+// CheckRequired checks all required arguments are specified in 'args' or have a default value.
+func (m *Manifest) CheckRequired(args map[string]string) error {
+	merged := m.ApplyDefaults(args)
+	for _, desc := range m.Usage.Args {
+		if !desc.Required {
+			continue
+		}
+		if _, ok := merged[desc.Name]; !ok {
+			return fmt.Errorf("%w: '%s' of function '%s'", ErrMissingArgument, desc.Name, m.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateArgs validates the values of the arguments by the usage of the manifest, the arguments that not
+// described by the usage aren't validated.
+func (m *Manifest) ValidateArgs(args map[string]string) error {
+	for _, desc := range m.Usage.Args {
+		v, ok := args[desc.Name]
+		if !ok {
+			continue
+		}
+		if err := desc.Validate(v); err != nil {
+			return fmt.Errorf("%w: function '%s'", err, m.Name)
+		}
+	}
+	return nil
+}
+
+// Validate checks the value matches the type, pattern and optional values of the argument.
+func (d UsageDesc) Validate(value string) error {
+	var err error
+	switch d.Type {
+	case "", TypeString:
+	case TypeInt:
+		if _, e := strconv.ParseInt(value, 10, 64); e != nil {
+			err = fmt.Errorf("'%s' isn't an int", value)
+		}
+	case TypeBool:
+		if _, e := ParseBool(value); e != nil {
+			err = fmt.Errorf("'%s' isn't a bool", value)
+		}
+	case TypeDuration:
+		if _, e := time.ParseDuration(value); e != nil {
+			err = fmt.Errorf("'%s' isn't a duration", value)
+		}
+	case TypeURL:
+		if _, e := ParseURL(value); e != nil {
+			err = fmt.Errorf("'%s' isn't an url", value)
+		}
+	case TypeList:
+	default:
+		err = fmt.Errorf("unknown type '%s'", d.Type)
+	}
+	if err == nil && d.Pattern != "" {
+		re, e := regexp.Compile(d.Pattern)
+		if e != nil {
+			err = fmt.Errorf("invalid pattern '%s'", d.Pattern)
+		} else if !re.MatchString(value) {
+			err = fmt.Errorf("'%s' doesn't match the pattern '%s'", value, d.Pattern)
+		}
+	}
+	if err == nil && len(d.OptionalValues) != 0 {
+		values := []string{value}
+		if d.Type == TypeList {
+			values = stringutil.String2Slice(value)
+		}
+		for _, v := range values {
+			if !contains(d.OptionalValues, v) {
+				err = fmt.Errorf("'%s' isn't one of the optional values '%s'", v, strings.Join(d.OptionalValues, "', '"))
+				break
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%w: '%s', %s", ErrInvalidArgument, d.Name, err)
+	}
+	return nil
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateArg(t *testing.T) {
+	cases := []struct {
+		desc  UsageDesc
+		value string
+		ok    bool
+	}{
+		{UsageDesc{Name: "s"}, "any", true},
+		{UsageDesc{Name: "n", Type: TypeInt}, "10", true},
+		{UsageDesc{Name: "n", Type: TypeInt}, "ten", false},
+		{UsageDesc{Name: "b", Type: TypeBool}, "yes", true},
+		{UsageDesc{Name: "b", Type: TypeBool}, "maybe", false},
+		{UsageDesc{Name: "d", Type: TypeDuration}, "1m10s", true},
+		{UsageDesc{Name: "d", Type: TypeDuration}, "10", false},
+		{UsageDesc{Name: "u", Type: TypeURL}, "https://cofunc.io/api", true},
+		{UsageDesc{Name: "u", Type: TypeURL}, "cofunc.io", false},
+		{UsageDesc{Name: "p", Pattern: `^v\d+$`}, "v1", true},
+		{UsageDesc{Name: "p", Pattern: `^v\d+$`}, "1", false},
+		{UsageDesc{Name: "o", OptionalValues: []string{"a", "b"}}, "a", true},
+		{UsageDesc{Name: "o", OptionalValues: []string{"a", "b"}}, "c", false},
+		{UsageDesc{Name: "l", Type: TypeList, OptionalValues: []string{"a", "b"}}, "a, b", true},
+		{UsageDesc{Name: "l", Type: TypeList, OptionalValues: []string{"a", "b"}}, "a,c", false},
+	}
+	for _, c := range cases {
+		err := c.desc.Validate(c.value)
+		if c.ok {
+			assert.NoError(t, err, c.value)
+		} else {
+			assert.ErrorIs(t, err, ErrInvalidArgument, c.value)
+		}
+	}
+}
+
+func TestValidateArgs(t *testing.T) {
+	mf := &Manifest{
+		Name: "testing",
+		Args: map[string]string{
+			"retries": "3",
+		},
+		Usage: Usage{
+			Args: []UsageDesc{
+				{Name: "target", Required: true},
+				{Name: "retries", Type: TypeInt, Required: true},
+				{Name: "timeout", Type: TypeDuration, Default: "10s"},
+			},
+		},
+	}
+	assert.NoError(t, mf.Validate())
+
+	err := mf.CheckRequired(map[string]string{})
+	assert.ErrorIs(t, err, ErrMissingArgument)
+	assert.NoError(t, mf.CheckRequired(map[string]string{"target": "all"}))
+
+	args := mf.ApplyDefaults(map[string]string{"target": "all"})
+	assert.Equal(t, map[string]string{"target": "all", "retries": "3", "timeout": "10s"}, args)
+	assert.NoError(t, mf.ValidateArgs(args))
+
+	args["timeout"] = "soon"
+	assert.ErrorIs(t, mf.ValidateArgs(args), ErrInvalidArgument)
+
+	mf.Usage.Args = append(mf.Usage.Args, UsageDesc{Name: "bad", Type: "float"})
+	assert.ErrorIs(t, mf.Validate(), ErrInvalidArgument)
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/cofunclabs/cofunc/pkg/enabled"
@@ -16,6 +17,13 @@ type Block struct {
 	parent   *Block
 	vtbl     vartable
 	body
+	// varrefs stores the references to variables in the var statements, the var statements aren't saved
+	// in the body.
+	varrefs  []VarRef
+	vardecls []VarDecl
+	comments []*Comment
+	// saved stores the values of variables saved by SaveValues
+	saved map[string]*_var
 }
 
 func (b *Block) Child() []*Block {
@@ -45,12 +53,24 @@ func (b *Block) RewriteVar(stm *Statement) error {
 	}
 	name := stm.tokens[0].String()
 
-	// Eliminate the circular dependency of the variable itself to itself
-	s, _ := b.calcVar(name)
+	// Eliminate the circular dependency of the variable itself to itself, the value is substituted by its type
+	// in the expression, e.g. 'a <- $(a) + 1'
+	val, err := b.GetValue(name)
+	if err != nil {
+		return err
+	}
+	asexp := stm.tokens[1].TypeEqual(_expr_t)
 	segments := stm.tokens[1]._segments
 	for i, seg := range segments {
 		if seg.isvar && seg.str == name {
-			segments[i].str = s
+			switch {
+			case !asexp:
+				segments[i].str = val.String()
+			case i > 0 && isQuoted(segments[i-1].str):
+				segments[i].str = quotedReplacer.Replace(val.String())
+			default:
+				segments[i].str = val.expr()
+			}
 			segments[i].isvar = false
 		}
 	}
@@ -80,14 +100,20 @@ func (b *Block) AddField2Var(name, field, val string) error {
 	return nil
 }
 
-func (b *Block) ExecCondition() bool {
-	_, ok := b.vtbl.get(_condition_expr_var)
+// ExecCondition evaluates the condition of the block, it's true if the block has no condition, the condition
+// that can't be converted to bool is false.
+func (b *Block) ExecCondition() (bool, error) {
+	v, ok := b.vtbl.get(_condition_expr_var)
 	if !ok {
 		// not found condition var in the block
-		return true
+		return true, nil
 	}
-	s, _ := b.calcVar(_condition_expr_var)
-	return s == "true"
+	val, _, err := v.value()
+	if err != nil {
+		return false, err
+	}
+	ok, err = val.Bool()
+	return err == nil && ok, nil
 }
 
 func (b *Block) Iskind(s string) bool {
@@ -114,6 +140,28 @@ func (b *Block) IsLoad() bool {
 	return b.Iskind(_kw_load)
 }
 
+func (b *Block) IsImport() bool {
+	return b.Iskind(_kw_import)
+}
+
+func (b *Block) IsParam() bool {
+	return b.Iskind(_kw_param)
+}
+
+func (b *Block) IsRequire() bool {
+	return b.Iskind(_kw_require)
+}
+
+// InImport returns true if the block is defined in an imported file
+func (b *Block) InImport() bool {
+	for p := b.parent; p != nil; p = p.parent {
+		if p.IsImport() {
+			return true
+		}
+	}
+	return false
+}
+
 func (b *Block) IsGlobal() bool {
 	return b.Iskind("global")
 }
@@ -206,15 +254,100 @@ func (b *Block) Debug() {
 	b.vtbl.debug("\t")
 }
 
-// GetVarValue returns the value of the variable, the argument is the variable name
+// GetVarValue returns the value of the variable, the argument is the variable name, it's empty if the variable
+// isn't defined or can't be calculated.
 func (b *Block) GetVarValue(name string) string {
-	defer func() {
-		recover()
-	}()
-	v, _ := b.calcVar(name)
+	v, _, _ := b.calcVar(name)
 	return v
 }
 
+// GetVarNames returns the names of all variables defined in the block, the builtin variables are not included.
+func (b *Block) GetVarNames() []string {
+	b.vtbl.Lock()
+	defer b.vtbl.Unlock()
+
+	var names []string
+	for name, v := range b.vtbl.vars {
+		if v.isenv || v.issecret || v.ns != nil || name == _condition_expr_var {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetValue returns the typed value of the variable, the argument is the variable name. It returns an error if
+// the variable isn't defined or the calculation fails.
+func (b *Block) GetValue(name string) (Value, error) {
+	if v, _ := b.getVar(name); v != nil && !v.isenv && !v.issecret && v.ns == nil {
+		val, _, err := v.value()
+		if err != nil {
+			return NullValue(), err
+		}
+		return val, nil
+	}
+	s, _, err := b.calcVar(name)
+	if err != nil {
+		return NullValue(), err
+	}
+	return StringValue(s), nil
+}
+
+// SetVarValue sets the value of the variable that defined in the block or its parent blocks
+func (b *Block) SetVarValue(name, val string) error {
+	return b.SetValue(name, StringValue(val))
+}
+
+// SetValue sets the typed value of the variable that defined in the block or its parent blocks
+func (b *Block) SetValue(name string, val Value) error {
+	v, inblock := b.getVar(name)
+	if v == nil || v.isenv || v.issecret || name == _condition_expr_var {
+		return fmt.Errorf("%w: variable '%s'", ErrVariableNotDefined, name)
+	}
+	inblock.putVar(name, &_var{
+		val:    val,
+		cached: true,
+	})
+	// The variables that reference the variable must be calculated again
+	inblock.uncache()
+	return nil
+}
+
+// SaveValues saves the values of the variables defined in the block, e.g. the initialized global variables of a
+// nested flow, they are restored by RestoreValues before running the flow again.
+func (b *Block) SaveValues() {
+	b.vtbl.Lock()
+	defer b.vtbl.Unlock()
+
+	b.saved = make(map[string]*_var, len(b.vtbl.vars))
+	for name, v := range b.vtbl.vars {
+		if v.isenv || v.issecret || v.ns != nil {
+			continue
+		}
+		b.saved[name] = v.snapshot()
+	}
+}
+
+// RestoreValues restores the values of the variables saved by SaveValues, the values set or rewritten after
+// saving and the fields of the return values are dropped.
+func (b *Block) RestoreValues() {
+	for name, saved := range b.saved {
+		if v, ok := b.vtbl.get(name); ok {
+			v.restore(saved)
+		}
+	}
+	b.uncache()
+}
+
+// uncache clears the cache of the variables in the block and its children that reference other variables
+func (b *Block) uncache() {
+	b.vtbl.uncache()
+	for _, c := range b.child {
+		c.uncache()
+	}
+}
+
 // Getvar lookup variable by name in map
 func (b *Block) getVar(name string) (*_var, *Block) {
 	for p := b; p != nil; p = p.parent {
@@ -238,20 +371,150 @@ func (b *Block) putVar(name string, v *_var) {
 }
 
 // calcVar calcuate the variable's value
-func (b *Block) calcVar(name string) (string, bool) {
+func (b *Block) calcVar(name string) (string, bool, error) {
 	if b == nil {
-		panic(fmt.Sprintf("var name '%s': block is nil", name))
+		return "", false, fmt.Errorf("%w: '%s', block is nil", ErrVariableNotDefined, name)
+	}
+	if isCallVar(name) {
+		t, err := newCallToken(b, name, 0, 0)
+		if err != nil {
+			return "", false, err
+		}
+		v, err := newVarFromToken(t)
+		if err != nil {
+			return "", false, err
+		}
+		return v.calc()
 	}
 
 	for p := b; p != nil; p = p.parent {
-		v, cached := p.vtbl.calc(name)
-		if v == nil {
+		v, found, cached, err := p.vtbl.calc(name)
+		if !found {
 			continue
 		}
-		return v.(string), cached
+		return v, cached, err
+	}
+	return "", false, fmt.Errorf("%w: '%s'", ErrVariableNotDefined, name)
+}
+
+// VarScope returns the block that defines the variable, it returns nil if the variable isn't defined.
+func (b *Block) VarScope(name string) *Block {
+	_, scope := b.getVar(name)
+	return scope
+}
+
+// VarRef is a reference to a variable or a field of a variable, e.g. '$(s)' or '$(out.now)'
+type VarRef struct {
+	Var string
+	// Field is empty if the reference isn't to a field
+	Field string
+	Line  int
+	// Scope is the block that defines the variable
+	Scope *Block
+	// secret is true if the reference is to a secret, e.g. '$(secret.TOKEN)'
+	secret bool
+}
+
+// VarDecl is a declaration of a variable by the 'var' statement
+type VarDecl struct {
+	Name string
+	Line int
+}
+
+// VarDecls returns the variables declared in the block, in the order of declaration.
+func (b *Block) VarDecls() []VarDecl {
+	return b.vardecls
+}
+
+// Comments returns the comments attached to the block, they are the comments above the statement of the block
+// and the trailing comments of its lines that aren't the statements of child blocks.
+func (b *Block) Comments() []*Comment {
+	return b.comments
+}
+
+// Line returns the line number of the block in flowl source file.
+func (b *Block) Line() int {
+	return b.kind.ln
+}
+
+// FieldRefs returns the references to the fields of variables in the block, e.g. '$(out.now)', the references in
+// the child blocks aren't included.
+func (b *Block) FieldRefs() []VarRef {
+	var refs []VarRef
+	for _, ref := range b.varRefs() {
+		if ref.Field != "" && !ref.secret {
+			refs = append(refs, ref)
+		}
 	}
+	return refs
+}
+
+// varRefs returns the references to variables in the block, the references to the environment variables
+// are excluded.
+func (b *Block) varRefs() []VarRef {
+	ts := []*Token{
+		&b.target1,
+		&b.target2,
+	}
+	if b.body != nil {
+		for _, l := range b.body.List() {
+			ts = append(ts, l.tokens...)
+		}
+	}
+	return append(b.scanVarRefs(ts), b.varrefs...)
+}
 
-	panic("not found variable: " + name)
+func (b *Block) scanVarRefs(ts []*Token) []VarRef {
+	var refs []VarRef
+	for _, t := range ts {
+		for _, seg := range t._segments {
+			if !seg.isvar {
+				continue
+			}
+			if isCallVar(seg.str) {
+				// the references in the arguments of the call
+				if ct, err := newCallToken(t._b, seg.str, t.ln, t.col); err == nil {
+					if ct._b == nil {
+						ct._b = b
+					}
+					refs = append(refs, b.scanVarRefs([]*Token{ct})...)
+				}
+				continue
+			}
+			name, field, ok := isFieldVar(seg.str)
+			if !ok {
+				name, field = seg.str, ""
+			}
+			// the path in the field isn't a part of the reference, e.g. '$(out.artifacts[0].path)'
+			field, _ = splitFieldPath(field)
+			scope := b
+			if t._b != nil {
+				scope = t._b
+			}
+			v, scope := scope.getVar(name)
+			if v == nil || v.isenv {
+				continue
+			}
+			if v.issecret {
+				refs = append(refs, VarRef{Var: name, Field: field, Line: t.ln, Scope: scope, secret: true})
+				continue
+			}
+			if v.ns != nil {
+				// the reference to a variable of the imported file, e.g. '$(go.version)'
+				if _, ok := v.ns.vtbl.get(field); !ok {
+					continue
+				}
+				name, field, scope = field, "", v.ns
+			}
+			refs = append(refs, VarRef{
+				Var:   name,
+				Field: field,
+				Line:  t.ln,
+				Scope: scope,
+			})
+		}
+	}
+	return refs
 }
 
 func (b *Block) validate() error {
@@ -296,6 +559,10 @@ func (b *Block) initVar(stm *Statement) error {
 	if err := b.vtbl.cyclecheck(name); err != nil {
 		return err
 	}
+	b.varrefs = append(b.varrefs, b.scanVarRefs(stm.tokens[1:])...)
+	if name != _condition_expr_var {
+		b.vardecls = append(b.vardecls, VarDecl{Name: name, Line: stm.tokens[0].ln})
+	}
 	return nil
 }
 
@@ -331,12 +598,57 @@ type MapBody struct {
 	plainbody
 }
 
-func (m *MapBody) ToMap() map[string]string {
-	ret := make(map[string]string)
+// ToValues calculates the keys and the typed values, the value that only references a variable, e.g. '"$(a)"',
+// has the type of the variable. It fails if a variable in them can't be calculated.
+func (m *MapBody) ToValues() (map[string]Value, error) {
+	ret := make(map[string]Value)
 	for _, ln := range m.lines {
-		k, v := ln.tokens[0].value(), ln.tokens[1].value()
+		k, err := ln.tokens[0].value()
+		if err != nil {
+			return nil, err
+		}
+		v, err := ln.tokens[1].typedValue()
+		if err != nil {
+			return nil, err
+		}
 		ret[k] = v
 	}
+	return ret, nil
+}
+
+// ToMap calculates the keys and values, the values are formatted by their types, e.g. the float '1 / 3' is
+// '0.333333333333333'. It fails if a variable in them can't be calculated.
+func (m *MapBody) ToMap() (map[string]string, error) {
+	values, err := m.ToValues()
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]string, len(values))
+	for k, v := range values {
+		ret[k] = v.String()
+	}
+	return ret, nil
+}
+
+// Keys returns the key tokens in the order of definition.
+func (m *MapBody) Keys() []*Token {
+	var keys []*Token
+	for _, ln := range m.lines {
+		keys = append(keys, ln.tokens[0])
+	}
+	return keys
+}
+
+// Literals returns the kvs that don't contain any variable, their values are known before running.
+func (m *MapBody) Literals() map[string]string {
+	ret := make(map[string]string)
+	for _, ln := range m.lines {
+		k, v := ln.tokens[0], ln.tokens[1]
+		if k.hasVar() || v.hasVar() {
+			continue
+		}
+		ret[k.str] = v.str
+	}
 	return ret
 }
 
@@ -368,13 +680,17 @@ type ListBody struct {
 	etype TokenType
 }
 
-func (l *ListBody) ToSlice() []string {
+// ToSlice calculates the elements, it fails if a variable in them can't be calculated.
+func (l *ListBody) ToSlice() ([]string, error) {
 	var ret []string
 	for _, ln := range l.lines {
-		v := ln.tokens[0].value()
+		v, err := ln.tokens[0].value()
+		if err != nil {
+			return nil, err
+		}
 		ret = append(ret, v)
 	}
-	return ret
+	return ret, nil
 }
 
 func (l *ListBody) Append(o interface{}) error {
@@ -0,0 +1,34 @@
+package parser
+
+import "strings"
+
+// Comment is a line comment '// ...' or a block comment '/* ... */' in the flowl source
+type Comment struct {
+	// Text is the content of the comment without the delimiters, it's raw for the block comment
+	Text string
+	// Line and EndLine are the first and last line of the comment, they are different only if the block comment
+	// spans lines.
+	Line    int
+	EndLine int
+	Col     int
+	// Block is true if it's a block comment
+	Block bool
+	// Trailing is true if the comment follows a statement on the same line
+	Trailing bool
+}
+
+// String returns the comment with its delimiters
+func (c *Comment) String() string {
+	if c.Block {
+		return "/*" + c.Text + "*/"
+	}
+	if c.Text == "" {
+		return _kw_comment
+	}
+	return _kw_comment + " " + c.Text
+}
+
+// Trimmed returns the text of comment that trimmed the spaces
+func (c *Comment) Trimmed() string {
+	return strings.TrimSpace(c.Text)
+}
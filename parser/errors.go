@@ -3,6 +3,7 @@ package parser
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -14,13 +15,132 @@ func wrapErrorf(err error, format string, args ...interface{}) error {
 	return fmt.Errorf(builder.String()+format, args...)
 }
 
-func parseErrorf(ln int, err error, format string, args ...interface{}) error {
+// Error is an error at a position of the flowl source
+type Error struct {
+	Line int
+	// Col is the one-based column in bytes, it's 0 if the column is unknown
+	Col int
+	// Src is the text of the line, it's used to show the snippet of the error
+	Src string
+	Err error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Line == 0:
+		return e.Err.Error()
+	case e.Col == 0:
+		return strconv.Itoa(e.Line) + ": " + e.Err.Error()
+	}
+	return strconv.Itoa(e.Line) + ":" + strconv.Itoa(e.Col) + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Snippet returns the source line with a caret under the column, it returns empty if the source line is unknown.
+func (e *Error) Snippet() string {
+	if e.Src == "" {
+		return ""
+	}
+	src := strings.ReplaceAll(e.Src, "\t", " ")
+	if e.Col == 0 || e.Col > len(src)+1 {
+		return src
+	}
+	return src + "\n" + strings.Repeat(" ", e.Col-1) + "^"
+}
+
+// Format returns the error in the format 'file:line:col: message' followed by the snippet
+func (e *Error) Format(file string) string {
 	var builder strings.Builder
-	builder.WriteString(strconv.Itoa(ln))
-	builder.WriteString(": ")
-	builder.WriteString(err.Error())
-	builder.WriteString(": ")
-	return fmt.Errorf(builder.String()+format, args...)
+	builder.WriteString(file + ":" + e.Error())
+	if snippet := e.Snippet(); snippet != "" {
+		for _, l := range strings.Split(snippet, "\n") {
+			builder.WriteString("\n\t" + l)
+		}
+	}
+	return builder.String()
+}
+
+// ErrorList is the errors found in one pass of parsing, they are sorted by the position.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	var msgs []string
+	for _, e := range l {
+		msgs = append(msgs, e.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (l ErrorList) Unwrap() []error {
+	var errs []error
+	for _, e := range l {
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// Format returns the errors in the format of 'Error.Format', one error per paragraph
+func (l ErrorList) Format(file string) string {
+	var msgs []string
+	for _, e := range l {
+		msgs = append(msgs, e.Format(file))
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// add appends the error to the list, the errors that aren't the 'Error' are appended without position.
+func (l ErrorList) add(err error) ErrorList {
+	var (
+		list ErrorList
+		e    *Error
+	)
+	switch {
+	case err == nil:
+		return l
+	case errors.As(err, &list):
+		return append(l, list...)
+	case errors.As(err, &e):
+		return append(l, e)
+	}
+	return append(l, &Error{Err: err})
+}
+
+func (l ErrorList) sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		if l[i].Line != l[j].Line {
+			return l[i].Line < l[j].Line
+		}
+		return l[i].Col < l[j].Col
+	})
+}
+
+// FormatError formats the error of parsing a flowl file by 'ErrorList.Format', the other errors are returned
+// in the format 'file: message'.
+func FormatError(file string, err error) string {
+	var list ErrorList
+	if errors.As(err, &list) {
+		return list.Format(file)
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Format(file)
+	}
+	return file + ": " + err.Error()
+}
+
+func parseErrorf(ln int, err error, format string, args ...interface{}) error {
+	return posErrorf(ln, 0, err, format, args...)
+}
+
+func posErrorf(ln, col int, err error, format string, args ...interface{}) error {
+	return &Error{
+		Line: ln,
+		Col:  col,
+		Err:  fmt.Errorf("%w: "+format, append([]interface{}{err}, args...)...),
+	}
 }
 
 var (
@@ -30,18 +150,20 @@ var (
 	ErrTokenRegex            error = errors.New("token regex not match")
 	ErrTokenCharacterIllegal error = errors.New("token character illegal")
 	ErrIsKeyword             error = errors.New("token is keyword")
+	ErrCommentUnterminated   error = errors.New("comment unterminated")
+	ErrHeredocIllegal        error = errors.New("heredoc illegal")
 )
 
-func tokenErrorf(ln int, err error, format string, args ...interface{}) error {
-	return parseErrorf(ln, err, format, args...)
+func tokenErrorf(t *Token, err error, format string, args ...interface{}) error {
+	return posErrorf(t.ln, t.col, err, format, args...)
 }
 
 func tokenTypeErrorf(t *Token, expect TokenType) error {
-	return tokenErrorf(t.ln, ErrTokenType, "'%s', actual '%s', expect '%s'", t, t.typ, expect)
+	return posErrorf(t.ln, t.col, ErrTokenType, "'%s', actual '%s', expect '%s'", t, t.typ, expect)
 }
 
 func tokenValueErrorf(t *Token, expect string) error {
-	return tokenErrorf(t.ln, ErrTokenValue, "actual '%s', expect '%s'", t, expect)
+	return posErrorf(t.ln, t.col, ErrTokenValue, "actual '%s', expect '%s'", t, expect)
 }
 
 var (
@@ -51,6 +173,16 @@ var (
 	ErrStatementInferFailed error = errors.New("statement infer failed")
 	ErrStatementTooMany     error = errors.New("statement too many")
 	ErrIdentConflict        error = errors.New("ident conflict")
+	ErrIncomplete           error = errors.New("incomplete source file")
+	ErrImportIllegal        error = errors.New("import illegal")
+	ErrImportNotFound       error = errors.New("imported file not found")
+	ErrImportCycle          error = errors.New("import cycle")
+	ErrImportFailed         error = errors.New("import failed")
+	ErrRequireIllegal       error = errors.New("require illegal")
+	ErrParamIllegal         error = errors.New("param illegal")
+	ErrParamType            error = errors.New("param type not match")
+	ErrParamUnknown         error = errors.New("unknown param")
+	ErrParamMissing         error = errors.New("required params missing")
 )
 
 func statementErrorf(ln int, err error, format string, args ...interface{}) error {
@@ -68,7 +200,7 @@ func statementTokensErrorf(err error, tokens []*Token) error {
 		builder.WriteString("'" + t.String() + "'")
 		builder.WriteString(" ")
 	}
-	return parseErrorf(ln, err, "%s", builder.String())
+	return posErrorf(ln, tokens[0].col, err, "%s", builder.String())
 }
 
 var (
@@ -78,6 +210,8 @@ var (
 	ErrVariableNotDefined     error = errors.New("variable not defined")
 	ErrVariableHasCycle       error = errors.New("variable has cycle")
 	ErrVariableValueType      error = errors.New("variable's value type illegal")
+	ErrExpressionIllegal      error = errors.New("expression illegal")
+	ErrValueConvert           error = errors.New("value can't be converted")
 )
 
 func varErrorf(ln int, err error, format string, args ...interface{}) error {
@@ -0,0 +1,243 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+)
+
+// indentUnit is the indentation of one level of blocks
+const indentUnit = "    "
+
+// fmtline is a line of the flowl source to be formatted, it's a line of tokens or a comment
+type fmtline struct {
+	// ln is the line number in the source, end is the last line number of the line, they are different when
+	// the line contains a multi-line string or block comment.
+	ln, end int
+	indent  int
+	tokens  []*Token
+	text    string
+	comment *Comment
+	// trailing are the comments after the tokens
+	trailing []*Comment
+}
+
+func (l *fmtline) isComment() bool {
+	return l.comment != nil
+}
+
+func (l *fmtline) isKV() bool {
+	return len(l.tokens) >= 3 && l.tokens[0].TypeEqual(_string_t) && l.tokens[1].String() == ":"
+}
+
+func (l *fmtline) opens() bool {
+	return !l.isComment() && l.tokens[len(l.tokens)-1].String() == "{"
+}
+
+func (l *fmtline) closes() bool {
+	return !l.isComment() && l.tokens[0].String() == "}"
+}
+
+// Format formats the flowl source in the canonical style: blocks are indented by 4 spaces, tokens are separated
+// by one space, the values of maps are aligned, strings are quoted by '"', the comments and single blank lines
+// between statements are preserved. The source must be a valid flowl, the 'opts' are used to parse it.
+func Format(src []byte, opts ...Option) ([]byte, error) {
+	if _, err := New(bytes.NewReader(src), opts...); err != nil {
+		return nil, err
+	}
+	lx, err := lex(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		lines    []*fmtline
+		depth    int
+		comments = lx.comments
+	)
+	// flush appends the comments that are before the line of tokens as the comment lines
+	flush := func(ln int) {
+		for len(comments) != 0 && (comments[0].Line < ln || comments[0].Line == ln && !comments[0].Trailing) {
+			c := comments[0]
+			comments = comments[1:]
+			lines = append(lines, &fmtline{
+				ln:      c.Line,
+				end:     c.EndLine,
+				indent:  depth,
+				comment: c,
+			})
+		}
+	}
+	lx.foreachLine(func(ln int, tokens []*Token) error {
+		flush(ln)
+		l := &fmtline{
+			ln:     ln,
+			end:    ln,
+			tokens: tokens,
+		}
+		var closed int
+		for closed < len(tokens) && tokens[closed].String() == "}" {
+			closed++
+		}
+		l.indent = depth - closed
+		for _, t := range tokens {
+			switch t.String() {
+			case "{":
+				depth++
+			case "}":
+				depth--
+			}
+			if t.raw != "" {
+				l.end += strings.Count(t.raw, "\n")
+			} else if t.TypeEqual(_string_t) {
+				l.end += strings.Count(t.String(), "\n")
+			}
+		}
+		if l.indent < 0 {
+			l.indent = 0
+		}
+		for len(comments) != 0 && comments[0].Line == ln && comments[0].Trailing {
+			l.trailing = append(l.trailing, comments[0])
+			if comments[0].EndLine > l.end {
+				l.end = comments[0].EndLine
+			}
+			comments = comments[1:]
+		}
+		lines = append(lines, l)
+		return nil
+	})
+	flush(len(lx.nums) + 1)
+
+	for _, l := range lines {
+		if l.isComment() {
+			l.text = l.comment.String()
+		} else {
+			l.text = formatTokens(l.tokens)
+		}
+	}
+	alignKVs(lines)
+	for _, l := range lines {
+		for _, c := range l.trailing {
+			l.text += " " + c.String()
+		}
+	}
+
+	var buf bytes.Buffer
+	for i, l := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			if l.ln > prev.end+1 && !prev.opens() && !l.closes() {
+				buf.WriteString("\n")
+			}
+		}
+		buf.WriteString(strings.Repeat(indentUnit, l.indent))
+		buf.WriteString(l.text)
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// alignKVs aligns the values of the consecutive kv lines in the maps
+func alignKVs(lines []*fmtline) {
+	for i := 0; i < len(lines); {
+		if !lines[i].isKV() {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(lines) && lines[j].isKV() && lines[j].indent == lines[i].indent && lines[j].ln == lines[j-1].end+1 {
+			j++
+		}
+		var width int
+		for _, l := range lines[i:j] {
+			if n := len(tokenText(l.tokens[0])); n > width {
+				width = n
+			}
+		}
+		for _, l := range lines[i:j] {
+			key := tokenText(l.tokens[0]) + ":"
+			l.text = key + strings.Repeat(" ", width+2-len(key)) + formatTokens(l.tokens[2:])
+		}
+		i = j
+	}
+}
+
+// formatTokens joins the tokens of a line by one space, except the cases that the space is unnecessary, but
+// two symbols are always separated, otherwise they will be merged into one symbol by the lexer.
+func formatTokens(tokens []*Token) string {
+	tokens = splitParens(tokens)
+	var builder strings.Builder
+	for i, t := range tokens {
+		if i > 0 && needSpace(tokens, i) {
+			builder.WriteString(" ")
+		}
+		builder.WriteString(tokenText(t))
+	}
+	return builder.String()
+}
+
+// tokenText returns the text of the token in the formatted source, the strings and heredoc strings are kept as
+// they are in the source, because re-quoting them may change the escapes.
+func tokenText(t *Token) string {
+	if t.raw != "" {
+		return t.raw
+	}
+	if t.TypeEqual(_string_t) {
+		return quote(t.String())
+	}
+	return t.String()
+}
+
+// splitParens splits the parentheses out of the symbols that merged by the lexer, e.g. ')*' in '(1+2)*3'
+func splitParens(tokens []*Token) []*Token {
+	var result []*Token
+	for _, t := range tokens {
+		s := t.String()
+		if !t.TypeEqual(_symbol_t) || len(s) == 1 || !strings.ContainsAny(s, "(),") {
+			result = append(result, t)
+			continue
+		}
+		var start int
+		for i, c := range s {
+			if c != '(' && c != ')' && c != ',' {
+				continue
+			}
+			if i > start {
+				result = append(result, &Token{str: s[start:i], typ: _symbol_t, ln: t.ln})
+			}
+			result = append(result, &Token{str: string(c), typ: _symbol_t, ln: t.ln})
+			start = i + 1
+		}
+		if start < len(s) {
+			result = append(result, &Token{str: s[start:], typ: _symbol_t, ln: t.ln})
+		}
+	}
+	return result
+}
+
+func needSpace(tokens []*Token, i int) bool {
+	prev, cur := tokens[i-1], tokens[i]
+	switch {
+	case prev.String() == "(":
+		return false
+	case cur.String() == ")" || cur.String() == ",":
+		return false
+	case cur.String() == "(" && prev.TypeEqual(_ident_t):
+		// the call of function, e.g. 'upper($(name))'
+		return false
+	}
+	if prev.TypeEqual(_symbol_t) && cur.TypeEqual(_symbol_t) {
+		return true
+	}
+	switch {
+	case cur.String() == ":" && prev.TypeEqual(_string_t):
+		return false
+	case prev.String() == "-" && (i == 1 || tokens[i-2].TypeEqual(_symbol_t) && tokens[i-2].String() != ")"):
+		// unary minus, e.g. 'v <- -1'
+		return false
+	}
+	return true
+}
+
+func quote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
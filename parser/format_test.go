@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	const testingdata string = `
+// flow description
+load "go:print"
+load  "go:sleep"
+
+
+var a="hello"
+var n = ((1+2)*3)-(-1)
+var   b
+var c = replace( trim($(a)) ,"-","_")
+
+b <- "$(a) world"
+b <- -1
+
+fn p = print {
+	args = {
+	"msg":"$(b)"
+	  "x": "a \"quoted\" value"
+	}
+}
+co sleep
+co   p -> b
+
+//   loop
+for $(n) > 0 {
+co p
+}
+`
+	const expected string = `// flow description
+load "go:print"
+load "go:sleep"
+
+var a = "hello"
+var n = ((1 + 2) * 3) - (-1)
+var b
+var c = replace(trim($(a)), "-", "_")
+
+b <- "$(a) world"
+b <- -1
+
+fn p = print {
+    args = {
+        "msg": "$(b)"
+        "x":   "a \"quoted\" value"
+    }
+}
+co sleep
+co p -> b
+
+// loop
+for $(n) > 0 {
+    co p
+}
+`
+	out, err := Format([]byte(testingdata))
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, string(out))
+	}
+
+	again, err := Format(out)
+	if assert.NoError(t, err) {
+		assert.Equal(t, string(out), string(again))
+	}
+
+	_, err = Format([]byte("co\n"))
+	assert.Error(t, err)
+}
+
+func TestFormatComments(t *testing.T) {
+	const testingdata string = `/* description
+   of flow */
+load "go:print"// printer
+fn p = print {   /* alias */
+	args = {
+	"a": "b" // first
+	// between
+	"long": "c"
+	}
+// end
+}
+co p
+//tail`
+	const expected string = `/* description
+   of flow */
+load "go:print" // printer
+fn p = print { /* alias */
+    args = {
+        "a": "b" // first
+        // between
+        "long": "c"
+    }
+    // end
+}
+co p
+// tail
+`
+	out, err := Format([]byte(testingdata))
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, string(out))
+	}
+	again, err := Format(out)
+	if assert.NoError(t, err) {
+		assert.Equal(t, string(out), string(again))
+	}
+}
+
+func TestFormatBackslash(t *testing.T) {
+	const testingdata string = `load "go:print"
+var path = "C:\tmp\\cofunc\"s"
+var esc = "\$(path) \\$(path)"
+
+co print {
+    "a\\b": "$(path)"
+    "c":    "\"$(esc)\"\\"
+}
+`
+	out, err := Format([]byte(testingdata))
+	if !assert.NoError(t, err) {
+		return
+	}
+	// the strings are kept as they are in the source
+	assert.Equal(t, testingdata, string(out))
+
+	// the formatted source has the same values as the original one
+	values := func(src string) map[string]string {
+		ast, err := New(strings.NewReader(src))
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		vars := make(map[string]string)
+		for _, name := range []string{"path", "esc"} {
+			vars[name] = ast.Global().GetVarValue(name)
+		}
+		return vars
+	}
+	assert.Equal(t, values(testingdata), values(string(out)))
+}
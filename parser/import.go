@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cofunclabs/cofunc/config"
+)
+
+// Option configures the parsing of a flowl source
+type Option func(*AST)
+
+// WithFile sets the path of the flowl source file, the relative paths of 'import' are resolved from its directory.
+// If the reader of source has the method 'Name() string', e.g. *os.File, its name is used by default.
+func WithFile(name string) Option {
+	return func(ast *AST) {
+		ast.file = name
+	}
+}
+
+// parseImport parses the 'import' statement, the imported file is parsed to a separate AST, then its 'load', 'fn'
+// and global 'var' are merged into the AST under the namespace, e.g.:
+//
+//	import "common/go.flowl"            // the namespace is 'go'
+//	import "common/go.flowl" as golang  // the namespace is 'golang'
+//
+// The fns are renamed to 'namespace.name', and the variables are accessed by '$(namespace.name)', the other
+// statements of the imported file aren't merged.
+func (ast *AST) parseImport(line []*Token, ln int, parent *Block) error {
+	b := &Block{
+		child:  []*Block{},
+		parent: parent,
+		vtbl:   vartable{vars: make(map[string]*_var)},
+	}
+	if _, err := ast.preparse("import", line, ln, b); err != nil {
+		return err
+	}
+	if len(line) == 3 {
+		return statementTokensErrorf(ErrImportIllegal, line)
+	}
+	path := line[1].String()
+
+	var ns *Token
+	if len(line) == 4 {
+		ns = line[3]
+		if err := ns.validate(); err != nil {
+			return err
+		}
+	} else {
+		ns = &Token{
+			str: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+			typ: _varname_t,
+			ln:  ln,
+		}
+		if err := ns.validate(); err != nil {
+			return tokenErrorf(line[1], ErrImportIllegal, "the namespace '%s' of '%s' isn't a valid name, use 'as' to rename it", ns, path)
+		}
+	}
+
+	file, err := ast.resolveImport(path)
+	if err != nil {
+		return tokenErrorf(line[1], err, "'%s'", path)
+	}
+	for _, f := range append([]string{ast.absFile()}, ast.importing...) {
+		if f == file {
+			return tokenErrorf(line[1], ErrImportCycle, "'%s'", path)
+		}
+	}
+
+	imported, err := ast.parseImportedFile(file)
+	if err != nil {
+		return tokenErrorf(line[1], ErrImportFailed, "'%s': %s", path, strings.ReplaceAll(err.Error(), "\n", "; "))
+	}
+
+	// The global block of the imported file becomes the 'import' block, so the imported fns still reference
+	// the variables of the imported file
+	b = &imported.global
+	b.kind = *line[0]
+	b.target1 = *line[1]
+	if len(line) == 4 {
+		b.operator = *line[2]
+	}
+	b.target2 = *ns
+	b.parent = parent
+
+	pruneImported(b)
+
+	var fns []*Block
+	deepwalk(b, func(blk *Block) error {
+		if blk.IsFn() {
+			fns = append(fns, blk)
+		}
+		return nil
+	})
+	for _, fn := range fns {
+		name := ns.String() + "." + fn.target1.String()
+		if _, ok := ast.fns[name]; ok {
+			return parseErrorf(ln, ErrIdentConflict, "duplicate definition of fn '%s'", name)
+		}
+		ast.fns[name] = true
+		fn.target1.str = name
+	}
+
+	if err := parent.addVar(ns.String(), &_var{ns: b}); err != nil {
+		return tokenErrorf(b.Target2(), ErrIdentConflict, "namespace '%s' of '%s' is already defined", ns, path)
+	}
+	parent.child = append(parent.child, b)
+	return nil
+}
+
+// resolveImport returns the absolute path of the imported file, the relative path is resolved from the directory
+// of the importing file first, then from the flow source directory.
+func (ast *AST) resolveImport(path string) (string, error) {
+	var candidates []string
+	if filepath.IsAbs(path) {
+		candidates = append(candidates, path)
+	} else {
+		candidates = append(candidates, filepath.Join(filepath.Dir(ast.file), path), filepath.Join(config.FlowSourceDir(), path))
+	}
+	for _, c := range candidates {
+		if fi, err := os.Stat(c); err == nil && !fi.IsDir() {
+			return filepath.Abs(c)
+		}
+	}
+	return "", ErrImportNotFound
+}
+
+func (ast *AST) parseImportedFile(file string) (*AST, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	imported := newast()
+	imported.file = file
+	imported.importing = append(append([]string{}, ast.importing...), ast.absFile())
+	return imported.parse(f)
+}
+
+// absFile returns the absolute path of the flowl source file, it returns empty if the file is unknown.
+func (ast *AST) absFile() string {
+	if ast.file == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(ast.file)
+	if err != nil {
+		return ast.file
+	}
+	return abs
+}
+
+// pruneImported removes the statements that aren't shared by the imported file
+func pruneImported(b *Block) {
+	var child []*Block
+	for _, c := range b.child {
+		if c.IsLoad() || c.IsFn() || c.IsImport() {
+			child = append(child, c)
+		}
+	}
+	b.child = child
+}
+
+// dedupLoads removes the loads of the imported files that are already loaded by the flow or the other imported
+// files, so a function is loaded only once.
+func (ast *AST) dedupLoads() {
+	loaded := make(map[string]bool)
+	ast.Foreach(func(b *Block) error {
+		if b.IsLoad() && !b.InImport() {
+			loaded[b.target1.String()] = true
+		}
+		return nil
+	})
+	var dedup func(b *Block)
+	dedup = func(b *Block) {
+		var child []*Block
+		for _, c := range b.child {
+			if c.IsLoad() && b.IsImport() {
+				if loaded[c.target1.String()] {
+					continue
+				}
+				loaded[c.target1.String()] = true
+			}
+			if c.IsImport() {
+				dedup(c)
+			}
+			child = append(child, c)
+		}
+		b.child = child
+	}
+	dedup(&ast.global)
+}
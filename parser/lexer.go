@@ -2,7 +2,9 @@
 package parser
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 
@@ -20,6 +22,8 @@ const (
 	_lx_string_backslash
 	_lx_var_directuse1
 	_lx_var_directuse2
+	_lx_comment_block
+	_lx_heredoc
 )
 
 type lexer struct {
@@ -28,16 +32,47 @@ type lexer struct {
 	state     lexstate
 	buf       strings.Builder
 	stringNum int
+	// quoted is the source text of the string that being lexed, the escapes are kept
+	quoted strings.Builder
+	// pos is the byte offset of the current character in the line, col is the column of the current token
+	pos int
+	col int
+	// src stores the text of lines, it's used to show the snippet of errors
+	src map[int]string
+	// broken stores the lines that have errors, the tokens of them are discarded
+	broken map[int]bool
+	errs   ErrorList
+	// comments stores the comments in the order of source, they aren't the tokens of lines
+	comments []*Comment
+	// here is the heredoc string that being lexed
+	here *heredoc
+}
+
+// heredoc is a multi-line string that starts with '<<EOF' or '<<-EOF' and ends with the line that only contains
+// the terminator 'EOF', the '<<-' strips the common indentation of the lines.
+type heredoc struct {
+	term  string
+	strip bool
+	ln    int
+	col   int
+	lines []string
+	// raw is the source text from the '<<' to the terminator line
+	raw strings.Builder
 }
 
 func newLexer() *lexer {
 	return &lexer{
-		tt:    make(map[int][]*Token),
-		state: _lx_unknow,
+		tt:     make(map[int][]*Token),
+		state:  _lx_unknow,
+		src:    make(map[int]string),
+		broken: make(map[int]bool),
 	}
 }
 
 func (l *lexer) save(r rune) {
+	if l.buf.Len() == 0 && l.state != _lx_string && l.state != _lx_string_backslash {
+		l.col = l.pos + 1
+	}
 	l.buf.WriteRune(r)
 }
 
@@ -48,6 +83,13 @@ func (l *lexer) export() string {
 }
 
 func (l *lexer) insert(num int, t *Token) {
+	t.ln = num
+	if t.col == 0 {
+		t.col = l.col
+	}
+	if t.end == 0 {
+		t.end = l.pos + 1
+	}
 	_, ok := l.tt[num]
 	if !ok {
 		l.tt[num] = make([]*Token, 0)
@@ -73,8 +115,15 @@ func (l *lexer) split(line string, ln int, eof bool) error {
 		line += "\n"
 	}
 	l.nums = append(l.nums, ln)
+	l.src[ln] = strings.TrimRight(line, "\r\n")
+
+	if l.state == _lx_heredoc {
+		l.continueHeredoc(l.src[ln])
+		return nil
+	}
 
 	for pos, c := range line {
+		l.pos = pos
 		switch l.state {
 		case _lx_unknow:
 			if is.Space(c) || is.EOL(c) {
@@ -93,6 +142,9 @@ func (l *lexer) split(line string, ln int, eof bool) error {
 			// string
 			if is.Quotation(c) {
 				l.stringNum = ln
+				l.col = pos + 1
+				l.quoted.Reset()
+				l.quoted.WriteRune(c)
 				l._goto(_lx_string)
 				break
 			}
@@ -102,29 +154,47 @@ func (l *lexer) split(line string, ln int, eof bool) error {
 				l._goto(_lx_var_directuse1)
 				break
 			}
-			return parseErrorf(ln, ErrTokenCharacterIllegal, "character '%c', state '%s'", c, l.state)
+			return posErrorf(ln, pos+1, ErrTokenCharacterIllegal, "character '%c', state '%s'", c, l.state)
 		case _lx_symbol:
 			if is.Symbol(c) {
 				l.save(c)
+				// Here is special handling of comments, the symbols before the comment are a token
+				if s := l.buf.String(); strings.HasSuffix(s, "//") || strings.HasSuffix(s, "/*") {
+					l.buf.Reset()
+					if prefix := s[:len(s)-2]; prefix != "" {
+						l.buf.WriteString(prefix)
+						l.pos = pos - 1
+						l.insert(ln, &Token{
+							str: l.export(),
+							typ: _symbol_t,
+						})
+					}
+					comment := &Comment{
+						Line:     ln,
+						EndLine:  ln,
+						Col:      pos,
+						Trailing: len(l.get(ln)) != 0,
+					}
+					l.comments = append(l.comments, comment)
+					if strings.HasSuffix(s, "/*") {
+						comment.Block = true
+						l._goto(_lx_comment_block)
+						break
+					}
+					// the remaining characters on the current line are the comment
+					comment.Text = strings.TrimSpace(line[pos+1:])
+					l._goto(_lx_unknow)
+					return nil
+				}
 				break
 			}
+			if s := l.buf.String(); (strings.HasSuffix(s, "<<") || strings.HasSuffix(s, "<<-")) && (is.Letter(c) || c == '_') && l.atValue(ln, s) {
+				return l.startHeredoc(ln, pos, line)
+			}
 			l.insert(ln, &Token{
 				str: l.export(),
 				typ: _symbol_t,
 			})
-			// Here is special handling of comments, because a line comment can contain unicode character
-			if ts := l.get(ln); ts != nil {
-				if len(ts) == 1 && ts[0].String() == "//" {
-					// save the remaining characters on the current line as comment
-					l.insert(ln, &Token{
-						str: strings.TrimSpace(line[pos:]),
-						typ: _string_t,
-					})
-					l._goto(_lx_unknow)
-					return nil
-				}
-			}
-
 			if is.Space(c) || is.EOL(c) {
 				l._goto(_lx_unknow)
 				break
@@ -136,6 +206,9 @@ func (l *lexer) split(line string, ln int, eof bool) error {
 			}
 			if is.Quotation(c) {
 				l.stringNum = ln
+				l.col = pos + 1
+				l.quoted.Reset()
+				l.quoted.WriteRune(c)
 				l._goto(_lx_string)
 				break
 			}
@@ -144,7 +217,7 @@ func (l *lexer) split(line string, ln int, eof bool) error {
 				l._goto(_lx_var_directuse1)
 				break
 			}
-			return parseErrorf(ln, ErrTokenCharacterIllegal, "character '%c', state '%s'", c, l.state)
+			return posErrorf(ln, pos+1, ErrTokenCharacterIllegal, "character '%c', state '%s'", c, l.state)
 		case _lx_ident:
 			if is.Ident(c) {
 				l.save(c)
@@ -170,8 +243,9 @@ func (l *lexer) split(line string, ln int, eof bool) error {
 				l._goto(_lx_symbol)
 				break
 			}
-			return parseErrorf(ln, ErrTokenCharacterIllegal, "character '%c', state '%s'", c, l.state)
+			return posErrorf(ln, pos+1, ErrTokenCharacterIllegal, "character '%c', state '%s'", c, l.state)
 		case _lx_string:
+			l.quoted.WriteRune(c)
 			if is.BackSlash(c) {
 				l._goto(_lx_string_backslash)
 				break
@@ -181,12 +255,15 @@ func (l *lexer) split(line string, ln int, eof bool) error {
 				l.insert(l.stringNum, &Token{
 					str: l.export(),
 					typ: _string_t,
+					end: pos + 2,
+					raw: l.quoted.String(),
 				})
 				l._goto(_lx_unknow)
 				break
 			}
 			l.save(c)
 		case _lx_string_backslash:
+			l.quoted.WriteRune(c)
 			if !is.Quotation(c) {
 				l.save('\\')
 			}
@@ -198,9 +275,11 @@ func (l *lexer) split(line string, ln int, eof bool) error {
 				l._goto(_lx_var_directuse2)
 				break
 			}
-			return parseErrorf(ln, ErrTokenCharacterIllegal, "character '%c', state '%s'", c, l.state)
+			return posErrorf(ln, pos+1, ErrTokenCharacterIllegal, "character '%c', state '%s'", c, l.state)
 		case _lx_var_directuse2:
-			if is.Ident(c) {
+			// the default value of env follows ':-', e.g. '$(env.BUILD:-false)', the path of the field is in
+			// '[]', e.g. '$(out.artifacts[0].path)'
+			if is.Ident(c) || c == ':' || c == '[' || c == ']' || c != ')' && strings.Contains(l.buf.String(), ":") {
 				l.save(c)
 				break
 			}
@@ -209,16 +288,189 @@ func (l *lexer) split(line string, ln int, eof bool) error {
 				l.insert(ln, &Token{
 					str: l.export(),
 					typ: _refvar_t,
+					end: pos + 2,
 				})
 				l._goto(_lx_unknow)
 				break
 			}
-			return parseErrorf(ln, ErrTokenCharacterIllegal, "character '%c', state '%s'", c, l.state)
+			return posErrorf(ln, pos+1, ErrTokenCharacterIllegal, "character '%c', state '%s'", c, l.state)
+		case _lx_comment_block:
+			if c == '/' && strings.HasSuffix(l.buf.String(), "*") {
+				comment := l.comments[len(l.comments)-1]
+				s := l.export()
+				comment.Text = s[:len(s)-1]
+				comment.EndLine = ln
+				l._goto(_lx_unknow)
+				break
+			}
+			l.buf.WriteRune(c)
+		}
+	}
+	return nil
+}
+
+// lex splits the flowl source into tokens line by line, the errors of lexing are saved in 'lexer.errs', the
+// returned error is only the error of reading.
+func lex(rd io.Reader) (*lexer, error) {
+	lx := newLexer()
+	buff := bufio.NewReader(rd)
+	for n := 1; ; n += 1 {
+		line, err := buff.ReadString('\n')
+		if err == io.EOF {
+			if len(line) != 0 {
+				if err := lx.split(line, n, true); err != nil {
+					lx.recover(n, err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := lx.split(line, n, false); err != nil {
+			lx.recover(n, err)
+		}
+	}
+	if lx.state == _lx_heredoc {
+		h := lx.here
+		lx.errs = lx.errs.add(posErrorf(h.ln, h.col, ErrHeredocIllegal, "the terminator '%s' isn't found", h.term))
+	}
+	if lx.state == _lx_comment_block {
+		c := lx.comments[len(lx.comments)-1]
+		lx.errs = lx.errs.add(posErrorf(c.Line, c.Col, ErrCommentUnterminated, "'/*' isn't closed by '*/'"))
+	}
+	return lx, nil
+}
+
+// valueSymbols are the symbols that a value follows
+var valueSymbols = []string{"=", ":", "<-", "->"}
+
+// atValue returns true if the symbols that end with '<<' or '<<-' are at the position of a value, so they start a
+// heredoc. In the other positions, the '<<' is the shift operator of expressions, e.g. '1<<x' or '$(a) <<b'.
+func (l *lexer) atValue(ln int, symbols string) bool {
+	prefix := strings.TrimSuffix(strings.TrimSuffix(symbols, "-"), "<<")
+	if prefix == "" {
+		// The symbol before the '<<' has been a token, e.g. 'var s = <<EOF'
+		tokens := l.get(ln)
+		if len(tokens) == 0 || tokens[len(tokens)-1].typ != _symbol_t {
+			return false
 		}
+		prefix = tokens[len(tokens)-1].str
+	}
+	for _, s := range valueSymbols {
+		if prefix == s {
+			return true
+		}
+	}
+	return false
+}
+
+// startHeredoc starts a heredoc string at the terminator, the symbols before the '<<' are a token.
+func (l *lexer) startHeredoc(ln, pos int, line string) error {
+	s := l.export()
+	op := "<<"
+	if strings.HasSuffix(s, "-") {
+		op = "<<-"
+	}
+	col := pos + 1 - len(op)
+	if prefix := s[:len(s)-len(op)]; prefix != "" {
+		l.insert(ln, &Token{
+			str: prefix,
+			typ: _symbol_t,
+			end: col,
+		})
+	}
+
+	end := pos
+	for end < len(line) && is.Ident(rune(line[end])) {
+		end++
+	}
+	term := line[pos:end]
+	if rest := strings.TrimSpace(line[end:]); rest != "" {
+		return posErrorf(ln, end+1, ErrHeredocIllegal, "unexpected '%s' after the terminator '%s'", rest, term)
+	}
+	l.here = &heredoc{
+		term:  term,
+		strip: op == "<<-",
+		ln:    ln,
+		col:   col,
 	}
+	l.here.raw.WriteString(op + term)
+	l._goto(_lx_heredoc)
 	return nil
 }
 
+// continueHeredoc saves the line into the heredoc string, the string is inserted as a token at the line of '<<'
+// when the line is the terminator.
+func (l *lexer) continueHeredoc(line string) {
+	h := l.here
+	h.raw.WriteString("\n" + line)
+	if strings.TrimSpace(line) != h.term {
+		h.lines = append(h.lines, line)
+		return
+	}
+	lines := h.lines
+	if h.strip {
+		lines = dedent(lines)
+	}
+	l.insert(h.ln, &Token{
+		str: strings.Join(lines, "\n"),
+		typ: _string_t,
+		col: h.col,
+		end: len(line) + 1,
+		raw: h.raw.String(),
+	})
+	l.here = nil
+	l._goto(_lx_unknow)
+}
+
+// dedent removes the common leading spaces and tabs of the non-blank lines
+func dedent(lines []string) []string {
+	var prefix string
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = strings.TrimPrefix(line, prefix)
+		if strings.TrimSpace(line) == "" {
+			result[i] = ""
+		}
+	}
+	return result
+}
+
+// fill sorts the errors and fills the source lines of them
+func (l *lexer) fill(errs ErrorList) ErrorList {
+	errs.sort()
+	for _, e := range errs {
+		if e.Src == "" {
+			e.Src = l.src[e.Line]
+		}
+	}
+	return errs
+}
+
+// recover discards the tokens of the broken line and resets the state, so the lexing can continue at the next line.
+func (l *lexer) recover(ln int, err error) {
+	l.errs = l.errs.add(err)
+	l.broken[ln] = true
+	l.tt[ln] = []*Token{}
+	l.buf.Reset()
+	l._goto(_lx_unknow)
+}
+
 func (l *lexer) foreachLine(do func(int, []*Token) error) error {
 	for _, n := range l.nums {
 		line, ok := l.tt[n]
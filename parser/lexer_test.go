@@ -10,10 +10,7 @@ import (
 )
 
 func loadTestingdataForLexer(testingdata string) (*lexer, error) {
-	lx := &lexer{
-		tt:    make(map[int][]*Token),
-		state: _lx_unknow,
-	}
+	lx := newLexer()
 
 	buff := bufio.NewReader(strings.NewReader(testingdata))
 	for i := 1; ; i += 1 {
@@ -132,3 +129,28 @@ world"`
 	})
 	assert.NoError(t, err)
 }
+
+func TestLexerColumns(t *testing.T) {
+	testingdata := `var a = "hello"
+	co print -> $(a)
+`
+	lx, err := loadTestingdataForLexer(testingdata)
+	assert.NoError(t, err)
+
+	type span struct {
+		str      string
+		col, end int
+	}
+	var spans []span
+	lx.foreachLine(func(ln int, tokens []*Token) error {
+		for _, tk := range tokens {
+			col, end := tk.Span()
+			spans = append(spans, span{tk.String(), col, end})
+		}
+		return nil
+	})
+	assert.Equal(t, []span{
+		{"var", 1, 4}, {"a", 5, 6}, {"=", 7, 8}, {"hello", 9, 16},
+		{"co", 2, 4}, {"print", 5, 10}, {"->", 11, 13}, {"$(a)", 14, 18},
+	}, spans)
+}
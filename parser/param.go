@@ -0,0 +1,213 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The types of params, the default type is string
+const (
+	ParamString = "string"
+	ParamInt    = "int"
+	ParamFloat  = "float"
+	ParamBool   = "bool"
+)
+
+// Param is a parameter of the flow declared by the 'param' statement, its value is passed when the flow is run
+type Param struct {
+	Name string
+	Type string
+	// Default is the default value, it's empty if the param is required
+	Default  string
+	Required bool
+	// Desc is the description from the comments of the statement
+	Desc string
+	Line int
+}
+
+// parseParam parses the 'param' statement, the param is a global variable that its value can be passed when
+// running the flow, e.g.:
+//
+//	param version = "1.0"      // the default value is "1.0"
+//	param count int = 3
+//	param target               // the param is required
+func (ast *AST) parseParam(line []*Token, ln int, parent *Block) error {
+	b := &Block{
+		child:  []*Block{},
+		parent: parent,
+		vtbl:   vartable{vars: make(map[string]*_var)},
+	}
+	body, err := ast.preparse("param", line, ln, b)
+	if err != nil {
+		return err
+	}
+	b.body = body
+	b.kind = *line[0]
+	b.target1 = *line[1]
+
+	rest := line[2:]
+	if len(rest) != 0 && rest[0].TypeEqual(_ident_t) {
+		typ := rest[0]
+		switch typ.String() {
+		case ParamString, ParamInt, ParamFloat, ParamBool:
+		default:
+			return tokenErrorf(typ, ErrParamType, "'%s', expect one of string, int, float and bool", typ)
+		}
+		b.target2 = *typ
+		rest = rest[1:]
+	}
+
+	var def *Token
+	switch len(rest) {
+	case 0:
+	case 2:
+		if rest[0].String() != "=" {
+			return tokenValueErrorf(rest[0], "=")
+		}
+		def = rest[1]
+		if !def.TypeEqual(_string_t, _number_t) || def.hasVar() {
+			return tokenErrorf(def, ErrParamIllegal, "the default value '%s' must be a string or number", def)
+		}
+		if err := checkParamType(b.paramType(), def.String()); err != nil {
+			return tokenErrorf(def, err, "default value of param '%s'", b.target1.String())
+		}
+		if err := b.body.Append([]*Token{def}); err != nil {
+			return err
+		}
+	default:
+		return statementTokensErrorf(ErrParamIllegal, line)
+	}
+
+	stm := NewStatement("var").Append(&b.target1)
+	if def != nil {
+		stm.Append(def)
+	}
+	if err := parent.initVar(stm); err != nil {
+		return err
+	}
+	if def != nil {
+		// the default value has the type of param, e.g. 'param debug bool = "false"' is a boolean
+		if err := parent.SetValue(b.target1.String(), paramValue(b.paramType(), def.String())); err != nil {
+			return err
+		}
+	}
+	parent.child = append(parent.child, b)
+	return nil
+}
+
+func (b *Block) paramType() string {
+	if b.target2.IsEmpty() {
+		return ParamString
+	}
+	return b.target2.String()
+}
+
+// Params returns the params of the flow in the order of declaration
+func (ast *AST) Params() []Param {
+	var params []Param
+	for _, b := range ast.global.child {
+		if !b.IsParam() {
+			continue
+		}
+		p := Param{
+			Name: b.target1.String(),
+			Type: b.paramType(),
+			Line: b.target1.ln,
+		}
+		// the default value is a literal, it has no variables to calculate
+		if defaults, _ := b.body.(*ListBody).ToSlice(); len(defaults) != 0 {
+			p.Default = defaults[0]
+		} else {
+			p.Required = true
+		}
+		var desc []string
+		for _, c := range b.comments {
+			if s := c.Trimmed(); s != "" {
+				desc = append(desc, s)
+			}
+		}
+		p.Desc = strings.Join(desc, " ")
+		params = append(params, p)
+	}
+	return params
+}
+
+// BindParams sets the values of params, the values are checked by the types of params. All required params
+// without values are reported at once, the params without values are reset to their default values.
+func (ast *AST) BindParams(values map[string]string) error {
+	params := make(map[string]Param)
+	for _, p := range ast.Params() {
+		params[p.Name] = p
+	}
+
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p, ok := params[name]
+		if !ok {
+			return fmt.Errorf("%w: '%s'", ErrParamUnknown, name)
+		}
+		if err := checkParamType(p.Type, values[name]); err != nil {
+			return fmt.Errorf("%w: param '%s'", err, name)
+		}
+	}
+
+	var missing []string
+	for _, p := range ast.Params() {
+		if _, ok := values[p.Name]; !ok && p.Required {
+			missing = append(missing, p.Name)
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("%w: %s", ErrParamMissing, strings.Join(missing, ", "))
+	}
+
+	for _, p := range ast.Params() {
+		val, ok := values[p.Name]
+		if !ok {
+			// the values of the last binding are dropped when the flow is initialized again
+			val = p.Default
+		}
+		if err := ast.global.SetValue(p.Name, paramValue(p.Type, val)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// paramValue converts the checked value of param to the value of its type
+func paramValue(typ, val string) Value {
+	switch typ {
+	case ParamInt:
+		i, _ := strconv.ParseInt(val, 10, 64)
+		return IntValue(i)
+	case ParamFloat:
+		f, _ := strconv.ParseFloat(val, 64)
+		return FloatValue(f)
+	case ParamBool:
+		b, _ := strconv.ParseBool(val)
+		return BoolValue(b)
+	}
+	return StringValue(val)
+}
+
+func checkParamType(typ, val string) error {
+	var err error
+	switch typ {
+	case ParamInt:
+		_, err = strconv.Atoi(val)
+	case ParamFloat:
+		_, err = strconv.ParseFloat(val, 64)
+	case ParamBool:
+		_, err = strconv.ParseBool(val)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: '%s' isn't %s", ErrParamType, val, typ)
+	}
+	return nil
+}
@@ -2,10 +2,9 @@
 package parser
 
 import (
-	"bufio"
-	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
 	"github.com/cofunclabs/cofunc/pkg/enabled"
@@ -36,6 +35,27 @@ var statementPatterns = map[string]struct {
 	uptypes []TokenType
 	newbody func() body
 }{
+	"import": {
+		2, 4,
+		[]TokenType{_ident_t, _string_t, _ident_t, _ident_t},
+		[]string{_kw_import, "", "as", ""},
+		[]TokenType{_keyword_t, _string_t, _keyword_t, _varname_t},
+		nil,
+	},
+	"require": {
+		3, 255,
+		[]TokenType{_ident_t, _ident_t},
+		[]string{_kw_require, "env"},
+		[]TokenType{_keyword_t, _keyword_t},
+		func() body { return &ListBody{etype: _envname_t} },
+	},
+	"param": {
+		2, 5,
+		[]TokenType{_ident_t, _ident_t},
+		[]string{_kw_param, ""},
+		[]TokenType{_keyword_t, _varname_t},
+		func() body { return &ListBody{etype: _string_t} },
+	},
 	"load": {
 		2, 2,
 		[]TokenType{_ident_t, _string_t},
@@ -175,6 +195,12 @@ type AST struct {
 	// We use the first line comment in the flowl file as the description of the flow
 	desc string
 
+	// file is the path of the flowl source file, it may be empty
+	file string
+	// importing is the absolute paths of the files that import this file directly or indirectly, it's used to
+	// detect the import cycle
+	importing []string
+
 	// for parsing
 	_InferTree *inferNode
 	_FA
@@ -183,33 +209,32 @@ type AST struct {
 	cos []string
 }
 
-func New(rd io.Reader) (*AST, error) {
-	lx := newLexer()
-	buff := bufio.NewReader(rd)
-	for n := 1; ; n += 1 {
-		line, err := buff.ReadString('\n')
-		if err == io.EOF {
-			if len(line) != 0 {
-				if err := lx.split(line, n, true); err != nil {
-					return nil, err
-				}
-			}
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-		if err := lx.split(line, n, false); err != nil {
-			return nil, err
-		}
+// New parses the flowl source to AST, the parser recovers from the errors at the statement boundaries, so all
+// errors are reported in one pass as an 'ErrorList'.
+func New(rd io.Reader, opts ...Option) (*AST, error) {
+	ast := newast()
+	if f, ok := rd.(interface{ Name() string }); ok {
+		ast.file = f.Name()
+	}
+	for _, opt := range opts {
+		opt(ast)
+	}
+	return ast.parse(rd)
+}
+
+func (ast *AST) parse(rd io.Reader) (*AST, error) {
+	lx, err := lex(rd)
+	if err != nil {
+		return nil, err
 	}
 
 	lx.debug()
 
-	ast := newast()
-	if err := ast.scan(lx); err != nil {
-		return nil, err
+	errs := lx.errs.add(ast.scan(lx))
+	if len(errs) != 0 {
+		return nil, lx.fill(errs)
 	}
+	ast.dedupLoads()
 
 	if enabled.Debug() {
 		ast.Foreach(func(b *Block) error {
@@ -218,7 +243,10 @@ func New(rd io.Reader) (*AST, error) {
 		})
 	}
 
-	return ast, ast.validate()
+	if errs := errs.add(ast.validate()); len(errs) != 0 {
+		return ast, lx.fill(errs)
+	}
+	return ast, nil
 }
 
 func newast() *AST {
@@ -227,7 +255,7 @@ func newast() *AST {
 			kind: Token{
 				str: "global",
 			},
-			vtbl: vartable{vars: map[string]*_var{"env": newEnvVar()}},
+			vtbl: vartable{vars: map[string]*_var{"env": newEnvVar(), "secret": newSecretVar()}},
 			body: &plainbody{},
 		},
 		_FA: _FA{
@@ -263,6 +291,90 @@ func (ast *AST) GetBlocks() (loads []*Block, fns []*Block, runs []*Block) {
 	return
 }
 
+// RequiredEnv returns the environment variables declared by the 'require env' statements, the duplicate names are
+// removed.
+func (ast *AST) RequiredEnv() []string {
+	var (
+		names []string
+		seen  = make(map[string]bool)
+	)
+	ast.Foreach(func(b *Block) error {
+		if !b.IsRequire() {
+			return nil
+		}
+		// the names of env are identifiers, they have no variables to calculate
+		list, _ := b.body.(*ListBody).ToSlice()
+		for _, name := range list {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		return nil
+	})
+	return names
+}
+
+// BindSecrets sets the values of secrets referenced by '$(secret.NAME)', the secrets are resolved by the runtime
+// before running the flow, the secrets without values are empty.
+func (ast *AST) BindSecrets(values map[string]string) {
+	if v, ok := ast.global.vtbl.get("secret"); ok {
+		v.resetFields(values)
+	}
+}
+
+// SecretRefs returns the names of secrets referenced by '$(secret.NAME)' in the flow, the duplicate names are
+// removed.
+func (ast *AST) SecretRefs() []string {
+	var (
+		names []string
+		seen  = make(map[string]bool)
+	)
+	ast.Foreach(func(b *Block) error {
+		for _, ref := range b.varRefs() {
+			if ref.secret && !seen[ref.Field] {
+				seen[ref.Field] = true
+				names = append(names, ref.Field)
+			}
+		}
+		return nil
+	})
+	return names
+}
+
+// VarRefs returns all references to variables in the flow, they are sorted by the line number.
+func (ast *AST) VarRefs() []VarRef {
+	var (
+		refs []VarRef
+		seen = make(map[VarRef]bool)
+	)
+	ast.Foreach(func(b *Block) error {
+		for _, ref := range b.varRefs() {
+			if !seen[ref] && !ref.secret {
+				seen[ref] = true
+				refs = append(refs, ref)
+			}
+		}
+		return nil
+	})
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refs[i].Line < refs[j].Line
+	})
+	return refs
+}
+
+// FieldRefs returns all references to the fields of variables in the flow, e.g. '$(out.now)', they are used to
+// check the return values of functions.
+func (ast *AST) FieldRefs() []VarRef {
+	var refs []VarRef
+	for _, ref := range ast.VarRefs() {
+		if ref.Field != "" {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
 func (ast *AST) Foreach(do func(*Block) error) error {
 	return deepwalk(&ast.global, do)
 }
@@ -279,29 +391,40 @@ func deepwalk(b *Block, do func(*Block) error) error {
 	return nil
 }
 
+// scan parses the lines to blocks, when a statement has error, the parsing continues at the next statement, and
+// the block opened by the statement is skipped, all errors are returned as an 'ErrorList'.
 func (ast *AST) scan(lx *lexer) error {
-	var parsingblock = &ast.global
+	var (
+		parsingblock = &ast.global
+		errs         ErrorList
+		// skipping is the depth of the skipped block that opened by an error statement
+		skipping int
+	)
 
-	err := lx.foreachLine(func(ln int, line []*Token) error {
+	parseLine := func(ln int, line []*Token) error {
 		if len(line) == 0 {
 			return nil
 		}
-		// the line is a commment
-		if line[0].String() == _kw_comment {
-			// save the first line comment as the description of the flow
-			if ast.desc == "" && len(ast.global.child) == 0 && len(line) > 1 {
-				ast.desc = line[1].String()
+		switch ast.phase() {
+		case _ast_co_body, _ast_args_body:
+		default:
+			// the params are bound before running the flow, so they can only be declared in the global scope
+			if kind := line[0]; kind.String() == _kw_param && parsingblock != &ast.global {
+				return tokenErrorf(kind, ErrParamIllegal, "'param' must be declared in the global scope")
 			}
-			// discard the other line comments
-			return nil
 		}
-
 		switch ast.phase() {
 		case _ast_global:
 			kind := line[0]
 			switch kind.String() {
 			case _kw_load:
 				return ast.parseLoad(line, ln, parsingblock)
+			case _kw_import:
+				return ast.parseImport(line, ln, parsingblock)
+			case _kw_require:
+				return ast.parseRequire(line, ln, parsingblock)
+			case _kw_param:
+				return ast.parseParam(line, ln, parsingblock)
 			case _kw_fn:
 				block, err := ast.parseFn(line, ln, parsingblock)
 				if err != nil {
@@ -431,16 +554,81 @@ func (ast *AST) scan(lx *lexer) error {
 			parsingblock = block
 		}
 		return nil
+	}
+
+	// comments are the comments that haven't been attached to blocks
+	comments := lx.comments
+	takeComments := func(ln int) []*Comment {
+		var taken []*Comment
+		for len(comments) != 0 && comments[0].Line <= ln {
+			c := comments[0]
+			comments = comments[1:]
+			// save the first comment as the description of the flow
+			if ast.desc == "" && len(ast.global.child) == 0 && !c.Trailing && c.Trimmed() != "" {
+				ast.desc = c.Trimmed()
+			}
+			taken = append(taken, c)
+		}
+		return taken
+	}
+
+	lx.foreachLine(func(ln int, line []*Token) error {
+		taken := takeComments(ln)
+		current, n := parsingblock, len(parsingblock.child)
+		defer func() {
+			// the comments are attached to the block opened or added by the statement, otherwise they are
+			// attached to the current block, e.g. the comments of 'var' statement or the closing '}'
+			target := current
+			switch {
+			case parsingblock != current && parsingblock.parent == current:
+				target = parsingblock
+			case len(current.child) > n:
+				target = current.child[len(current.child)-1]
+			}
+			target.comments = append(target.comments, taken...)
+		}()
+
+		if skipping > 0 {
+			skipping += braces(line)
+			return nil
+		}
+		if lx.broken[ln] {
+			if strings.HasSuffix(strings.TrimSpace(lx.src[ln]), "{") {
+				skipping = 1
+			}
+			return nil
+		}
+		if err := parseLine(ln, line); err != nil {
+			errs = errs.add(err)
+			if n := len(line); n != 0 && line[n-1].String() == "{" && line[0].String() != "}" {
+				skipping = 1
+			}
+		}
+		return nil
 	})
-	if err != nil {
-		return err
+	ast.global.comments = append(ast.global.comments, takeComments(len(lx.nums))...)
+	if ast.phase() != _ast_global && len(errs) == 0 {
+		// report at the last line of the source
+		errs = errs.add(parseErrorf(len(lx.nums), ErrIncomplete, "possible missing terminator"))
 	}
-	if ast.phase() != _ast_global {
-		return errors.New("incomplete source file, possible missing terminator")
+	if len(errs) != 0 {
+		return errs
 	}
 	return nil
 }
 
+// braces returns the number of '{' minus the number of '}' in the line
+func braces(line []*Token) int {
+	var n int
+	for _, t := range line {
+		if !t.TypeEqual(_symbol_t) {
+			continue
+		}
+		n += strings.Count(t.String(), "{") - strings.Count(t.String(), "}")
+	}
+	return n
+}
+
 func (ast *AST) validate() error {
 	for _, s := range ast.cos {
 		if ok, found := ast.fns[s]; !found {
@@ -471,7 +659,7 @@ func (ast *AST) preparse(k string, line []*Token, ln int, b *Block) (body, error
 	pattern := statementPatterns[k]
 
 	if l := len(line); l < pattern.min || l > pattern.max {
-		return nil, tokenErrorf(ln, ErrTokenNumInLine, "actual %d, expect [%d,%d]", l, pattern.min, pattern.max)
+		return nil, posErrorf(ln, line[0].col, ErrTokenNumInLine, "actual %d, expect [%d,%d]", l, pattern.min, pattern.max)
 	}
 
 	min := len(line)
@@ -504,6 +692,9 @@ func (ast *AST) preparse(k string, line []*Token, ln int, b *Block) (body, error
 		if err := t.extractVar(); err != nil {
 			return nil, err
 		}
+		if err := t.checkCalls(); err != nil {
+			return nil, err
+		}
 	}
 
 	var body body
@@ -516,9 +707,10 @@ func (ast *AST) preparse(k string, line []*Token, ln int, b *Block) (body, error
 
 func (ast *AST) parseVar(line []*Token, ln int, current *Block) error {
 	var composed []*Token
-	if l := len(line); l > 4 {
+	if l := len(line); l > 4 || l == 4 && isBoolLiteral(line[3]) {
 		composed = append(composed, line[0:3]...)
-		// Compose all intermediate tokens to expresssion
+		// Compose all intermediate tokens to expresssion, the bool literal is an expression too, so the variable
+		// is a bool, e.g. 'var ok = true'
 		composed = append(composed, newExpression(line[3:]).ToToken())
 	} else {
 		composed = line
@@ -541,6 +733,7 @@ func (ast *AST) parseVar(line []*Token, ln int, current *Block) error {
 		// 		var v = -1
 		// 		var v = 1 + $(foo)
 		// 		var v = "a" > "b"
+		// 		var v = true
 		// the value is a expression
 		val = composed[3]
 		if !val.TypeEqual(_string_t, _number_t, _refvar_t, _expr_t) {
@@ -578,6 +771,45 @@ func (ast *AST) parseLoad(line []*Token, ln int, parent *Block) error {
 	return nil
 }
 
+// parseRequire parses the 'require' statement that declares the environment variables required by the flow, e.g.
+// 'require env BUILD, TEST', the names are separated by ','.
+func (ast *AST) parseRequire(line []*Token, ln int, parent *Block) error {
+	b := &Block{
+		child:  []*Block{},
+		parent: parent,
+		vtbl:   vartable{vars: make(map[string]*_var)},
+	}
+	body, err := ast.preparse("require", line, ln, b)
+	if err != nil {
+		return err
+	}
+	b.body = body
+	b.kind = *line[0]
+	b.target1 = *line[1]
+
+	names := line[2:]
+	if len(names)%2 == 0 {
+		return statementTokensErrorf(ErrRequireIllegal, line)
+	}
+	for i, t := range names {
+		if i%2 == 1 {
+			if t.String() != "," {
+				return tokenValueErrorf(t, ",")
+			}
+			continue
+		}
+		if !t.TypeEqual(_ident_t) {
+			return tokenTypeErrorf(t, _ident_t)
+		}
+		if err := b.body.Append([]*Token{t}); err != nil {
+			return err
+		}
+	}
+
+	parent.child = append(parent.child, b)
+	return nil
+}
+
 func (ast *AST) parseFn(line []*Token, ln int, parent *Block) (*Block, error) {
 	b := &Block{
 		child:  []*Block{},
@@ -603,6 +835,9 @@ func (ast *AST) parseFn(line []*Token, ln int, parent *Block) (*Block, error) {
 		s2 := b.target2.String()
 		return nil, parseErrorf(ln, ErrIdentConflict, "'%s', '%s'", s1, s2)
 	}
+	if strings.Contains(target.String(), ".") {
+		return nil, tokenErrorf(target, ErrTokenCharacterIllegal, "'%s', the '.' is reserved for the imported fn", target)
+	}
 
 	if s, ok := ast.fns[b.Target1().String()]; ok {
 		return nil, parseErrorf(ln, ErrIdentConflict, "duplicate definition of fn '%s'", s)
@@ -1250,6 +1485,9 @@ func (in *inferNode) lookup(line []*Token) (func(*Block, []*Token, int) error, e
 			return nil, statementTokensErrorf(ErrStatementInferFailed, line)
 		}
 	}
+	if p.parse == nil {
+		return nil, statementTokensErrorf(ErrStatementInferFailed, line)
+	}
 	return p.parse, nil
 }
 
@@ -1,9 +1,12 @@
 package parser
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/cofunclabs/cofunc/pkg/eval"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -73,34 +76,34 @@ func TestParseBlocksFull(t *testing.T) {
 	}
 	for _, b := range blocks {
 		{
-			val, cached := b.calcVar("a")
+			val, cached, _ := b.calcVar("a")
 			assert.True(t, cached)
 			assert.Equal(t, "1", val)
 		}
 		{
-			val, cached := b.calcVar("b")
+			val, cached, _ := b.calcVar("b")
 			assert.True(t, cached)
 			assert.Equal(t, "100", val)
 		}
 		{
-			val, cached := b.calcVar("c")
+			val, cached, _ := b.calcVar("c")
 			assert.True(t, cached)
 			assert.Equal(t, "", val)
 		}
 		{
-			val, cached := b.calcVar("d")
+			val, cached, _ := b.calcVar("d")
 			assert.True(t, cached)
 			assert.Equal(t, "hello word", val)
 		}
 
 		if b.IsFn() && b.target1.String() == "f1" {
 			{
-				val, cached := b.calcVar("fa")
+				val, cached, _ := b.calcVar("fa")
 				assert.True(t, cached)
 				assert.Equal(t, "f1", val)
 			}
 			{
-				val, cached := b.calcVar("fb")
+				val, cached, _ := b.calcVar("fb")
 				assert.True(t, cached)
 				assert.Equal(t, "f1", val)
 			}
@@ -229,11 +232,11 @@ hello2"
 		assert.Equal(t, obj, b.target1.String())
 
 		if obj == "function2" {
-			kvs := b.body.(*MapBody).ToMap()
+			kvs, _ := b.body.(*MapBody).ToMap()
 			assert.Len(t, kvs, 2)
 		}
 		if obj == "function3" {
-			kvs := b.body.(*MapBody).ToMap()
+			kvs, _ := b.body.(*MapBody).ToMap()
 			assert.Len(t, kvs, 4)
 			assert.Equal(t, "{(1+2+3)}", kvs["k"])
 			assert.Equal(t, "hello1\nhello2\n", kvs["multi1"])
@@ -272,7 +275,7 @@ co {
 		assert.True(t, b.operator.IsEmpty())
 		assert.True(t, b.target2.IsEmpty())
 
-		slice := b.body.(*ListBody).ToSlice()
+		slice, _ := b.body.(*ListBody).ToSlice()
 		assert.Len(t, slice, 3)
 		e1, e2, e3 := slice[0], slice[1], slice[2]
 		assert.Equal(t, "function1", e1)
@@ -302,7 +305,7 @@ co {
 		assert.True(t, b.operator.IsEmpty())
 		assert.True(t, b.target2.IsEmpty())
 
-		slice := b.body.(*ListBody).ToSlice()
+		slice, _ := b.body.(*ListBody).ToSlice()
 		assert.Len(t, slice, 3)
 		e1, e2, e3 := slice[0], slice[1], slice[2]
 		assert.Equal(t, "function1", e1)
@@ -548,19 +551,19 @@ func TestVarExpression(t *testing.T) {
 			t.FailNow()
 		}
 		for _, b := range blocks {
-			v, _ := b.calcVar("a")
+			v, _, _ := b.calcVar("a")
 			assert.Equal(t, "100", v)
 
-			v, _ = b.calcVar("b")
+			v, _, _ = b.calcVar("b")
 			assert.Equal(t, "101", v)
 
-			v, _ = b.calcVar("c")
+			v, _, _ = b.calcVar("c")
 			assert.Equal(t, "3", v)
 
-			v, _ = b.calcVar("d")
+			v, _, _ = b.calcVar("d")
 			assert.Equal(t, "true", v)
 
-			v, _ = b.calcVar("e")
+			v, _, _ = b.calcVar("e")
 			assert.Equal(t, "false", v)
 		}
 	}
@@ -621,3 +624,711 @@ func TestEvent(t *testing.T) {
 		assert.Equal(t, _kw_co, blocks[3].kind.String())
 	}
 }
+
+func TestSetVarValue(t *testing.T) {
+	const testingdata string = `
+	var a = "foo"
+	var b = "hello $(a)"
+	`
+	ast, err := New(strings.NewReader(testingdata))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	global := ast.Global()
+	assert.Equal(t, []string{"a", "b"}, global.GetVarNames())
+	assert.Equal(t, "hello foo", global.GetVarValue("b"))
+
+	assert.NoError(t, global.SetVarValue("a", "bar"))
+	assert.Equal(t, "bar", global.GetVarValue("a"))
+	assert.Equal(t, "hello bar", global.GetVarValue("b"))
+
+	assert.Error(t, global.SetVarValue("c", "bar"))
+	assert.Error(t, global.SetVarValue("env", "bar"))
+}
+
+func TestFieldRefs(t *testing.T) {
+	const testingdata string = `
+load "go:time"
+load "go:print"
+
+var out
+var msg = "$(out.now) $(env.HOME)"
+
+co time -> out
+for {
+	co print {
+		"_": "$(out.year)"
+	}
+}
+	`
+	ast, err := New(strings.NewReader(testingdata))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	refs := ast.FieldRefs()
+	if assert.Len(t, refs, 2) {
+		assert.Equal(t, "out", refs[0].Var)
+		assert.Equal(t, "now", refs[0].Field)
+		assert.Equal(t, 6, refs[0].Line)
+		assert.Equal(t, ast.Global(), refs[0].Scope)
+
+		assert.Equal(t, "year", refs[1].Field)
+		assert.Equal(t, 11, refs[1].Line)
+		assert.Equal(t, ast.Global(), refs[1].Scope)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	const testingdata string = `load "go:print"
+co print {
+	"a": "b"
+	x y
+}
+fn p = ! {
+	args = {
+	}
+}
+var a = 1 @
+co print me
+`
+	_, err := New(strings.NewReader(testingdata))
+	var list ErrorList
+	if !assert.ErrorAs(t, err, &list) {
+		return
+	}
+	var positions [][2]int
+	for _, e := range list {
+		positions = append(positions, [2]int{e.Line, e.Col})
+	}
+	assert.Equal(t, [][2]int{{4, 2}, {6, 8}, {10, 11}, {11, 1}}, positions)
+
+	assert.ErrorIs(t, err, ErrMapKVIllegal)
+	assert.ErrorIs(t, err, ErrTokenCharacterIllegal)
+	assert.Equal(t, "4:2: map kv format illegal: 'x' 'y' ", list[0].Error())
+	assert.Equal(t, "testing.flowl:6:8: "+list[1].Err.Error()+"\n\tfn p = ! {\n\t       ^", list[1].Format("testing.flowl"))
+
+	// the missing terminator is reported at the last line
+	_, err = New(strings.NewReader("co print {\n\n"))
+	if assert.ErrorAs(t, err, &list) && assert.Len(t, list, 1) {
+		assert.ErrorIs(t, err, ErrIncomplete)
+		assert.Equal(t, 2, list[0].Line)
+	}
+}
+
+func TestComments(t *testing.T) {
+	const testingdata string = `/* The description
+   of the flow */
+load "go:print" // the printer
+//--- divider
+var a = "http://cofunc.io" /* not a comment in string */
+
+fn p = print { // alias
+	args = {
+		"_": "$(a)" // argument
+	}
+	// the end of fn
+}
+co p
+// the end of file
+`
+	ast, err := New(strings.NewReader(testingdata))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "The description\n   of the flow", ast.Desc())
+	assert.Equal(t, "http://cofunc.io", ast.Global().GetVarValue("a"))
+
+	comments := make(map[string][]string)
+	ast.Foreach(func(b *Block) error {
+		for _, c := range b.Comments() {
+			key := b.kind.String() + " " + b.target1.String()
+			comments[key] = append(comments[key], c.String())
+		}
+		return nil
+	})
+	assert.Equal(t, map[string][]string{
+		"load go:print": {"/* The description\n   of the flow */", "// the printer"},
+		"global ":       {"// --- divider", "/* not a comment in string */", "// the end of file"},
+		"fn p":          {"// alias", "// the end of fn"},
+		"args ":         {"// argument"},
+	}, comments)
+
+	var trailing []bool
+	for _, c := range ast.Global().Comments() {
+		trailing = append(trailing, c.Trailing)
+	}
+	assert.Equal(t, []bool{false, true, false}, trailing)
+
+	_, err = New(strings.NewReader("load \"go:print\"\n/* unterminated\n"))
+	assert.ErrorIs(t, err, ErrCommentUnterminated)
+}
+
+func TestHeredoc(t *testing.T) {
+	const testingdata string = `var dir = "/tmp"
+var script = <<-EOF
+	cd $(dir)
+	  echo "done"
+
+	EOF
+fn c = command {
+	args = {
+		"cmd":<<EOF
+  ls $(dir)
+EOF
+	}
+}
+co c
+`
+	ast, err := New(strings.NewReader(testingdata))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "cd /tmp\n  echo \"done\"\n", ast.Global().GetVarValue("script"))
+
+	var args map[string]string
+	ast.Foreach(func(b *Block) error {
+		if b.IsArgs() {
+			args, _ = b.Body().(*MapBody).ToMap()
+		}
+		return nil
+	})
+	assert.Equal(t, map[string]string{"cmd": "  ls /tmp"}, args)
+
+	_, err = New(strings.NewReader("var a = <<EOF\nabc\n"))
+	assert.ErrorIs(t, err, ErrHeredocIllegal)
+	_, err = New(strings.NewReader("var a = <<EOF abc\nEOF\n"))
+	assert.ErrorIs(t, err, ErrHeredocIllegal)
+
+	// The '<<' isn't at the position of a value, it's the shift operator
+	ast, err = New(strings.NewReader("var n = 2\nvar s = \"ab\"\nvar a = $(n) <<len($(s))\nvar b = 1<<len(\"abc\")\n"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "8", ast.Global().GetVarValue("a"))
+		assert.Equal(t, "8", ast.Global().GetVarValue("b"))
+	}
+
+	out, err := Format([]byte(testingdata))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(out), "        \"cmd\": <<EOF\n  ls $(dir)\nEOF\n")
+		assert.Contains(t, string(out), "var script = <<-EOF\n\tcd $(dir)\n")
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+		return path
+	}
+	write("common/go.flowl", `load "go:print"
+load "go:time"
+var version = "1.21"
+fn build_local = print {
+	args = {
+		"_": "go $(version)"
+	}
+}
+co build_local
+`)
+	main := write("main.flowl", `import "common/go.flowl"
+import "common/go.flowl" as golang
+load "go:print"
+var v = "using $(go.version)"
+co go.build_local
+co golang.build_local
+`)
+
+	f, err := os.Open(main)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+	ast, err := New(f)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "using 1.21", ast.Global().GetVarValue("v"))
+	assert.Equal(t, "1.21", ast.Global().GetVarValue("go.version"))
+	assert.Equal(t, []string{"v"}, ast.Global().GetVarNames())
+
+	loads, fns, runs := ast.GetBlocks()
+	var names []string
+	for _, b := range loads {
+		names = append(names, b.Target1().String())
+	}
+	// the loads are merged once, the 'co' of the imported file isn't merged
+	assert.ElementsMatch(t, []string{"go:print", "go:time"}, names)
+	if assert.Len(t, fns, 2) {
+		assert.Equal(t, "go.build_local", fns[0].Target1().String())
+		assert.Equal(t, "golang.build_local", fns[1].Target1().String())
+		assert.True(t, fns[0].InImport())
+		args, _ := fns[0].Child()[0].Body().(*MapBody).ToMap()
+		assert.Equal(t, map[string]string{"_": "go 1.21"}, args)
+	}
+	assert.Len(t, runs, 2)
+
+	testingdata := []struct {
+		name string
+		data string
+		err  error
+	}{
+		{"notfound.flowl", `import "nope.flowl"`, ErrImportNotFound},
+		{"cycle.flowl", `import "cycle2.flowl"`, ErrImportFailed},
+		{"cycle2.flowl", `import "cycle.flowl"`, ErrImportFailed},
+		{"self.flowl", `import "self.flowl"`, ErrImportCycle},
+		{"conflict.flowl", "var go\nimport \"common/go.flowl\"", ErrIdentConflict},
+		{"namespace.flowl", `import "common/go.flowl" as`, ErrImportIllegal},
+		{"fn.flowl", "fn a.b = print {\n}", ErrTokenCharacterIllegal},
+	}
+	for _, tt := range testingdata {
+		write(tt.name, tt.data)
+	}
+	for _, tt := range testingdata {
+		_, err := New(strings.NewReader(tt.data), WithFile(filepath.Join(dir, tt.name)))
+		assert.ErrorIs(t, err, tt.err, tt.name)
+	}
+	_, err = New(strings.NewReader(`import "cycle.flowl"`), WithFile(filepath.Join(dir, "x.flowl")))
+	assert.ErrorContains(t, err, "import cycle")
+}
+
+func TestFunctionCalls(t *testing.T) {
+	const testingdata string = `
+var a = " x-y "
+var b = replace(trim($(a)), "-", "_")
+var n = len($(b)) + 1
+var q = "say \"hi\""
+var j = "{\"k\": [1, {\"v\": \"deep\"}]}"
+var s = "$(upper($(b))):$(default('', 'none')):$(json_get($(j), 'k.1.v')):$(lower($(q)))"
+var x = json_get("{\"x\": \"it's\"}", "x")
+co print {
+	"_": "$(contains($(q), 'hi'))"
+}
+switch {
+	case len($(b)) == 3 && contains($(b), "_") {
+		co print
+	}
+}
+`
+	ast, err := New(strings.NewReader(testingdata))
+	if !assert.NoError(t, err) {
+		return
+	}
+	g := ast.Global()
+	assert.Equal(t, "x_y", g.GetVarValue("b"))
+	assert.Equal(t, "4", g.GetVarValue("n"))
+	assert.Equal(t, "X_Y:none:deep:say \"hi\"", g.GetVarValue("s"))
+	assert.Equal(t, "it's", g.GetVarValue("x"))
+
+	ast.Foreach(func(b *Block) error {
+		if b.IsCo() && b.Parent().IsGlobal() {
+			args, err := b.Body().(*MapBody).ToMap()
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]string{"_": "true"}, args)
+		}
+		if b.IsCo() && b.Parent().IsCase() {
+			ok, err := b.ExecCondition()
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}
+		return nil
+	})
+
+	// the variables in the arguments are referenced
+	var names []string
+	for _, ref := range ast.VarRefs() {
+		names = append(names, ref.Var)
+	}
+	assert.Subset(t, names, []string{"a", "b", "j", "q"})
+
+	errdata := []string{
+		`var a = upper("a", "b")`,
+		`var a = "$(lower())"`,
+		`var a = foo(1)`,
+		"switch {\ncase len() > 1 {\n}\n}",
+	}
+	for _, data := range errdata {
+		_, err := New(strings.NewReader(data))
+		assert.ErrorIs(t, err, ErrExpressionIllegal, data)
+	}
+	_, err = New(strings.NewReader(`var a = "$(upper($(nope)))"`))
+	assert.ErrorIs(t, err, ErrVariableNotDefined)
+}
+
+func TestFunctionFailed(t *testing.T) {
+	const testingdata string = `
+var x = "notjson"
+var s = "a"
+var m = match($(s), "[")
+co print {
+	"_": "$(json_get($(x), 'a'))"
+}
+switch {
+	case match($(s), "[") {
+		co print
+	}
+}
+`
+	ast, err := New(strings.NewReader(testingdata))
+	if !assert.NoError(t, err) {
+		return
+	}
+	g := ast.Global()
+	_, _, err = g.calcVar("m")
+	assert.ErrorIs(t, err, eval.ErrArgumentIllegal)
+	assert.Equal(t, "", g.GetVarValue("m"))
+
+	var n int
+	ast.Foreach(func(b *Block) error {
+		if b.IsCo() && b.Parent().IsGlobal() {
+			_, err := b.Body().(*MapBody).ToMap()
+			assert.ErrorIs(t, err, eval.ErrArgumentIllegal)
+			assert.ErrorContains(t, err, "json_get 'notjson' isn't a JSON")
+			n++
+		}
+		if b.IsCo() && b.Parent().IsCase() {
+			_, err := b.ExecCondition()
+			assert.ErrorIs(t, err, eval.ErrArgumentIllegal)
+			assert.ErrorContains(t, err, "match pattern")
+			n++
+		}
+		return nil
+	})
+	assert.Equal(t, 2, n)
+}
+
+func TestEnvDefault(t *testing.T) {
+	const testingdata string = `
+require env COFUNC_TEST_SET, COFUNC_TEST_EMPTY
+require env COFUNC_TEST_SET
+var a = $(env.COFUNC_TEST_UNSET:-false)
+var b = "$(env.COFUNC_TEST_EMPTY:-1.2.3) $(env.COFUNC_TEST_SET:-no)"
+var c = "$(env.COFUNC_TEST_UNSET:-a b)"
+`
+	t.Setenv("COFUNC_TEST_SET", "yes")
+	t.Setenv("COFUNC_TEST_EMPTY", "")
+
+	ast, err := New(strings.NewReader(testingdata))
+	if !assert.NoError(t, err) {
+		return
+	}
+	g := ast.Global()
+	assert.Equal(t, "false", g.GetVarValue("a"))
+	assert.Equal(t, "1.2.3 yes", g.GetVarValue("b"))
+	assert.Equal(t, "a b", g.GetVarValue("c"))
+	assert.Equal(t, []string{"COFUNC_TEST_SET", "COFUNC_TEST_EMPTY"}, ast.RequiredEnv())
+
+	errdata := map[string]error{
+		"var a = 1\nvar b = $(a:-1)": ErrVariableFormat,
+		"var a = $(env.A:false)":     ErrVariableFormat,
+		`var a = "$(env.A:)"`:        ErrVariableFormat,
+		"require env A B":            ErrRequireIllegal,
+		"require env A,":             ErrRequireIllegal,
+		"require os A":               ErrTokenValue,
+		"require env A.B":            ErrTokenRegex,
+	}
+	for data, expect := range errdata {
+		_, err := New(strings.NewReader(data))
+		assert.ErrorIs(t, err, expect, data)
+	}
+
+	// ':' without '-' is a typo of the default value
+	_, err = New(strings.NewReader("co print {\n\t\"_\": \"$(env.A:false)\"\n}"))
+	assert.ErrorIs(t, err, ErrVariableFormat)
+}
+
+func TestParams(t *testing.T) {
+	const testingdata string = `
+// the version to build
+param version = "1.0"
+param count int = 3 // the times
+param target
+param debug bool
+var msg = "$(version)-$(target)"
+var n = $(count) + 1
+`
+	ast, err := New(strings.NewReader(testingdata))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []Param{
+		{Name: "version", Type: "string", Default: "1.0", Desc: "the version to build", Line: 3},
+		{Name: "count", Type: "int", Default: "3", Desc: "the times", Line: 4},
+		{Name: "target", Type: "string", Required: true, Line: 5},
+		{Name: "debug", Type: "bool", Required: true, Line: 6},
+	}, ast.Params())
+
+	err = ast.BindParams(map[string]string{"target": "linux"})
+	assert.ErrorIs(t, err, ErrParamMissing)
+	assert.Contains(t, err.Error(), "debug")
+	assert.ErrorIs(t, ast.BindParams(map[string]string{"target": "linux", "debug": "yes"}), ErrParamType)
+	assert.ErrorIs(t, ast.BindParams(map[string]string{"target": "linux", "debug": "true", "nope": "1"}), ErrParamUnknown)
+
+	g := ast.Global()
+	assert.Equal(t, "1.0-", g.GetVarValue("msg"))
+	assert.NoError(t, ast.BindParams(map[string]string{"target": "linux", "debug": "true", "count": "5"}))
+	assert.Equal(t, "1.0-linux", g.GetVarValue("msg"))
+	assert.Equal(t, "6", g.GetVarValue("n"))
+	// the omitted params are reset to their default values
+	assert.NoError(t, ast.BindParams(map[string]string{"target": "darwin", "debug": "false"}))
+	assert.Equal(t, "1.0-darwin", g.GetVarValue("msg"))
+	assert.Equal(t, "4", g.GetVarValue("n"))
+
+	errdata := map[string]error{
+		"param a uint":           ErrParamType,
+		"param a int = \"x\"":    ErrParamType,
+		"param a = $(b)":         ErrParamIllegal,
+		"param a int 3":          ErrParamIllegal,
+		"var a = 1\nparam a":     ErrVariableNameDuplicated,
+		"param a string : \"x\"": ErrTokenValue,
+	}
+	for data, expect := range errdata {
+		_, err := New(strings.NewReader(data))
+		assert.ErrorIs(t, err, expect, data)
+	}
+
+	nested := []string{
+		"var i = 1\nfor $(i) < 3 {\n\tparam a\n}",
+		"var i = 1\nif $(i) > 1 {\n\tparam a\n}",
+		"fn f = print {\n\tparam a\n}",
+		"var i = 1\nswitch {\n\tcase $(i) > 1 {\n\t\tparam a\n\t}\n}",
+		"event {\n\tparam a\n}",
+	}
+	for _, data := range nested {
+		_, err := New(strings.NewReader(data))
+		assert.ErrorIs(t, err, ErrParamIllegal, data)
+	}
+}
+
+func TestSecretRefs(t *testing.T) {
+	const testingdata string = `
+var a = "token=$(secret.TOKEN)"
+var b = $(a)
+co print {
+	"_": "$(secret.OTHER) $(secret.TOKEN)"
+}
+`
+	ast, err := New(strings.NewReader(testingdata))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"TOKEN", "OTHER"}, ast.SecretRefs())
+	for _, ref := range ast.VarRefs() {
+		assert.NotEqual(t, "secret", ref.Var)
+	}
+	assert.Equal(t, []string{"a", "b"}, ast.Global().GetVarNames())
+	assert.Error(t, ast.Global().SetVarValue("secret", "x"))
+
+	assert.Equal(t, "token=", ast.Global().GetVarValue("b"))
+	ast.BindSecrets(map[string]string{"TOKEN": "s3cr3t"})
+	assert.Equal(t, "token=s3cr3t", ast.Global().GetVarValue("b"))
+
+	_, err = New(strings.NewReader(`var a = "$(secret.TOKEN:-x)"`))
+	assert.ErrorIs(t, err, ErrVariableFormat)
+}
+
+func TestValues(t *testing.T) {
+	const testingdata string = `
+param ratio float = 0.5
+param ok bool = "false"
+var a = 1 / 3
+var b = 9007199254740993
+var c = 0.1 + 0.2
+var d = 2.0
+var e = $(ratio) * 2
+var s = "x"
+var q = "say \"hi\""
+var n
+var m = $(n) + 1
+var big = $(b) + 0
+var sum = 10000000000000000 + 1
+var f = true
+var g = false
+
+co print {
+	"a": "$(a)"
+	"ok": "$(ok)"
+	"s": "s=$(s)"
+}
+
+s <- "$(s)y"
+c <- $(c) * 10
+q <- "$(q) " + "!"
+
+switch {
+	case $(ok) == true {
+		co print
+	}
+	case $(e) == 1 {
+		co print
+	}
+}
+`
+	ast, err := New(strings.NewReader(testingdata))
+	if !assert.NoError(t, err) {
+		return
+	}
+	g := ast.Global()
+	kind := func(name string) Kind {
+		v, err := g.GetValue(name)
+		assert.NoError(t, err, name)
+		return v.Kind()
+	}
+	assert.Equal(t, "0.333333333333333", g.GetVarValue("a"))
+	assert.Equal(t, IntKind, kind("b"))
+	assert.Equal(t, "9007199254740993", g.GetVarValue("b"))
+	assert.Equal(t, FloatKind, kind("c"))
+	assert.Equal(t, "0.3", g.GetVarValue("c"))
+	assert.Equal(t, "2", g.GetVarValue("d"))
+	assert.Equal(t, "1", g.GetVarValue("e"))
+	assert.Equal(t, StringKind, kind("s"))
+	// the null is 0 in the expression
+	assert.Equal(t, NullKind, kind("n"))
+	assert.Equal(t, "1", g.GetVarValue("m"))
+	// the integers keep their full precision in the arithmetic
+	assert.Equal(t, "9007199254740993", g.GetVarValue("big"))
+	assert.Equal(t, "10000000000000001", g.GetVarValue("sum"))
+	assert.Equal(t, BoolKind, kind("f"))
+	assert.Equal(t, "false", g.GetVarValue("g"))
+	_, err = g.GetValue("nope")
+	assert.ErrorIs(t, err, ErrVariableNotDefined)
+
+	assert.NoError(t, ast.BindParams(map[string]string{"ok": "true"}))
+	assert.Equal(t, BoolKind, kind("ok"))
+	assert.Equal(t, FloatKind, kind("ratio"))
+
+	var args *MapBody
+	for _, b := range g.Child() {
+		if b.IsCo() {
+			args = b.Body().(*MapBody)
+			break
+		}
+	}
+	values, err := args.ToValues()
+	if assert.NoError(t, err) {
+		assert.Equal(t, FloatKind, values["a"].Kind())
+		assert.Equal(t, BoolKind, values["ok"].Kind())
+		assert.Equal(t, StringKind, values["s"].Kind())
+	}
+	kvs, err := args.ToMap()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "0.333333333333333", "ok": "true", "s": "s=x"}, kvs)
+
+	for _, stm := range g.List() {
+		assert.NoError(t, g.RewriteVar(stm))
+	}
+	assert.Equal(t, "xy", g.GetVarValue("s"))
+	assert.Equal(t, "3", g.GetVarValue("c"))
+	assert.Equal(t, "say \"hi\" !", g.GetVarValue("q"))
+
+	var conds []bool
+	ast.Foreach(func(b *Block) error {
+		if b.IsCo() && b.Parent().IsCase() {
+			ok, err := b.ExecCondition()
+			assert.NoError(t, err)
+			conds = append(conds, ok)
+		}
+		return nil
+	})
+	assert.Equal(t, []bool{true, true}, conds)
+
+	v, err := ParseJSON(`{"b": [1, 2.5, true, null], "a": "x"}`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, MapKind, v.Kind())
+	assert.Equal(t, `{"a":"x","b":[1,2.5,true,null]}`, v.String())
+	list, err := v.Map()
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = list["b"].Float()
+	assert.ErrorIs(t, err, ErrValueConvert)
+
+	conversions := []struct {
+		val    Value
+		expect bool
+	}{
+		{StringValue("true"), true},
+		{StringValue(""), false},
+		{IntValue(0), false},
+		{FloatValue(0.5), true},
+	}
+	for _, c := range conversions {
+		b, err := c.val.Bool()
+		assert.NoError(t, err)
+		assert.Equal(t, c.expect, b, c.val.String())
+	}
+	_, err = StringValue("yes").Bool()
+	assert.ErrorIs(t, err, ErrValueConvert)
+}
+
+func TestFieldPath(t *testing.T) {
+	const testingdata string = `
+load "go:go_build"
+
+var out
+var first = $(out.artifacts[0].path)
+var arch = "$(out.artifacts[1].arch)"
+var n = len($(out.artifacts))
+
+co go_build -> out
+co print {
+	"path": "$(out.artifacts[0].path)"
+	"missing": "$(out.artifacts[5].path)"
+	"outcome": "$(out.outcome)"
+}
+`
+	ast, err := New(strings.NewReader(testingdata))
+	if !assert.NoError(t, err) {
+		return
+	}
+	g := ast.Global()
+	artifacts := `[{"path": "bin/a", "os": "linux", "arch": "amd64"}, {"path": "bin/b", "os": "darwin", "arch": "arm64"}]`
+	assert.NoError(t, g.AddField2Var("out", "artifacts", artifacts))
+	assert.NoError(t, g.AddField2Var("out", "outcome", "bin/a,bin/b"))
+
+	assert.Equal(t, "bin/a", g.GetVarValue("first"))
+	assert.Equal(t, "arm64", g.GetVarValue("arch"))
+	assert.Equal(t, "bin/a", g.GetVarValue("out.artifacts[0].path"))
+	assert.Equal(t, `{"arch":"amd64","os":"linux","path":"bin/a"}`, g.GetVarValue("out.artifacts[0]"))
+
+	ast.Foreach(func(b *Block) error {
+		if b.IsCo() && b.Body() != nil {
+			args, err := b.Body().(*MapBody).ToMap()
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]string{
+				"path":    "bin/a",
+				"missing": "",
+				"outcome": "bin/a,bin/b",
+			}, args)
+		}
+		return nil
+	})
+
+	// the path isn't a part of the field
+	var fields []string
+	for _, ref := range ast.FieldRefs() {
+		fields = append(fields, ref.Field)
+	}
+	assert.Contains(t, fields, "artifacts")
+	assert.NotContains(t, fields, "artifacts[0].path")
+
+	val, err := ParseJSON(`{"a": {"b": [1, {"c": 9007199254740993}]}}`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "1", val.Lookup(".a.b[0]").String())
+		assert.Equal(t, "9007199254740993", val.Lookup(".a.b[1].c").String())
+		assert.Equal(t, NullKind, val.Lookup(".a.x").Kind())
+		assert.Equal(t, NullKind, val.Lookup(".a.b[2]").Kind())
+	}
+
+	errdata := []string{
+		"var out\nvar a = $(out.x[a])",
+		"var out\nvar a = \"$(out.x[0]y)\"",
+		"var a = $(env.HOME[0])",
+	}
+	for _, data := range errdata {
+		_, err := New(strings.NewReader(data))
+		assert.ErrorIs(t, err, ErrVariableFormat, data)
+	}
+}
@@ -4,8 +4,10 @@ package parser
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
+	"github.com/cofunclabs/cofunc/pkg/eval"
 	"github.com/cofunclabs/cofunc/pkg/is"
 )
 
@@ -22,6 +24,9 @@ const (
 	_kw_case    = "case"
 	_kw_default = "default"
 	_kw_event   = "event"
+	_kw_import  = "import"
+	_kw_require = "require"
+	_kw_param   = "param"
 )
 
 var keywordTable = map[string]struct{}{
@@ -37,6 +42,21 @@ var keywordTable = map[string]struct{}{
 	_kw_switch:  {},
 	_kw_var:     {},
 	_kw_event:   {},
+	_kw_import:  {},
+	_kw_require: {},
+	_kw_param:   {},
+}
+
+// Keywords returns the keywords of flowl in alphabetical order, the comment symbol is excluded.
+func Keywords() []string {
+	var keywords []string
+	for k := range keywordTable {
+		if k != _kw_comment {
+			keywords = append(keywords, k)
+		}
+	}
+	sort.Strings(keywords)
+	return keywords
 }
 
 func iskeyword(ss ...string) (string, bool) {
@@ -63,6 +83,7 @@ const (
 	_keyword_t
 	_varname_t
 	_expr_t
+	_envname_t
 )
 
 type TokenType int
@@ -70,27 +91,34 @@ type TokenType int
 var tokenPatterns = map[TokenType]*regexp.Regexp{
 	_unknow_t:       regexp.MustCompile(`^*$`),
 	_string_t:       regexp.MustCompile(`^*$`),
-	_refvar_t:       regexp.MustCompile(`^\$\([a-zA-Z0-9_\.]*\)$`),
+	_refvar_t:       regexp.MustCompile(`^\$\([a-zA-Z0-9_\.\[\]]*(:-[^)]*)?\)$`),
 	_ident_t:        regexp.MustCompile(`^[a-zA-Z0-9_\.]*$`),
 	_number_t:       regexp.MustCompile(`^[0-9\.]+$`),
 	_mapkey_t:       regexp.MustCompile(`^[^:]+$`), // not contain ":"
 	_operator_t:     regexp.MustCompile(`^(=|->)$`),
 	_load_t:         regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9]*:.*[a-zA-Z0-9]$`),
-	_functionname_t: regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`),
+	_functionname_t: regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*(\.[a-zA-Z][a-zA-Z0-9_]*)*$`), // the imported fn is 'namespace.name'
 	_keyword_t:      regexp.MustCompile(`^[a-z]*$`),
 	_varname_t:      regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`),
+	_envname_t:      regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`),
 }
 
 type Token struct {
-	str       string
-	typ       TokenType
-	ln        int
+	str string
+	typ TokenType
+	ln  int
+	// col and end are the one-based columns in bytes of the token, the 'end' is exclusive, they are 0 if the
+	// token isn't from the source. The 'end' is the column in the last line for a multi-line string.
+	col int
+	end int
+	// raw is the source text of the heredoc string or the quoted string, it's empty for the other tokens
+	raw       string
 	_b        *Block
 	_segments []struct {
 		str   string
 		isvar bool
 	}
-	_get func(*Block, string) (string, bool)
+	_get func(*Block, string) (string, bool, error)
 }
 
 func (t *Token) IsEmpty() bool {
@@ -119,11 +147,31 @@ func (t *Token) String() string {
 	return t.str
 }
 
+// Line returns the line number of the token in flowl source file.
+func (t *Token) Line() int {
+	return t.ln
+}
+
+// Column returns the one-based column of the token in the line, it's 0 if the column is unknown.
+func (t *Token) Column() int {
+	return t.col
+}
+
+// Span returns the start and exclusive end columns of the token
+func (t *Token) Span() (int, int) {
+	return t.col, t.end
+}
+
+// HasVar returns true if the token contains any variable.
+func (t *Token) HasVar() bool {
+	return t.hasVar()
+}
+
 func (t *Token) FormatString() string {
 	return fmt.Sprintf("['%s','%s']", t.str, t.typ)
 }
 
-func _lookupVar(b *Block, name string) (string, bool) {
+func _lookupVar(b *Block, name string) (string, bool, error) {
 	return b.calcVar(name)
 }
 
@@ -143,13 +191,13 @@ func (t *Token) copySegments() []struct {
 func (t *Token) validate() error {
 	if pattern, ok := tokenPatterns[t.typ]; ok {
 		if !pattern.MatchString(t.str) {
-			return tokenErrorf(t.ln, ErrTokenRegex, "actual '%s', expect '%s'", t, pattern)
+			return tokenErrorf(t, ErrTokenRegex, "actual '%s', expect '%s'", t, pattern)
 		}
 	}
 
 	if t.TypeEqual(_functionname_t, _varname_t, _ident_t) {
 		if s, ok := iskeyword(t.String()); ok {
-			return tokenErrorf(t.ln, ErrIsKeyword, "'%s'", s)
+			return tokenErrorf(t, ErrIsKeyword, "'%s'", s)
 		}
 	}
 
@@ -159,16 +207,34 @@ func (t *Token) validate() error {
 			continue
 		}
 		name := seg.str
-		if strings.Contains(seg.str, ".") {
-			fields := strings.Split(seg.str, ".")
-			if len(fields) != 2 {
+		if isCallVar(name) {
+			ct, err := newCallToken(t._b, name, t.ln, t.col)
+			if err != nil {
+				return err
+			}
+			if err := ct.validate(); err != nil {
+				return err
+			}
+			continue
+		}
+		ref, _, hasDef := strings.Cut(seg.str, ":-")
+		if strings.Contains(ref, ":") {
+			// e.g. '$(env.BUILD:false)' is a typo of '$(env.BUILD:-false)'
+			return varErrorf(t.ln, ErrVariableFormat, "'%s' in token '%s', the default value follows ':-'", seg.str, t)
+		}
+		if strings.ContainsAny(ref, ".[") {
+			main, field, ok := isFieldVar(ref)
+			if !ok {
 				return varErrorf(t.ln, ErrVariableFormat, "'%s' in token '%s'", name, t)
 			}
-			f1, f2 := fields[0], fields[1]
-			if f1 == "" || f2 == "" {
+			field, path := splitFieldPath(field)
+			if field == "" || !fieldPathPattern.MatchString(path) {
 				return varErrorf(t.ln, ErrVariableFormat, "'%s' in token '%s'", name, t)
 			}
-			name = f1
+			name = main
+		}
+		if hasDef && name != "env" {
+			return varErrorf(t.ln, ErrVariableFormat, "'%s' in token '%s', only env has the default value", seg.str, t)
 		}
 		if v, _ := t._b.getVar(name); v == nil {
 			return varErrorf(t.ln, ErrVariableNotDefined, "'%s' in token '%s'", name, t)
@@ -177,10 +243,45 @@ func (t *Token) validate() error {
 	return nil
 }
 
+// checkCalls checks the functions called by the expression or the '$(fn(...))' in the string, the variables are
+// replaced by '0' because their values are unknown before running.
+func (t *Token) checkCalls() error {
+	if t.TypeEqual(_expr_t) {
+		s := t.str
+		if len(t._segments) != 0 {
+			var builder strings.Builder
+			for _, seg := range t._segments {
+				if seg.isvar {
+					builder.WriteString("0")
+				} else {
+					builder.WriteString(seg.str)
+				}
+			}
+			s = builder.String()
+		}
+		if err := eval.Check(s); err != nil {
+			return tokenErrorf(t, ErrExpressionIllegal, "'%s': %w", t, err)
+		}
+	}
+	for _, seg := range t._segments {
+		if !seg.isvar || !isCallVar(seg.str) {
+			continue
+		}
+		ct, err := newCallToken(t._b, seg.str, t.ln, t.col)
+		if err != nil {
+			return err
+		}
+		if err := ct.checkCalls(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // value will calcuate the variable's value, if the token contain some variables
-func (t *Token) value() string {
+func (t *Token) value() (string, error) {
 	if !t.hasVar() {
-		return t.str
+		return t.str, nil
 	}
 	if t._get == nil {
 		t._get = _lookupVar
@@ -188,13 +289,34 @@ func (t *Token) value() string {
 	var bd strings.Builder
 	for _, seg := range t._segments {
 		if seg.isvar {
-			val, _ := t._get(t._b, seg.str)
+			val, _, err := t._get(t._b, seg.str)
+			if err != nil {
+				return "", tokenErrorf(t, err, "'%s'", seg.str)
+			}
 			bd.WriteString(val)
 		} else {
 			bd.WriteString(seg.str)
 		}
 	}
-	return bd.String()
+	return bd.String(), nil
+}
+
+// typedValue calculates the typed value of the token, the string that only references a variable, e.g. '"$(a)"',
+// keeps the type of the variable, the others are strings.
+func (t *Token) typedValue() (Value, error) {
+	if len(t._segments) == 1 && t._segments[0].isvar && t._b != nil {
+		name := t._segments[0].str
+		val, err := t._b.GetValue(name)
+		if err != nil {
+			return NullValue(), tokenErrorf(t, err, "'%s'", name)
+		}
+		return val, nil
+	}
+	s, err := t.value()
+	if err != nil {
+		return NullValue(), err
+	}
+	return StringValue(s), nil
 }
 
 func (t *Token) hasVar() bool {
@@ -219,6 +341,8 @@ func (t *Token) extractVar() error {
 		start  int
 		vstart int
 		state  aststate
+		// depth is the depth of parens in the call of function, e.g. '$(upper($(name)))'
+		depth int
 	)
 	l := len(t.str)
 	next := func(i int) byte {
@@ -239,7 +363,15 @@ func (t *Token) extractVar() error {
 			}
 		case _ast_ident: // from '$'
 			// keep
-			if is.Ident(c) || c == '(' {
+			if c == '(' && i > vstart+1 {
+				depth++
+				break
+			}
+			if c == ')' && depth > 0 {
+				depth--
+				break
+			}
+			if is.Ident(c) || c == '(' || depth > 0 {
 				break
 			}
 			// transfer
@@ -2,8 +2,12 @@ package parser
 
 import (
 	"container/list"
+	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -18,7 +22,7 @@ const (
 
 type _var struct {
 	sync.Mutex
-	v        string
+	val      Value
 	segments []struct {
 		str   string
 		isvar bool
@@ -31,79 +35,163 @@ type _var struct {
 	// for $(v.key)
 	field string
 	mainv *_var
+	// path is the path in the JSON value of the field, e.g. '[0].path' of '$(v.key[0].path)'
+	path string
 
 	// for env
 	isenv bool
+	// for secret, the value of '$(secret.NAME)' is the field of the var, the secrets are bound before running
+	issecret bool
+
+	// for import, ns is the 'import' block, the variables of the imported file are accessed by '$(ns.v)'
+	ns *Block
 }
 
 func (v *_var) update(nv *_var) {
 	v.Lock()
 	defer v.Unlock()
 
-	v.v = nv.v
+	v.val = nv.val
 	v.segments = nv.segments
 	v.child = nv.child
 	v.cached = nv.cached
 	v.asexp = nv.asexp
 }
 
-func (v *_var) calc() (string, bool) {
+// snapshot returns a copy of the variable, the fields are copied too.
+func (v *_var) snapshot() *_var {
+	v.Lock()
+	defer v.Unlock()
+
+	s := &_var{
+		val:      v.val,
+		segments: v.segments,
+		child:    v.child,
+		cached:   v.cached,
+		asexp:    v.asexp,
+	}
+	if v.fields != nil {
+		s.fields = make(map[string]string, len(v.fields))
+		for key, val := range v.fields {
+			s.fields[key] = val
+		}
+	}
+	return s
+}
+
+// restore sets the variable to the snapshot.
+func (v *_var) restore(s *_var) {
+	v.update(s)
+	v.resetFields(s.fields)
+}
+
+func (v *_var) calc() (string, bool, error) {
+	val, cached, err := v.value()
+	return val.String(), cached, err
+}
+
+// value calculates the typed value of the variable, the expression is evaluated to the type of its result, and
+// the string that only references a variable, e.g. '"$(a)"', keeps the type of the referenced variable. The
+// expression fails if a function fails on the values, e.g. 'json_get($(x), "a")' with the non-JSON 'x'.
+func (v *_var) value() (Value, bool, error) {
 	v.Lock()
 	defer v.Unlock()
 
 	if v.mainv != nil && v.field != "" {
 		if v.mainv.isenv {
-			return os.Getenv(v.field), true
+			return StringValue(getenv(v.field)), true, nil
+		} else if v.mainv.issecret {
+			// not cached, the secrets are bound again when the flow is initialized again
+			return StringValue(v.mainv.readField(v.field)), false, nil
 		} else {
-			return v.mainv.fields[v.field], false
+			return fieldValue(v.mainv.readField(v.field), v.path), false, nil
 		}
 	}
 
 	if v.cached && !v.asexp {
-		return v.v, v.cached
+		return v.val, v.cached, nil
 	}
 
 	var (
-		vals      []string
+		vals      []Value
 		cacheable = true
 		vb        strings.Builder
 	)
 	for _, c := range v.child {
-		val, cached := c.calc()
+		val, cached, err := c.value()
+		if err != nil {
+			return NullValue(), false, err
+		}
 		vals = append(vals, val)
 		if !cached {
 			cacheable = false
 		}
 	}
 	var seq int
-	for _, seg := range v.segments {
+	for i, seg := range v.segments {
 		if seg.isvar {
-			seg.str = vals[seq]
+			val := vals[seq]
 			seq += 1
+			switch {
+			case !v.asexp:
+				seg.str = val.String()
+			case i > 0 && isQuoted(v.segments[i-1].str):
+				seg.str = quotedReplacer.Replace(val.String())
+			default:
+				seg.str = val.expr()
+			}
 		}
 		vb.WriteString(seg.str)
 	}
 
 	if v.asexp {
 		s := vb.String()
-		res, err := eval.String(s)
+		// the integers keep their full precision, govaluate calculates by float64
+		if n, ok := eval.Integer(s); ok {
+			v.val = IntValue(n)
+			if len(v.child) == 0 {
+				v.cached = true
+			}
+			return v.val, v.cached, nil
+		}
+		res, err := eval.New(s)
 		if err != nil {
-			panic(fmt.Errorf("%w: '%s' '%p'", err, s, v))
+			return NullValue(), false, fmt.Errorf("%w: '%s'", err, s)
 		}
-		v.v = res
+		val, err := valueOf(res)
+		if err != nil {
+			return NullValue(), false, fmt.Errorf("%w: '%s'", err, s)
+		}
+		v.val = val
 		if len(v.child) == 0 {
 			v.cached = true
 		}
-		return v.v, v.cached
+		return v.val, v.cached, nil
 	}
 
-	v.v = vb.String()
+	switch {
+	case len(v.segments) == 0:
+		// the variable is declared without a value
+		v.val = NullValue()
+	case len(v.segments) == 1 && v.segments[0].isvar:
+		v.val = vals[0]
+	default:
+		v.val = StringValue(vb.String())
+	}
 	if cacheable {
 		v.cached = true
 	}
-	return v.v, v.cached
+	return v.val, v.cached, nil
 }
 
+// isQuoted returns true if the variable after the text is quoted in the expression, e.g. '"$(s)"', the quotes in
+// the value must be escaped, so they don't end the string, the string of expression ends at both '"' and "'".
+func isQuoted(before string) bool {
+	return strings.HasSuffix(before, `"`) || strings.HasSuffix(before, "'")
+}
+
+var quotedReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "'", `\'`)
+
 func (v *_var) dfscycle(stack *list.List) error {
 	for e := stack.Front(); e != nil; e = e.Next() {
 		if e.Value.(*_var) == v {
@@ -139,6 +227,16 @@ func (v *_var) addField(key, val string) {
 	v.fields[key] = val
 }
 
+// resetFields replaces all fields of the variable.
+func (v *_var) resetFields(fields map[string]string) {
+	v.Lock()
+	defer v.Unlock()
+	v.fields = make(map[string]string, len(fields))
+	for key, val := range fields {
+		v.fields[key] = val
+	}
+}
+
 func (v *_var) readField(f string) string {
 	v.Lock()
 	defer v.Unlock()
@@ -147,11 +245,11 @@ func (v *_var) readField(f string) string {
 
 func newVarFromToken(t *Token) (*_var, error) {
 	v := &_var{
-		v:        t.String(),
 		segments: t._segments,
 		asexp:    t.TypeEqual(_expr_t),
 	}
-	if !t.hasVar() {
+	if len(v.segments) == 0 {
+		v.val = literalValue(t)
 		v.cached = true
 	}
 	for _, seg := range v.segments {
@@ -161,14 +259,40 @@ func newVarFromToken(t *Token) (*_var, error) {
 		var chld *_var
 		name := seg.str
 		main, field, ok := isFieldVar(name)
-		if ok {
+		if strings.Contains(name, ":-") && !isCallVar(name) && main != "env" {
+			return nil, tokenErrorf(t, ErrVariableFormat, "'%s', only env has the default value", name)
+		}
+		if ref, _, _ := strings.Cut(name, ":-"); strings.Contains(ref, ":") && !isCallVar(name) {
+			return nil, tokenErrorf(t, ErrVariableFormat, "'%s', the default value follows ':-'", name)
+		}
+		if isCallVar(name) {
+			ct, err := newCallToken(t._b, name, t.ln, t.col)
+			if err != nil {
+				return nil, err
+			}
+			if chld, err = newVarFromToken(ct); err != nil {
+				return nil, err
+			}
+		} else if ok {
 			mv, _ := t._b.getVar(main)
 			if mv == nil {
-				return nil, tokenErrorf(t.ln, ErrVariableNotDefined, "'%s', variable name '%s'", t, main)
+				return nil, tokenErrorf(t, ErrVariableNotDefined, "'%s', variable name '%s'", t, main)
+			}
+			field, path := splitFieldPath(field)
+			if !fieldPathPattern.MatchString(path) {
+				return nil, tokenErrorf(t, ErrVariableFormat, "'%s', the path of field is illegal", name)
+			}
+			if path != "" && (mv.ns != nil || mv.isenv || mv.issecret) {
+				return nil, tokenErrorf(t, ErrVariableFormat, "'%s', only the return values have the path", name)
 			}
-			chld = &_var{
-				field: field,
-				mainv: mv,
+			if mv.ns != nil {
+				chld, _ = mv.ns.vtbl.get(field)
+			} else {
+				chld = &_var{
+					field: field,
+					mainv: mv,
+					path:  path,
+				}
 			}
 		} else {
 			chld, _ = t._b.getVar(name)
@@ -177,7 +301,7 @@ func newVarFromToken(t *Token) (*_var, error) {
 		if chld != nil {
 			v.child = append(v.child, chld)
 		} else {
-			return nil, tokenErrorf(t.ln, ErrVariableNotDefined, "'%s', variable name '%s'", t, name)
+			return nil, tokenErrorf(t, ErrVariableNotDefined, "'%s', variable name '%s'", t, name)
 		}
 	}
 	return v, nil
@@ -211,16 +335,101 @@ func newEnvVar() *_var {
 	}
 }
 
+func newSecretVar() *_var {
+	return &_var{
+		issecret: true,
+	}
+}
+
+// isCallVar returns true if the variable is a call of function in the string, e.g. '$(upper($(name)))'
+func isCallVar(name string) bool {
+	return strings.Contains(name, "(")
+}
+
+var refOrStringPattern = regexp.MustCompile(`"(\\.|[^"\\])*"|'(\\.|[^'\\])*'|\$\([a-zA-Z0-9_.\[\]]+\)`)
+
+// newCallToken returns the expression token of the call in the string, the variables in the arguments are
+// quoted as strings unless they are already in the string literals, e.g. 'upper($(name))' -> 'upper("$(name)")'
+func newCallToken(b *Block, name string, ln, col int) (*Token, error) {
+	s := refOrStringPattern.ReplaceAllStringFunc(name, func(m string) string {
+		if strings.HasPrefix(m, "$") {
+			return `"` + m + `"`
+		}
+		return m
+	})
+	t := &Token{
+		str: s,
+		typ: _expr_t,
+		ln:  ln,
+		col: col,
+		_b:  b,
+	}
+	if err := t.extractVar(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// isFieldVar splits the variable 'main.field', the default value of env and the path of the field are kept in the
+// field, e.g. 'env.BUILD:-false' -> 'env', 'BUILD:-false' and 'out.artifacts[0].path' -> 'out', 'artifacts[0].path'
 func isFieldVar(name string) (string, string, bool) {
-	fields := strings.Split(name, ".")
-	if len(fields) != 2 {
+	ref, def, hasDef := strings.Cut(name, ":-")
+	main, field, ok := strings.Cut(ref, ".")
+	if !ok || main == "" || field == "" || strings.Contains(main, "[") {
 		return "", "", false
 	}
-	return fields[0], fields[1], true
+	if hasDef {
+		return main, field + ":-" + def, true
+	}
+	return main, field, true
+}
+
+// fieldPathPattern is the pattern of the path in the field, the keys follow '.' and the indexes of lists are in '[]'
+var fieldPathPattern = regexp.MustCompile(`^(\.[a-zA-Z0-9_]+|\[[0-9]+\])*$`)
+
+// splitFieldPath splits the path from the field, e.g. 'artifacts[0].path' -> 'artifacts', '[0].path', the default
+// value of env isn't a path.
+func splitFieldPath(field string) (string, string) {
+	ref, _, _ := strings.Cut(field, ":-")
+	if i := strings.IndexAny(ref, ".["); i != -1 {
+		return field[:i], field[i:]
+	}
+	return field, ""
+}
+
+// fieldValue returns the value of the field, the field is parsed as JSON if it has the path, it's null if the path
+// doesn't exist in the value.
+func fieldValue(s, path string) Value {
+	if path == "" {
+		return StringValue(s)
+	}
+	val, err := ParseJSON(s)
+	if err != nil {
+		return NullValue()
+	}
+	return val.Lookup(path)
+}
+
+// getenv returns the value of the environment variable, the field may have a default value like the shell,
+// e.g. 'BUILD:-false', the default value is used if the variable is unset or empty.
+func getenv(field string) string {
+	name, def, _ := strings.Cut(field, ":-")
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
 }
 
 type expression struct {
 	s string
+	// ln and col are the position of the first token of the expression
+	ln  int
+	col int
+}
+
+// isBoolLiteral returns true if the token is 'true' or 'false'
+func isBoolLiteral(t *Token) bool {
+	return t.TypeEqual(_ident_t) && (t.String() == "true" || t.String() == "false")
 }
 
 func newExpression(tokens []*Token) *expression {
@@ -228,23 +437,55 @@ func newExpression(tokens []*Token) *expression {
 		hasString bool
 		hasArith  bool
 		hasNumber bool
+		hasBool   bool
 		builder   strings.Builder
 		subtokens []*Token
 	)
 
+	// incall marks the variables in the arguments of function calls, e.g. 'upper($(name))', they are strings
+	incall := make(map[*Token]bool)
+	var (
+		depth int
+		calls []int
+	)
+	for i, t := range tokens {
+		if t.TypeEqual(_refvar_t) && len(calls) != 0 {
+			incall[t] = true
+		}
+		if !t.TypeEqual(_symbol_t) {
+			continue
+		}
+		for j, c := range t.String() {
+			switch c {
+			case '(':
+				depth++
+				if j == 0 && i > 0 && tokens[i-1].TypeEqual(_ident_t) {
+					calls = append(calls, depth)
+				}
+			case ')':
+				if l := len(calls); l != 0 && calls[l-1] == depth {
+					calls = calls[:l-1]
+				}
+				depth--
+			}
+		}
+	}
+
 	convert := func() {
 		for _, t := range subtokens {
 			switch t.typ {
 			case _string_t:
+				// the escaped quotes have been unescaped by the lexer, they are escaped again, so they don't end
+				// the string of expression, e.g. '"{\"x\":1}"'
 				builder.WriteString("\"")
-				builder.WriteString(t.String())
+				builder.WriteString(quotedReplacer.Replace(t.String()))
 				builder.WriteString("\"")
 			case _refvar_t:
-				if hasString {
+				if hasString || incall[t] {
 					builder.WriteString("\"")
 					builder.WriteString(t.String())
 					builder.WriteString("\"")
-				} else if hasNumber {
+				} else if hasNumber || hasBool {
 					builder.WriteString(t.String())
 				} else if !hasArith {
 					builder.WriteString("\"")
@@ -280,20 +521,30 @@ func newExpression(tokens []*Token) *expression {
 		if t.TypeEqual(_number_t) {
 			hasNumber = true
 		}
+		// the variable compared with a boolean literal is a boolean, e.g. '$(ok) == true'
+		if isBoolLiteral(t) {
+			hasBool = true
+		}
 	}
 	if subtokens != nil {
 		convert()
 	}
 
-	return &expression{
+	e := &expression{
 		s: builder.String(),
 	}
+	if len(tokens) != 0 {
+		e.ln, e.col = tokens[0].ln, tokens[0].col
+	}
+	return e
 }
 
 func (e *expression) ToToken() *Token {
 	return &Token{
 		str: e.s,
 		typ: _expr_t,
+		ln:  e.ln,
+		col: e.col,
 	}
 }
 
@@ -314,9 +565,9 @@ func (vs *vartable) debug(tab ...string) {
 	indent := strings.Join(tab, "")
 	fmt.Println(indent + "variables in block:")
 	for k, v := range vs.vars {
-		fmt.Printf(indent+"\tname:'%s', value:'%s', exp:'%t', addr:%p, segments:'%+v'\n", k, v.v, v.asexp, v, v.segments)
+		fmt.Printf(indent+"\tname:'%s', value:'%s', exp:'%t', addr:%p, segments:'%+v'\n", k, v.val, v.asexp, v, v.segments)
 		for _, c := range v.child {
-			fmt.Printf(indent+"\t\taddr:'%p', value:'%s', exp:'%t'\n", c, c.v, c.asexp)
+			fmt.Printf(indent+"\t\taddr:'%p', value:'%s', exp:'%t'\n", c, c.val, c.asexp)
 		}
 	}
 }
@@ -333,6 +584,20 @@ func (vs *vartable) put(name string, v *_var) {
 	}
 }
 
+// uncache clears the cache of the variables that reference other variables, so they will be calculated again.
+func (vs *vartable) uncache() {
+	vs.Lock()
+	defer vs.Unlock()
+
+	for _, v := range vs.vars {
+		v.Lock()
+		if len(v.child) != 0 {
+			v.cached = false
+		}
+		v.Unlock()
+	}
+}
+
 func (vs *vartable) add(name string, v *_var) error {
 	vs.Lock()
 	defer vs.Unlock()
@@ -353,24 +618,33 @@ func (vs *vartable) get(name string) (*_var, bool) {
 	return v, ok
 }
 
-func (vs *vartable) calc(name string) (_v interface{}, cached bool) {
+// calc calculates the value of the variable in the table, the 'found' is false if it isn't defined in the table.
+func (vs *vartable) calc(name string) (val string, found, cached bool, err error) {
 	main, field, ok := isFieldVar(name)
 	if ok {
 		if main == "env" {
-			return os.Getenv(field), true
+			return getenv(field), true, true, nil
 		}
 		v, ok := vs.get(main)
 		if !ok {
-			return nil, false
+			return "", false, false, nil
+		}
+		if v.issecret {
+			return v.readField(field), true, false, nil
 		}
-		return v.readField(field), false
+		if v.ns != nil {
+			return v.ns.vtbl.calc(field)
+		}
+		field, path := splitFieldPath(field)
+		return fieldValue(v.readField(field), path).String(), true, false, nil
 	}
 
 	v, ok := vs.get(name)
 	if !ok {
-		return nil, false
+		return "", false, false, nil
 	}
-	return v.calc()
+	val, cached, err = v.calc()
+	return val, true, cached, err
 }
 
 func (vs *vartable) cyclecheck(names ...string) error {
@@ -398,3 +672,356 @@ func (vs *vartable) cyclecheck(names ...string) error {
 	}
 	return nil
 }
+
+// Kind is the type of the value of variable
+type Kind int
+
+const (
+	NullKind Kind = iota
+	StringKind
+	IntKind
+	FloatKind
+	BoolKind
+	ListKind
+	MapKind
+)
+
+var kindNames = map[Kind]string{
+	NullKind:   "null",
+	StringKind: "string",
+	IntKind:    "int",
+	FloatKind:  "float",
+	BoolKind:   "bool",
+	ListKind:   "list",
+	MapKind:    "map",
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// Value is the typed value of variable, the values are formatted to strings when they are interpolated into the
+// strings or passed to functions:
+//
+//	null   ""
+//	string as is
+//	int    decimal, e.g. '100000000000'
+//	float  without the exponent and rounded to 15 significant digits, e.g. '0.333333333333333'
+//	bool   'true' or 'false'
+//	list   JSON array, e.g. '[1,"a"]'
+//	map    JSON object with the sorted keys, e.g. '{"a":1}'
+//
+// The literals of numbers are int or float, the strings are string, and the expressions are the type of their
+// results, the numbers of results are int if they are integers.
+type Value struct {
+	kind Kind
+	s    string
+	i    int64
+	f    float64
+	b    bool
+	list []Value
+	m    map[string]Value
+}
+
+func NullValue() Value {
+	return Value{kind: NullKind}
+}
+
+func StringValue(s string) Value {
+	return Value{kind: StringKind, s: s}
+}
+
+func IntValue(i int64) Value {
+	return Value{kind: IntKind, i: i}
+}
+
+func FloatValue(f float64) Value {
+	return Value{kind: FloatKind, f: f}
+}
+
+func BoolValue(b bool) Value {
+	return Value{kind: BoolKind, b: b}
+}
+
+func ListValue(list []Value) Value {
+	return Value{kind: ListKind, list: list}
+}
+
+func MapValue(m map[string]Value) Value {
+	return Value{kind: MapKind, m: m}
+}
+
+// ParseJSON converts the JSON to the value, the numbers that are integers are int.
+func ParseJSON(s string) (Value, error) {
+	var x interface{}
+	// the numbers are decoded as json.Number, so the large integers keep their precision
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&x); err != nil {
+		return Value{}, fmt.Errorf("%w: %s", ErrValueConvert, err)
+	}
+	if dec.More() {
+		return Value{}, fmt.Errorf("%w: '%s' isn't a JSON value", ErrValueConvert, s)
+	}
+	return valueOf(x)
+}
+
+// valueOf converts the result of expression or JSON to the value
+func valueOf(x interface{}) (Value, error) {
+	switch x := x.(type) {
+	case nil:
+		return NullValue(), nil
+	case string:
+		return StringValue(x), nil
+	case bool:
+		return BoolValue(x), nil
+	case float64:
+		if eval.IsInteger(x) {
+			return IntValue(int64(x)), nil
+		}
+		return FloatValue(x), nil
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return IntValue(i), nil
+		}
+		f, err := x.Float64()
+		if err != nil {
+			return Value{}, fmt.Errorf("%w: %s", ErrValueConvert, err)
+		}
+		return valueOf(f)
+	case []interface{}:
+		list := make([]Value, 0, len(x))
+		for _, e := range x {
+			v, err := valueOf(e)
+			if err != nil {
+				return Value{}, err
+			}
+			list = append(list, v)
+		}
+		return ListValue(list), nil
+	case map[string]interface{}:
+		m := make(map[string]Value, len(x))
+		for k, e := range x {
+			v, err := valueOf(e)
+			if err != nil {
+				return Value{}, err
+			}
+			m[k] = v
+		}
+		return MapValue(m), nil
+	}
+	return Value{}, fmt.Errorf("%w: unsupported type '%T'", ErrValueConvert, x)
+}
+
+// literalValue returns the value of the token that doesn't contain any variable
+func literalValue(t *Token) Value {
+	if t.TypeEqual(_number_t) {
+		if i, err := strconv.ParseInt(t.str, 10, 64); err == nil {
+			return IntValue(i)
+		}
+		if f, err := strconv.ParseFloat(t.str, 64); err == nil {
+			return FloatValue(f)
+		}
+	}
+	return StringValue(t.str)
+}
+
+func (v Value) Kind() Kind {
+	return v.kind
+}
+
+func (v Value) String() string {
+	switch v.kind {
+	case StringKind:
+		return v.s
+	case IntKind:
+		return strconv.FormatInt(v.i, 10)
+	case FloatKind:
+		return eval.FormatNumber(v.f)
+	case BoolKind:
+		return strconv.FormatBool(v.b)
+	case ListKind, MapKind:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return ""
+}
+
+var numberPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// expr returns the literal of the value in the expression: the numbers and bools are as is, the strings that are
+// numbers or bools are as is too, e.g. the value of environment variable, the others are quoted strings. The null
+// is 0 as it's in Float, e.g. '$(n) + 1' is 1 if 'n' has no value.
+func (v Value) expr() string {
+	switch v.kind {
+	case NullKind:
+		return "0"
+	case IntKind, FloatKind, BoolKind:
+		return v.String()
+	case StringKind:
+		if numberPattern.MatchString(v.s) || v.s == "true" || v.s == "false" {
+			return v.s
+		}
+	}
+	return `"` + quotedReplacer.Replace(v.String()) + `"`
+}
+
+// MarshalJSON implements the json.Marshaler, the numbers are formatted as the strings of values.
+func (v Value) MarshalJSON() ([]byte, error) {
+	switch v.kind {
+	case NullKind:
+		return []byte("null"), nil
+	case StringKind:
+		return json.Marshal(v.s)
+	case IntKind, BoolKind:
+		return []byte(v.String()), nil
+	case FloatKind:
+		if math.IsNaN(v.f) || math.IsInf(v.f, 0) {
+			return json.Marshal(v.String())
+		}
+		return []byte(v.String()), nil
+	case ListKind:
+		if v.list == nil {
+			return []byte("[]"), nil
+		}
+		return json.Marshal(v.list)
+	case MapKind:
+		if v.m == nil {
+			return []byte("{}"), nil
+		}
+		return json.Marshal(v.m)
+	}
+	return nil, fmt.Errorf("%w: unknown kind %d", ErrValueConvert, v.kind)
+}
+
+// Bool converts the value to bool: null is false, the numbers are true if they aren't zero, the empty list and
+// map are false, the string is false if it's empty, otherwise it must be a boolean, e.g. 'true', 'false', '1', '0'.
+func (v Value) Bool() (bool, error) {
+	switch v.kind {
+	case NullKind:
+		return false, nil
+	case BoolKind:
+		return v.b, nil
+	case IntKind:
+		return v.i != 0, nil
+	case FloatKind:
+		return v.f != 0, nil
+	case ListKind:
+		return len(v.list) != 0, nil
+	case MapKind:
+		return len(v.m) != 0, nil
+	}
+	if v.s == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v.s)
+	if err != nil {
+		return false, fmt.Errorf("%w: '%s' to bool", ErrValueConvert, v.s)
+	}
+	return b, nil
+}
+
+// Float converts the value to float64: null is 0, bool is 1 or 0, the string must be a number, the list and map
+// can't be converted.
+func (v Value) Float() (float64, error) {
+	switch v.kind {
+	case NullKind:
+		return 0, nil
+	case IntKind:
+		return float64(v.i), nil
+	case FloatKind:
+		return v.f, nil
+	case BoolKind:
+		if v.b {
+			return 1, nil
+		}
+		return 0, nil
+	case StringKind:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v.s), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: '%s' to float", ErrValueConvert, v.s)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("%w: %s to float", ErrValueConvert, v.kind)
+}
+
+// List converts the value to list: null is empty, the string must be a JSON array, the other types can't be
+// converted.
+func (v Value) List() ([]Value, error) {
+	switch v.kind {
+	case NullKind:
+		return nil, nil
+	case ListKind:
+		return v.list, nil
+	case StringKind:
+		if pv, err := ParseJSON(v.s); err == nil && pv.kind == ListKind {
+			return pv.list, nil
+		}
+		return nil, fmt.Errorf("%w: '%s' to list", ErrValueConvert, v.s)
+	}
+	return nil, fmt.Errorf("%w: %s to list", ErrValueConvert, v.kind)
+}
+
+// Map converts the value to map: null is empty, the string must be a JSON object, the other types can't be
+// converted.
+func (v Value) Map() (map[string]Value, error) {
+	switch v.kind {
+	case NullKind:
+		return nil, nil
+	case MapKind:
+		return v.m, nil
+	case StringKind:
+		if pv, err := ParseJSON(v.s); err == nil && pv.kind == MapKind {
+			return pv.m, nil
+		}
+		return nil, fmt.Errorf("%w: '%s' to map", ErrValueConvert, v.s)
+	}
+	return nil, fmt.Errorf("%w: %s to map", ErrValueConvert, v.kind)
+}
+
+// Lookup returns the value at the path, the keys of maps follow '.' and the indexes of lists are in '[]', e.g.
+// '[0].path'. It returns null if the path doesn't exist, the string is parsed as JSON when it's accessed by the path.
+func (v Value) Lookup(path string) Value {
+	cur := v
+	for path != "" {
+		if cur.kind == StringKind {
+			pv, err := ParseJSON(cur.s)
+			if err != nil {
+				return NullValue()
+			}
+			cur = pv
+		}
+		switch path[0] {
+		case '.':
+			key := path[1:]
+			if i := strings.IndexAny(key, ".["); i != -1 {
+				key, path = key[:i], key[i:]
+			} else {
+				path = ""
+			}
+			if cur.kind != MapKind {
+				return NullValue()
+			}
+			e, ok := cur.m[key]
+			if !ok {
+				return NullValue()
+			}
+			cur = e
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end == -1 {
+				return NullValue()
+			}
+			idx, err := strconv.Atoi(path[1:end])
+			path = path[end+1:]
+			if err != nil || cur.kind != ListKind || idx < 0 || idx >= len(cur.list) {
+				return NullValue()
+			}
+			cur = cur.list[idx]
+		default:
+			return NullValue()
+		}
+	}
+	return cur
+}
@@ -2,12 +2,15 @@ package eval
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 
 	"github.com/Knetic/govaluate"
 )
 
+// New evaluates the expression, the functions returned by 'Functions' can be called in it.
 func New(s string) (interface{}, error) {
-	exp, err := govaluate.NewEvaluableExpression(s)
+	exp, err := govaluate.NewEvaluableExpressionWithFunctions(s, expressionFunctions)
 	if err != nil {
 		return nil, err
 	}
@@ -49,14 +52,34 @@ func String(s string) (string, error) {
 	}
 	switch v := res.(type) {
 	case float64:
-		return fmt.Sprint(v), nil
+		return FormatNumber(v), nil
 	case bool:
 		if v {
 			return "true", nil
 		} else {
 			return "false", nil
 		}
+	case string:
+		return v, nil
 	default:
 		return "", fmt.Errorf("invalid eval type: '%s'", s)
 	}
 }
+
+// IsInteger returns true if the number is an integer that can be represented by int64
+func IsInteger(f float64) bool {
+	return f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64
+}
+
+// FormatNumber formats the number without the exponent, e.g. '1e+21' is '1000000000000000000000', the fractions
+// are rounded to 15 significant digits, so '1/3' is '0.333333333333333' and '0.1+0.2' is '0.3'.
+func FormatNumber(f float64) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	if IsInteger(f) {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
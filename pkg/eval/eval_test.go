@@ -0,0 +1,117 @@
+package eval
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunctions(t *testing.T) {
+	testingdata := []struct {
+		exp    string
+		expect string
+	}{
+		{`len("héllo")`, "5"},
+		{`len("abc") > 2`, "true"},
+		{`upper("abc")`, "ABC"},
+		{`lower("ABC")`, "abc"},
+		{`trim("  abc ")`, "abc"},
+		{`trim("--abc-", "-")`, "abc"},
+		{`replace("a-b-c", "-", "_")`, "a_b_c"},
+		{`contains("abc", "b")`, "true"},
+		{`split("a,b,c", ",")`, `["a","b","c"]`},
+		{`split("a,b,c", ",", 1)`, "b"},
+		{`split("a,b,c", ",", 5)`, ""},
+		{`join(split("a,b,c", ","), "-")`, "a-b-c"},
+		{`join("[1, true, \"x\"]", " ")`, "1 true x"},
+		{`match("v1.2.3", "^v[0-9]+")`, "true"},
+		{`match("1.2.3", "^v[0-9]+")`, "false"},
+		{`default("", "x")`, "x"},
+		{`default("a", "x")`, "a"},
+		{`now("2006") == "` + time.Now().Format("2006") + `"`, "true"},
+		{`base64("hello")`, "aGVsbG8="},
+		{`sha256("hello")`, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{`json_get("{\"a\": {\"b\": [1, \"x\"]}}", "a.b.1")`, "x"},
+		{`json_get("{\"a\": {\"b\": [1, \"x\"]}}", "a.b")`, `[1,"x"]`},
+		{`json_get("{\"a\": 1}", "b")`, ""},
+		{`upper(lower("AbC")) == "ABC" && len("a") == 1`, "true"},
+	}
+	for _, tt := range testingdata {
+		assert.NoError(t, Check(tt.exp), tt.exp)
+		v, err := String(tt.exp)
+		assert.NoError(t, err, tt.exp)
+		assert.Equal(t, tt.expect, v, tt.exp)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	testingdata := []struct {
+		exp string
+		err error
+	}{
+		{`upper()`, ErrArgumentNumber},
+		{`upper("a", "b")`, ErrArgumentNumber},
+		{`replace("a", "b")`, ErrArgumentNumber},
+		{`trim(upper("a"), "b", "c")`, ErrArgumentNumber},
+		{`foo("a")`, ErrFunctionUnknown},
+		{`upper(foo("a"))`, ErrFunctionUnknown},
+	}
+	for _, tt := range testingdata {
+		assert.ErrorIs(t, Check(tt.exp), tt.err, tt.exp)
+	}
+	assert.NoError(t, Check(`now() != "" && upper("foo(1)") == "FOO(1)"`))
+	// the error names the function of the token
+	assert.ErrorContains(t, Check(`trim(upper("a"), "b", "c")`), "function 'trim'")
+	assert.ErrorContains(t, Check(`len(upper("a", "b"))`), "function 'upper'")
+
+	_, err := String(`json_get("x", "a")`)
+	assert.ErrorIs(t, err, ErrArgumentIllegal)
+	_, err = String(`split("a", ",", "x")`)
+	assert.ErrorIs(t, err, ErrArgumentIllegal)
+}
+
+func TestFormatNumber(t *testing.T) {
+	testingdata := []struct {
+		f      float64
+		expect string
+	}{
+		{1.0 / 3, "0.333333333333333"},
+		{0.1 + 0.2, "0.3"},
+		{2, "2"},
+		{-1.5, "-1.5"},
+		{1e20, "100000000000000000000"},
+		{123456789012, "123456789012"},
+	}
+	for _, d := range testingdata {
+		assert.Equal(t, d.expect, FormatNumber(d.f))
+	}
+	assert.True(t, IsInteger(3))
+	assert.False(t, IsInteger(3.5))
+}
+
+func TestInteger(t *testing.T) {
+	testingdata := []struct {
+		s      string
+		expect int64
+		ok     bool
+	}{
+		{"10000000000000000 + 1", 10000000000000001, true},
+		{"9007199254740993 + 0", 9007199254740993, true},
+		{"-(2 + 3) * 4 % 7", -6, true},
+		{"6 / 3", 2, true},
+		// the others are calculated by float64
+		{"1 / 3", 0, false},
+		{"1 / 0", 0, false},
+		{"1.5 + 1", 0, false},
+		{"9223372036854775807 + 1", 0, false},
+		{"1 < 2", 0, false},
+		{"(1 + 2", 0, false},
+		{`"1" + 2`, 0, false},
+	}
+	for _, d := range testingdata {
+		n, ok := Integer(d.s)
+		assert.Equal(t, d.ok, ok, d.s)
+		assert.Equal(t, d.expect, n, d.s)
+	}
+}
@@ -0,0 +1,308 @@
+package eval
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Knetic/govaluate"
+)
+
+var (
+	ErrFunctionUnknown = errors.New("unknown function")
+	ErrArgumentNumber  = errors.New("argument number not match")
+	ErrArgumentIllegal = errors.New("argument illegal")
+)
+
+// function is a helper that can be called in the expressions, e.g. 'upper("$(name)")', all arguments are
+// converted to strings, the lists are represented by the JSON arrays.
+type function struct {
+	// min and max are the number of arguments, the max is -1 if it's unlimited
+	min  int
+	max  int
+	call func(args []string) (interface{}, error)
+}
+
+var functions = map[string]function{
+	"len": {1, 1, func(args []string) (interface{}, error) {
+		return float64(utf8.RuneCountInString(args[0])), nil
+	}},
+	"upper": {1, 1, func(args []string) (interface{}, error) {
+		return strings.ToUpper(args[0]), nil
+	}},
+	"lower": {1, 1, func(args []string) (interface{}, error) {
+		return strings.ToLower(args[0]), nil
+	}},
+	// trim(s) removes the leading and trailing spaces, trim(s, cutset) removes the characters in 'cutset'
+	"trim": {1, 2, func(args []string) (interface{}, error) {
+		if len(args) == 2 {
+			return strings.Trim(args[0], args[1]), nil
+		}
+		return strings.TrimSpace(args[0]), nil
+	}},
+	"replace": {3, 3, func(args []string) (interface{}, error) {
+		return strings.ReplaceAll(args[0], args[1], args[2]), nil
+	}},
+	"contains": {2, 2, func(args []string) (interface{}, error) {
+		return strings.Contains(args[0], args[1]), nil
+	}},
+	// split(s, sep) returns the JSON array of the substrings, split(s, sep, n) returns the n-th substring
+	"split": {2, 3, func(args []string) (interface{}, error) {
+		fields := strings.Split(args[0], args[1])
+		if len(args) == 2 {
+			return jsonString(fields)
+		}
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: split index '%s'", ErrArgumentIllegal, args[2])
+		}
+		if n >= len(fields) {
+			return "", nil
+		}
+		return fields[n], nil
+	}},
+	// join(list, sep) joins the elements of the JSON array
+	"join": {2, 2, func(args []string) (interface{}, error) {
+		var list []interface{}
+		if err := json.Unmarshal([]byte(args[0]), &list); err != nil {
+			return nil, fmt.Errorf("%w: join '%s' isn't a JSON array", ErrArgumentIllegal, args[0])
+		}
+		var elems []string
+		for _, e := range list {
+			s, err := toString(e)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, s)
+		}
+		return strings.Join(elems, args[1]), nil
+	}},
+	"match": {2, 2, func(args []string) (interface{}, error) {
+		re, err := regexp.Compile(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: match pattern: %s", ErrArgumentIllegal, err)
+		}
+		return re.MatchString(args[0]), nil
+	}},
+	// default(s, def) returns 'def' if 's' is empty
+	"default": {2, 2, func(args []string) (interface{}, error) {
+		if args[0] == "" {
+			return args[1], nil
+		}
+		return args[0], nil
+	}},
+	// now() returns the current time in RFC3339, now(layout) formats it by the layout of Go
+	"now": {0, 1, func(args []string) (interface{}, error) {
+		layout := time.RFC3339
+		if len(args) == 1 {
+			layout = args[0]
+		}
+		return time.Now().Format(layout), nil
+	}},
+	"base64": {1, 1, func(args []string) (interface{}, error) {
+		return base64.StdEncoding.EncodeToString([]byte(args[0])), nil
+	}},
+	"sha256": {1, 1, func(args []string) (interface{}, error) {
+		sum := sha256.Sum256([]byte(args[0]))
+		return hex.EncodeToString(sum[:]), nil
+	}},
+	// json_get(s, path) returns the value in the JSON by the path, e.g. 'a.b.0', the objects and arrays are
+	// returned as JSON, it returns empty if the path isn't found
+	"json_get": {2, 2, func(args []string) (interface{}, error) {
+		var v interface{}
+		if err := json.Unmarshal([]byte(args[0]), &v); err != nil {
+			return nil, fmt.Errorf("%w: json_get '%s' isn't a JSON: %s", ErrArgumentIllegal, args[0], err)
+		}
+		if args[1] != "" {
+			for _, key := range strings.Split(args[1], ".") {
+				switch x := v.(type) {
+				case map[string]interface{}:
+					v = x[key]
+				case []interface{}:
+					i, err := strconv.Atoi(key)
+					if err != nil || i < 0 || i >= len(x) {
+						return "", nil
+					}
+					v = x[i]
+				default:
+					return "", nil
+				}
+			}
+		}
+		switch v.(type) {
+		case nil:
+			return "", nil
+		case map[string]interface{}, []interface{}:
+			return jsonString(v)
+		}
+		return v, nil
+	}},
+}
+
+// Functions returns the names of the functions that can be called in the expressions
+func Functions() []string {
+	var names []string
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nameProbe is the argument that makes the wrapped function return its name, the tokens of govaluate only keep
+// the functions, not their names.
+type nameProbe struct{}
+
+// expressionFunctions wraps the functions for govaluate, the arguments are converted to strings
+var expressionFunctions = func() map[string]govaluate.ExpressionFunction {
+	m := make(map[string]govaluate.ExpressionFunction)
+	for name, f := range functions {
+		name, f := name, f
+		m[name] = func(args ...interface{}) (interface{}, error) {
+			if len(args) == 1 {
+				if _, ok := args[0].(nameProbe); ok {
+					return name, nil
+				}
+			}
+			if err := f.checkArity(name, len(args)); err != nil {
+				return nil, err
+			}
+			var ss []string
+			for _, arg := range args {
+				s, err := toString(arg)
+				if err != nil {
+					return nil, fmt.Errorf("%w: function '%s': %s", ErrArgumentIllegal, name, err)
+				}
+				ss = append(ss, s)
+			}
+			return f.call(ss)
+		}
+	}
+	return m
+}()
+
+func (f function) checkArity(name string, n int) error {
+	if n < f.min || (f.max != -1 && n > f.max) {
+		expect := strconv.Itoa(f.min)
+		if f.max != f.min {
+			expect += "-" + strconv.Itoa(f.max)
+		}
+		return fmt.Errorf("%w: function '%s', actual %d, expect %s", ErrArgumentNumber, name, n, expect)
+	}
+	return nil
+}
+
+// Check parses the expression to check the functions and the number of their arguments without evaluating it.
+func Check(s string) error {
+	exp, err := govaluate.NewEvaluableExpressionWithFunctions(s, expressionFunctions)
+	if err != nil {
+		if name := unknownFunction(s); name != "" {
+			return fmt.Errorf("%w: '%s'", ErrFunctionUnknown, name)
+		}
+		return err
+	}
+	tokens := exp.Tokens()
+	for i, t := range tokens {
+		if t.Kind != govaluate.FUNCTION {
+			continue
+		}
+		// the function is followed by the clause of arguments, the separators at the top level of the clause
+		// split the arguments
+		var (
+			depth int
+			seps  int
+			args  bool
+		)
+	scan:
+		for _, a := range tokens[i+2:] {
+			switch a.Kind {
+			case govaluate.CLAUSE:
+				depth++
+			case govaluate.CLAUSE_CLOSE:
+				if depth == 0 {
+					break scan
+				}
+				depth--
+			case govaluate.SEPARATOR:
+				if depth == 0 {
+					seps++
+				}
+			}
+			args = true
+		}
+		n := 0
+		if args {
+			n = seps + 1
+		}
+		name := functionName(t)
+		if err := functions[name].checkArity(name, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var callPattern = regexp.MustCompile(`([a-zA-Z_][a-zA-Z0-9_]*)\s*\(`)
+
+// unknownFunction returns the first called name that isn't a function, govaluate can't parse the calls of
+// the unknown functions.
+func unknownFunction(s string) string {
+	for _, m := range callPattern.FindAllStringSubmatch(stripStrings(s), -1) {
+		if _, ok := functions[m[1]]; !ok {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// functionName returns the name of the function token, the value of the token is the wrapped function, it returns
+// its name when it's called with the 'nameProbe'.
+func functionName(t govaluate.ExpressionToken) string {
+	fn, ok := t.Value.(govaluate.ExpressionFunction)
+	if !ok {
+		return ""
+	}
+	name, _ := fn(nameProbe{})
+	s, _ := name.(string)
+	return s
+}
+
+var stringPattern = regexp.MustCompile(`"(\\.|[^"\\])*"|'(\\.|[^'\\])*'`)
+
+// stripStrings removes the string literals from the expression, so the text in strings isn't a call
+func stripStrings(s string) string {
+	return stringPattern.ReplaceAllString(s, `""`)
+}
+
+func toString(v interface{}) (string, error) {
+	switch x := v.(type) {
+	case string:
+		return x, nil
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case nil:
+		return "", nil
+	case time.Time:
+		return x.Format(time.RFC3339), nil
+	}
+	return "", fmt.Errorf("unsupported type '%T'", v)
+}
+
+func jsonString(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,161 @@
+package eval
+
+import (
+	"math"
+	"strconv"
+)
+
+// Integer calculates the expression that only has the integers, '+', '-', '*', '/', '%' and the parentheses by
+// int64, because govaluate calculates by float64 that loses the precision of the integers larger than 2^53. The
+// 'ok' is false if the expression has the others, the result overflows or isn't an integer, e.g. '1 / 3', then
+// it should be calculated by New.
+func Integer(s string) (n int64, ok bool) {
+	p := &intParser{s: s}
+	n, ok = p.expr()
+	if !ok {
+		return 0, false
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return 0, false
+	}
+	return n, true
+}
+
+type intParser struct {
+	s   string
+	pos int
+}
+
+func (p *intParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// next returns the next operator or parenthesis without consuming it
+func (p *intParser) next() byte {
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+// expr := term (('+' | '-') term)*
+func (p *intParser) expr() (int64, bool) {
+	a, ok := p.term()
+	if !ok {
+		return 0, false
+	}
+	for {
+		op := p.next()
+		if op != '+' && op != '-' {
+			return a, true
+		}
+		p.pos++
+		b, ok := p.term()
+		if !ok {
+			return 0, false
+		}
+		if op == '-' {
+			if b == math.MinInt64 {
+				return 0, false
+			}
+			b = -b
+		}
+		if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+			return 0, false
+		}
+		a += b
+	}
+}
+
+// term := unary (('*' | '/' | '%') unary)*
+func (p *intParser) term() (int64, bool) {
+	a, ok := p.unary()
+	if !ok {
+		return 0, false
+	}
+	for {
+		op := p.next()
+		if op != '*' && op != '/' && op != '%' {
+			return a, true
+		}
+		p.pos++
+		b, ok := p.unary()
+		if !ok {
+			return 0, false
+		}
+		switch op {
+		case '*':
+			if a != 0 && b != 0 {
+				r := a * b
+				if r/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+					return 0, false
+				}
+			}
+			a *= b
+		case '/':
+			if b == 0 || a%b != 0 || (a == math.MinInt64 && b == -1) {
+				return 0, false
+			}
+			a /= b
+		case '%':
+			if b == 0 || (a == math.MinInt64 && b == -1) {
+				return 0, false
+			}
+			a %= b
+		}
+	}
+}
+
+// unary := ('-' | '+') unary | primary
+func (p *intParser) unary() (int64, bool) {
+	switch p.next() {
+	case '-':
+		p.pos++
+		n, ok := p.unary()
+		if !ok || n == math.MinInt64 {
+			return 0, false
+		}
+		return -n, true
+	case '+':
+		p.pos++
+		return p.unary()
+	}
+	return p.primary()
+}
+
+// primary := integer | '(' expr ')'
+func (p *intParser) primary() (int64, bool) {
+	if p.next() == '(' {
+		p.pos++
+		n, ok := p.expr()
+		if !ok || p.next() != ')' {
+			return 0, false
+		}
+		p.pos++
+		return n, true
+	}
+	begin := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	if begin == p.pos {
+		return 0, false
+	}
+	// the integer is followed by a '.' or a letter, e.g. '1.5' or '1e3', isn't an integer
+	if p.pos < len(p.s) && (p.s[p.pos] == '.' || isLetter(p.s[p.pos])) {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(p.s[begin:p.pos], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -45,6 +45,7 @@ func Symbol(x rune) bool {
 		':',
 		'+', '-', '*', '/', '%',
 		'(', ')',
+		',',
 	}
 	for _, c := range symbols {
 		if c == x {
@@ -56,7 +57,7 @@ func Symbol(x rune) bool {
 
 func Arithmetic(s string) bool {
 	symbols := []string{
-		"+", "-", "*", "/", "%",
+		"+", "-", "*", "/", "%", "<<", ">>",
 	}
 	for _, c := range symbols {
 		if c == s {
@@ -81,3 +82,7 @@ func Ident(x rune) bool {
 	}
 	return false
 }
+
+func Letter(x rune) bool {
+	return x >= 'a' && x <= 'z' || x >= 'A' && x <= 'Z'
+}
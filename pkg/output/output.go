@@ -28,7 +28,9 @@ func (o *Output) Write(p []byte) (n int, err error) {
 			i = i + end + 1
 			continue
 		}
-		o.buffer = p[i:]
+		// The caller may reuse 'p' after Write returns, e.g. the memory of a wasm guest, so the unfinished line
+		// is copied into the buffer owned by the Output.
+		o.buffer = append(o.buffer, p[i:]...)
 		break
 	}
 	if o.W != nil {
@@ -92,3 +92,22 @@ upstream        https://github.com/cofunclabs/cofunc.git (push)
 
 	assert.Len(t, rows, 1)
 }
+
+func TestOutputReusedBuffer(t *testing.T) {
+	var lines []string
+	out := &Output{
+		HandleFunc: func(line []byte) {
+			lines = append(lines, string(line))
+		},
+	}
+	// The line is split across the writes, and the writer reuses the same slice every time
+	p := make([]byte, 16)
+	for _, data := range []string{"::return::", "k=v\nhel", "lo", "\nfoo"} {
+		n := copy(p, data)
+		out.Write(p[:n])
+		copy(p, "xxxxxxxxxxxxxxxx")
+	}
+	out.Close()
+
+	assert.Equal(t, []string{"::return::k=v\n", "hello\n", "foo"}, lines)
+}
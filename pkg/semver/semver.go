@@ -0,0 +1,218 @@
+package semver
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Latest is the constraint that matches any normal version, the highest version will be chosen.
+const Latest = "latest"
+
+var ErrInvalidVersion = errors.New("invalid version")
+
+// preIdentifier is the pattern of the dot-separated identifier of the pre-release version
+var preIdentifier = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+
+// Version is a semantic version, e.g. 'v1.2.3' or '1.2.3-rc1'
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	Pre   string
+	// parts is the number of the version numbers that be specified, e.g. it's 2 for '1.2'
+	parts int
+}
+
+// Parse parses a version string, the 'v' prefix is optional, and the minor and patch numbers can be omitted.
+func Parse(s string) (Version, error) {
+	var v Version
+	raw := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(raw, '-'); i != -1 {
+		v.Pre = raw[i+1:]
+		raw = raw[:i]
+		for _, id := range strings.Split(v.Pre, ".") {
+			if !preIdentifier.MatchString(id) {
+				return Version{}, fmt.Errorf("%w: '%s'", ErrInvalidVersion, s)
+			}
+		}
+	}
+	fields := strings.Split(raw, ".")
+	if len(fields) > 3 {
+		return Version{}, fmt.Errorf("%w: '%s'", ErrInvalidVersion, s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("%w: '%s'", ErrInvalidVersion, s)
+		}
+		*nums[i] = n
+	}
+	v.parts = len(fields)
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 if the version is less than, equal to or greater than the other version.
+// A pre-release version is less than the normal version.
+func (v Version) Compare(o Version) int {
+	a := []int{v.Major, v.Minor, v.Patch}
+	b := []int{o.Major, o.Minor, o.Patch}
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	case v.Pre < o.Pre:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// Constraint is a version constraint, it's one of the following:
+//
+//	latest or empty: any normal version
+//	1.2.3:  exactly 1.2.3, the omitted numbers match any number, e.g. '1.2' matches '1.2.x'
+//	^1.2.3: >= 1.2.3 and < 2.0.0, if the major is 0, it's >= 0.2.3 and < 0.3.0
+//	~1.2.3: >= 1.2.3 and < 1.3.0
+//	>=1.2.3: >= 1.2.3
+//
+// The pre-release versions are matched only if they are requested explicitly by the constraint that has a
+// pre-release version, e.g. '>=1.2.3-rc1' or '2.0.0-rc1'.
+type Constraint struct {
+	raw   string
+	op    string
+	bound Version
+}
+
+// ParseConstraint parses a constraint string.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: s}
+	s = strings.TrimSpace(s)
+	if s == "" || s == Latest {
+		return c, nil
+	}
+	for _, op := range []string{">=", "^", "~"} {
+		if strings.HasPrefix(s, op) {
+			c.op = op
+			s = strings.TrimPrefix(s, op)
+			break
+		}
+	}
+	if c.op == "" {
+		c.op = "="
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return Constraint{}, fmt.Errorf("%w: constraint '%s'", err, c.raw)
+	}
+	c.bound = v
+	return c, nil
+}
+
+// IsLatest returns true if the constraint matches any normal version.
+func (c Constraint) IsLatest() bool {
+	return c.op == ""
+}
+
+// Match checks the version satisfies the constraint or not.
+func (c Constraint) Match(v Version) bool {
+	b := c.bound
+	if v.Pre != "" && b.Pre == "" {
+		return false
+	}
+	switch c.op {
+	case "":
+		return true
+	case "=":
+		if b.Pre != "" || b.parts == 3 {
+			return v.Compare(b) == 0
+		}
+		if v.Major != b.Major {
+			return false
+		}
+		return b.parts < 2 || v.Minor == b.Minor
+	case ">=":
+		return v.Compare(b) >= 0
+	case "^":
+		if v.Compare(b) < 0 {
+			return false
+		}
+		if b.Major == 0 && b.parts > 1 {
+			return v.Major == 0 && v.Minor == b.Minor
+		}
+		return v.Major == b.Major
+	case "~":
+		if v.Compare(b) < 0 {
+			return false
+		}
+		if b.parts < 2 {
+			return v.Major == b.Major
+		}
+		return v.Major == b.Major && v.Minor == b.Minor
+	}
+	return false
+}
+
+func (c Constraint) String() string {
+	if c.IsLatest() {
+		return Latest
+	}
+	return c.raw
+}
+
+// Highest returns the highest version in the 'versions' that satisfies the constraint, the invalid versions
+// are ignored. It returns false if no version satisfies the constraint.
+func (c Constraint) Highest(versions []string) (string, bool) {
+	var (
+		found   bool
+		highest Version
+		result  string
+	)
+	for _, s := range versions {
+		v, err := Parse(s)
+		if err != nil || !c.Match(v) {
+			continue
+		}
+		if !found || v.Compare(highest) > 0 {
+			found = true
+			highest = v
+			result = s
+		}
+	}
+	return result, found
+}
+
+// Sort sorts the versions in ascending order, the invalid versions are placed at the beginning.
+func Sort(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		a, erra := Parse(versions[i])
+		b, errb := Parse(versions[j])
+		if erra != nil || errb != nil {
+			return erra != nil && errb == nil
+		}
+		return a.Compare(b) < 0
+	})
+}
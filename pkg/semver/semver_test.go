@@ -0,0 +1,122 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		s       string
+		version string
+		err     bool
+	}{
+		{"1.2.3", "1.2.3", false},
+		{"v1.2.3", "1.2.3", false},
+		{"v1", "1.0.0", false},
+		{"1.2", "1.2.0", false},
+		{"1.2.3-rc1", "1.2.3-rc1", false},
+		{"1.2.3.4", "", true},
+		{"a.b", "", true},
+		{"1.2-", "", true},
+		{"1.2.3-rc.1", "1.2.3-rc.1", false},
+		{"1.2.3-rc..1", "", true},
+		{"1.0.0-../../../../tmp/pwn", "", true},
+		{"1.0.0-rc/1", "", true},
+		{"lastest", "", true},
+	}
+	for _, c := range cases {
+		v, err := Parse(c.s)
+		if c.err {
+			assert.ErrorIs(t, err, ErrInvalidVersion, c.s)
+			continue
+		}
+		assert.NoError(t, err, c.s)
+		assert.Equal(t, c.version, v.String())
+	}
+}
+
+func TestConstraintMatch(t *testing.T) {
+	cases := []struct {
+		constraint string
+		version    string
+		match      bool
+	}{
+		{"latest", "0.0.1", true},
+		{"", "3.0.0", true},
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{"v1", "1.9.0", true},
+		{"v1", "2.0.0", false},
+		{"1.2", "1.2.9", true},
+		{"1.2", "1.3.0", false},
+		{"^1.2", "1.2.0", true},
+		{"^1.2", "1.9.9", true},
+		{"^1.2", "1.1.9", false},
+		{"^1.2", "2.0.0", false},
+		{"^0.2.3", "0.2.5", true},
+		{"^0.2.3", "0.3.0", false},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1", "1.3.0", true},
+		{">=1.2", "3.0.0", true},
+		{">=1.2", "1.1.0", false},
+		{"1.2.3", "1.2.3-rc1", false},
+		{">=1.2.3-rc1", "1.2.3", true},
+		{">=1.2.3-rc1", "1.2.3-rc2", true},
+		{"1.2.3-rc1", "1.2.3-rc1", true},
+		{"latest", "2.0.0-rc1", false},
+		{"^1.2", "1.3.0-beta", false},
+		{">=1.2", "3.0.0-rc1", false},
+	}
+	for _, c := range cases {
+		constraint, err := ParseConstraint(c.constraint)
+		if !assert.NoError(t, err, c.constraint) {
+			continue
+		}
+		v, err := Parse(c.version)
+		if !assert.NoError(t, err, c.version) {
+			continue
+		}
+		assert.Equal(t, c.match, constraint.Match(v), "%s %s", c.constraint, c.version)
+	}
+
+	_, err := ParseConstraint("^x")
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+}
+
+func TestConstraintHighest(t *testing.T) {
+	versions := []string{"1.0.0", "v1.2.0", "1.10.1", "2.0.0-rc1", "2.0.0", "invalid"}
+	{
+		c, _ := ParseConstraint("^1.0")
+		v, ok := c.Highest(versions)
+		assert.True(t, ok)
+		assert.Equal(t, "1.10.1", v)
+	}
+	{
+		c, _ := ParseConstraint("latest")
+		v, ok := c.Highest(versions)
+		assert.True(t, ok)
+		assert.Equal(t, "2.0.0", v)
+	}
+	{
+		// the pre-release is excluded even if it's the highest version
+		c, _ := ParseConstraint("latest")
+		v, ok := c.Highest([]string{"1.0.0", "3.0.0-rc1"})
+		assert.True(t, ok)
+		assert.Equal(t, "1.0.0", v)
+
+		c, _ = ParseConstraint(">=3.0.0-rc1")
+		v, ok = c.Highest([]string{"1.0.0", "3.0.0-rc1"})
+		assert.True(t, ok)
+		assert.Equal(t, "3.0.0-rc1", v)
+	}
+	{
+		c, _ := ParseConstraint("^3")
+		_, ok := c.Highest(versions)
+		assert.False(t, ok)
+	}
+	Sort(versions)
+	assert.Equal(t, []string{"invalid", "1.0.0", "v1.2.0", "1.10.1", "2.0.0-rc1", "2.0.0"}, versions)
+}
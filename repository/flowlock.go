@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/cofunclabs/cofunc/functiondriver"
+	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/parser"
+)
+
+// FlowLock pins the exact versions of the functions that loaded by a flow, it's stored next to the flowl source
+// file, e.g. 'build.flowl.lock' for 'build.flowl'.
+type FlowLock struct {
+	// Functions is the pinned functions, the key is the location without version, e.g. 'shell:echo'
+	Functions map[string]Pinned `json:"functions"`
+}
+
+// Pinned is the exact version and checksum of a function when the flow is locked.
+type Pinned struct {
+	Version  string `json:"version"`
+	Checksum string `json:"checksum"`
+}
+
+// FlowLockPath returns the path of the lock file of the flowl source file.
+func FlowLockPath(flowl string) string {
+	return flowl + ".lock"
+}
+
+// LockFlow resolves the versions of all functions that loaded by the flowl source file, then writes them into
+// the lock file of the flow. Only the functions that can be installed by the repository are locked.
+func LockFlow(flowl string) (*FlowLock, error) {
+	f, err := os.Open(flowl)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ast, err := parser.New(f)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &FlowLock{
+		Functions: make(map[string]Pinned),
+	}
+	loads, _, _ := ast.GetBlocks()
+	for _, b := range loads {
+		loc := functiondriver.NewLocation(b.Target1().String())
+		dir, ok := functionDirs[loc.DriverName]
+		if !ok {
+			continue
+		}
+		fdir, mf, err := manifest.Resolve(dir(), loc.FuncPath, loc.Version)
+		if err != nil {
+			return nil, err
+		}
+		if mf.Version == "" {
+			return nil, fmt.Errorf("%w: function '%s' is unversioned, can't be locked", manifest.ErrVersionNotFound, loc)
+		}
+		sum, err := Checksum(fdir)
+		if err != nil {
+			return nil, err
+		}
+		lock.Functions[loc.DriverName+":"+loc.FuncPath] = Pinned{
+			Version:  mf.Version,
+			Checksum: sum,
+		}
+	}
+
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(FlowLockPath(flowl), data, 0644); err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// ReadFlowLock reads the lock file of the flowl source file, it returns nil if the flow isn't locked.
+func ReadFlowLock(flowl string) (*FlowLock, error) {
+	data, err := os.ReadFile(FlowLockPath(flowl))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lock FlowLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("%w: decode '%s'", err, FlowLockPath(flowl))
+	}
+	return &lock, nil
+}
+
+// Versions returns the pinned versions, the key is the location without version.
+func (l *FlowLock) Versions() map[string]string {
+	versions := make(map[string]string)
+	for k, p := range l.Functions {
+		versions[k] = p.Version
+	}
+	return versions
+}
+
+// Verify checks the pinned versions are still installed and not be modified.
+func (l *FlowLock) Verify() error {
+	var keys []string
+	for k := range l.Functions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := l.Functions[k]
+		loc := functiondriver.NewLocation(k)
+		dir, ok := functionDirs[loc.DriverName]
+		if !ok {
+			continue
+		}
+		fdir, _, err := manifest.Resolve(dir(), loc.FuncPath, p.Version)
+		if err != nil {
+			return err
+		}
+		sum, err := Checksum(fdir)
+		if err != nil {
+			return err
+		}
+		if sum != p.Checksum {
+			return fmt.Errorf("%w: '%s@%s'", ErrChecksumMismatch, k, p.Version)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,472 @@
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cofunclabs/cofunc/config"
+	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/pkg/semver"
+)
+
+// lockfileName is the name of the lockfile that records all installed functions, it's stored in $COFUNC_HOME.
+const lockfileName = "functions.lock"
+
+var (
+	ErrInvalidPackage   = errors.New("invalid function package")
+	ErrAlreadyInstalled = errors.New("function already installed")
+	ErrNotInstalled     = errors.New("function not installed")
+	ErrChecksumMismatch = errors.New("function checksum mismatch")
+	ErrUnknownDriver    = errors.New("unknown driver of function")
+	ErrIllegalPath      = errors.New("function path illegal")
+)
+
+// functionName is the pattern of the function name, a category can be used as the prefix, e.g. 'tools/echo'
+var functionName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_\-]*(/[a-zA-Z][a-zA-Z0-9_\-]*)*$`)
+
+// functionDirs are the directories of the drivers that the functions can be installed into.
+var functionDirs = map[string]func() string{
+	"shell": config.ShellDir,
+	"js":    config.JsDir,
+	"wasm":  config.WasmDir,
+}
+
+// Entry is an installed function that recorded in the lockfile.
+type Entry struct {
+	Driver      string    `json:"driver"`
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	Checksum    string    `json:"checksum"`
+	Source      string    `json:"source"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// Location returns the location that can be used in the 'load' statement, e.g. 'shell:echo@1.0.0'
+func (e Entry) Location() string {
+	return e.Driver + ":" + e.Name + "@" + e.Version
+}
+
+// Dir returns the directory that the function is installed into, the lockfile may be edited by hand, so the
+// driver of the entry isn't always known.
+func (e Entry) Dir() (string, error) {
+	dir, ok := functionDirs[e.Driver]
+	if !ok {
+		return "", fmt.Errorf("%w: '%s'", ErrUnknownDriver, e.Location())
+	}
+	path := filepath.Join(dir(), e.Name+"@"+e.Version)
+	if !isInside(dir(), path) {
+		return "", fmt.Errorf("%w: '%s' is outside of '%s'", ErrIllegalPath, e.Location(), dir())
+	}
+	return path, nil
+}
+
+// isInside returns true if the path is in the directory, the path is removed or replaced by installing and
+// uninstalling, so it must not escape from the directory of the driver.
+func isInside(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Verify checks the installed files of the function have not been modified.
+func (e Entry) Verify() error {
+	dir, err := e.Dir()
+	if err != nil {
+		return err
+	}
+	sum, err := Checksum(dir)
+	if err != nil {
+		return err
+	}
+	if sum != e.Checksum {
+		return fmt.Errorf("%w: '%s'", ErrChecksumMismatch, e.Location())
+	}
+	return nil
+}
+
+type lockfile struct {
+	Functions []Entry `json:"functions"`
+}
+
+func lockfilePath() string {
+	return filepath.Join(config.HomeDir(), lockfileName)
+}
+
+func readLockfile() (*lockfile, error) {
+	data, err := os.ReadFile(lockfilePath())
+	if os.IsNotExist(err) {
+		return &lockfile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lf lockfile
+	if err := json.Unmarshal(data, &lf); err != nil {
+		return nil, fmt.Errorf("%w: decode '%s'", err, lockfilePath())
+	}
+	return &lf, nil
+}
+
+func (lf *lockfile) write() error {
+	sort.Slice(lf.Functions, func(i, j int) bool {
+		a, b := lf.Functions[i], lf.Functions[j]
+		if a.Driver != b.Driver {
+			return a.Driver < b.Driver
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		va, _ := semver.Parse(a.Version)
+		vb, _ := semver.Parse(b.Version)
+		return va.Compare(vb) < 0
+	})
+	data, err := json.MarshalIndent(lf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(lockfilePath(), data, 0644)
+}
+
+func (lf *lockfile) find(driver, name, version string) (Entry, bool) {
+	for _, e := range lf.Functions {
+		if e.Driver == driver && e.Name == name && e.Version == version {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Install installs a function package into $COFUNC_HOME, the source can be a local directory, a git working
+// directory or a tarball(.tar, .tar.gz, .tgz). The package must contain a valid 'manifest.json' at the root
+// directory, the version in the manifest is required. If 'force' is true, the installed same version will be
+// replaced.
+func Install(src string, force bool) (Entry, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return Entry{}, err
+	}
+	root := src
+	if !info.IsDir() {
+		tmp, err := os.MkdirTemp("", "cofunc-install-")
+		if err != nil {
+			return Entry{}, err
+		}
+		defer os.RemoveAll(tmp)
+		if err := extract(src, tmp); err != nil {
+			return Entry{}, err
+		}
+		root = tmp
+	}
+	root, err = findPackageRoot(root)
+	if err != nil {
+		return Entry{}, err
+	}
+	mf, err := Validate(root)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	lf, err := readLockfile()
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{
+		Driver:      mf.Driver,
+		Name:        mf.Name,
+		Version:     mf.Version,
+		InstalledAt: time.Now(),
+	}
+	if abs, err := filepath.Abs(src); err == nil {
+		entry.Source = abs
+	}
+	dest, err := entry.Dir()
+	if err != nil {
+		return Entry{}, err
+	}
+	if _, err := os.Stat(dest); err == nil {
+		if !force {
+			return Entry{}, fmt.Errorf("%w: '%s'", ErrAlreadyInstalled, entry.Location())
+		}
+	}
+
+	// Copy into a temporary directory first, then rename it, so that a failed installation leaves nothing
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return Entry{}, err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dest), ".install-")
+	if err != nil {
+		return Entry{}, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := copyDir(root, tmp); err != nil {
+		return Entry{}, err
+	}
+	if entry.Checksum, err = Checksum(tmp); err != nil {
+		return Entry{}, err
+	}
+	if !isInside(functionDirs[entry.Driver](), dest) {
+		return Entry{}, fmt.Errorf("%w: '%s'", ErrIllegalPath, dest)
+	}
+	if err := os.RemoveAll(dest); err != nil {
+		return Entry{}, err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return Entry{}, err
+	}
+
+	var functions []Entry
+	for _, e := range lf.Functions {
+		if e.Driver == entry.Driver && e.Name == entry.Name && e.Version == entry.Version {
+			continue
+		}
+		functions = append(functions, e)
+	}
+	lf.Functions = append(functions, entry)
+	if err := lf.write(); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// Uninstall removes the installed function, the target is 'driver:name@version', all installed versions will be
+// removed if the version is omitted.
+func Uninstall(target string) ([]Entry, error) {
+	driver, name, version, err := parseTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	lf, err := readLockfile()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		removed []Entry
+		kept    []Entry
+	)
+	for _, e := range lf.Functions {
+		if e.Driver == driver && e.Name == name && (version == "" || e.Version == version) {
+			removed = append(removed, e)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	if len(removed) == 0 {
+		return nil, fmt.Errorf("%w: '%s'", ErrNotInstalled, target)
+	}
+	for _, e := range removed {
+		dir, err := e.Dir()
+		if err != nil {
+			return nil, err
+		}
+		if !isInside(functionDirs[e.Driver](), dir) {
+			return nil, fmt.Errorf("%w: '%s'", ErrIllegalPath, dir)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, err
+		}
+	}
+	lf.Functions = kept
+	if err := lf.write(); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// List returns all installed functions that recorded in the lockfile.
+func List() ([]Entry, error) {
+	lf, err := readLockfile()
+	if err != nil {
+		return nil, err
+	}
+	return lf.Functions, nil
+}
+
+// Lookup returns the installed function that recorded in the lockfile.
+func Lookup(driver, name, version string) (Entry, bool, error) {
+	lf, err := readLockfile()
+	if err != nil {
+		return Entry{}, false, err
+	}
+	e, ok := lf.find(driver, name, version)
+	return e, ok, nil
+}
+
+// Validate checks the manifest of the function package in the directory.
+func Validate(dir string) (*manifest.Manifest, error) {
+	mf, err := manifest.LoadFile(filepath.Join(dir, manifest.FileName))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPackage, err)
+	}
+	if !functionName.MatchString(mf.Name) || !isPathSafe(mf.Name) {
+		return nil, fmt.Errorf("%w: invalid function name '%s'", ErrInvalidPackage, mf.Name)
+	}
+	if _, ok := functionDirs[mf.Driver]; !ok {
+		return nil, fmt.Errorf("%w: unsupported driver '%s' of function '%s'", ErrInvalidPackage, mf.Driver, mf.Name)
+	}
+	if mf.Version == "" {
+		return nil, fmt.Errorf("%w: not found version of function '%s'", ErrInvalidPackage, mf.Name)
+	}
+	if _, err := semver.Parse(mf.Version); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPackage, err)
+	}
+	// the version is a part of the installed directory, e.g. 'echo@1.0.0'
+	if strings.ContainsAny(mf.Version, `/\`) || !isPathSafe(mf.Version) {
+		return nil, fmt.Errorf("%w: invalid version '%s' of function '%s'", ErrInvalidPackage, mf.Version, mf.Name)
+	}
+	if mf.Entrypoint == "" {
+		return nil, fmt.Errorf("%w: not found entrypoint of function '%s'", ErrInvalidPackage, mf.Name)
+	}
+	entrypoint := filepath.Join(dir, mf.Entrypoint)
+	if !strings.HasPrefix(entrypoint, filepath.Clean(dir)+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%w: entrypoint is outside of the package '%s'", ErrInvalidPackage, mf.Entrypoint)
+	}
+	if info, err := os.Stat(entrypoint); err != nil || info.IsDir() {
+		return nil, fmt.Errorf("%w: not found entrypoint file '%s'", ErrInvalidPackage, mf.Entrypoint)
+	}
+	return mf, nil
+}
+
+// isPathSafe returns false if the name contains '..' or the backslash, the '/' in the function name is the
+// separator of the category.
+func isPathSafe(name string) bool {
+	return !strings.Contains(name, "..") && !strings.ContainsRune(name, '\\')
+}
+
+// Checksum calculates the sha256 checksum of all files in the directory, the relative path and the content of
+// every file are included.
+func Checksum(dir string) (string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, path := range files {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return "", err
+		}
+		io.WriteString(h, filepath.ToSlash(rel))
+		h.Write([]byte{0})
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
+
+// parseTarget parses 'driver:name@version', the version is optional.
+func parseTarget(target string) (driver, name, version string, err error) {
+	fields := strings.SplitN(target, ":", 2)
+	if len(fields) != 2 || fields[1] == "" {
+		return "", "", "", fmt.Errorf("invalid function '%s', the format is 'driver:name[@version]'", target)
+	}
+	driver, name = fields[0], fields[1]
+	if i := strings.LastIndex(name, "@"); i != -1 {
+		name, version = name[:i], name[i+1:]
+	}
+	return driver, name, version, nil
+}
+
+// findPackageRoot returns the directory that contains the manifest, it's the root directory or the only
+// sub-directory of the root, e.g. a tarball that contains a top-level directory.
+func findPackageRoot(root string) (string, error) {
+	if _, err := os.Stat(filepath.Join(root, manifest.FileName)); err == nil {
+		return root, nil
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return "", err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != ".git" {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	if len(dirs) == 1 {
+		sub := filepath.Join(root, dirs[0])
+		if _, err := os.Stat(filepath.Join(sub, manifest.FileName)); err == nil {
+			return sub, nil
+		}
+	}
+	return "", fmt.Errorf("%w: not found %s", ErrInvalidPackage, manifest.FileName)
+}
+
+// copyDir copies all files from src to dst, the '.git' directory is skipped.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/runtime/actuator"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupHome(t *testing.T) string {
+	home := t.TempDir()
+	os.Setenv("COFUNC_HOME", home)
+	t.Cleanup(func() {
+		os.Unsetenv("COFUNC_HOME")
+	})
+	return home
+}
+
+func writePackage(t *testing.T, dir, version string) {
+	files := map[string]string{
+		"manifest.json": `{"name": "echo", "version": "` + version + `", "driver": "shell", "entrypoint": "entry.sh"}`,
+		"entry.sh":      "#!/bin/sh\necho $COFUNC_MESSAGE\n",
+		".git/HEAD":     "ref: refs/heads/main\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+	}
+}
+
+func writeTarball(t *testing.T, path, version string) {
+	f, err := os.Create(path)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	defer gz.Close()
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	files := map[string]string{
+		"echo/manifest.json": `{"name": "echo", "version": "` + version + `", "driver": "shell", "entrypoint": "entry.sh"}`,
+		"echo/entry.sh":      "#!/bin/sh\necho $COFUNC_MESSAGE\n",
+	}
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0755,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+		tw.Write([]byte(content))
+	}
+}
+
+func TestInstall(t *testing.T) {
+	home := setupHome(t)
+	src := t.TempDir()
+	writePackage(t, src, "1.0.0")
+
+	e, err := Install(src, false)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, "shell:echo@1.0.0", e.Location())
+	assert.True(t, strings.HasPrefix(e.Checksum, "sha256:"))
+	assert.FileExists(t, filepath.Join(home, "shell", "echo@1.0.0", "entry.sh"))
+	assert.NoDirExists(t, filepath.Join(home, "shell", "echo@1.0.0", ".git"))
+	assert.NoError(t, e.Verify())
+
+	_, err = Install(src, false)
+	assert.ErrorIs(t, err, ErrAlreadyInstalled)
+	_, err = Install(src, true)
+	assert.NoError(t, err)
+
+	// install another version from a tarball
+	tarball := filepath.Join(t.TempDir(), "echo.tar.gz")
+	writeTarball(t, tarball, "1.2.0")
+	_, err = Install(tarball, false)
+	assert.NoError(t, err)
+
+	list, err := List()
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "1.0.0", list[0].Version)
+		assert.Equal(t, "1.2.0", list[1].Version)
+	}
+
+	// the installed version can be resolved
+	_, mf, err := manifest.Resolve(filepath.Join(home, "shell"), "echo", "^1.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.0", mf.Version)
+
+	// the modified files can be detected
+	os.WriteFile(filepath.Join(home, "shell", "echo@1.0.0", "entry.sh"), []byte("echo modified"), 0755)
+	assert.ErrorIs(t, list[0].Verify(), ErrChecksumMismatch)
+
+	removed, err := Uninstall("shell:echo@1.0.0")
+	assert.NoError(t, err)
+	assert.Len(t, removed, 1)
+	assert.NoDirExists(t, filepath.Join(home, "shell", "echo@1.0.0"))
+
+	removed, err = Uninstall("shell:echo")
+	assert.NoError(t, err)
+	assert.Len(t, removed, 1)
+	_, err = Uninstall("shell:echo")
+	assert.ErrorIs(t, err, ErrNotInstalled)
+
+	list, err = List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 0)
+}
+
+func TestInstallInvalid(t *testing.T) {
+	setupHome(t)
+	cases := []string{
+		`{"name": "echo", "driver": "shell", "entrypoint": "entry.sh"}`,
+		`{"name": "echo", "version": "x", "driver": "shell", "entrypoint": "entry.sh"}`,
+		`{"name": "echo", "version": "1.0.0", "driver": "go", "entrypoint": "entry.sh"}`,
+		`{"name": "../echo", "version": "1.0.0", "driver": "shell", "entrypoint": "entry.sh"}`,
+		`{"name": "echo", "version": "1.0.0-../../../../tmp/pwn", "driver": "shell", "entrypoint": "entry.sh"}`,
+		`{"name": "echo", "version": "1.0.0-rc\\1", "driver": "shell", "entrypoint": "entry.sh"}`,
+		`{"name": "echo", "version": "1.0.0", "driver": "shell", "entrypoint": "missing.sh"}`,
+		`{"name": "echo", "version": "1.0.0", "driver": "shell", "entrypoint": "../entry.sh"}`,
+	}
+	for _, c := range cases {
+		src := t.TempDir()
+		os.WriteFile(filepath.Join(src, "manifest.json"), []byte(c), 0644)
+		os.WriteFile(filepath.Join(src, "entry.sh"), []byte("echo"), 0755)
+		_, err := Install(src, false)
+		assert.ErrorIs(t, err, ErrInvalidPackage, c)
+	}
+}
+
+func TestUnknownDriver(t *testing.T) {
+	home := setupHome(t)
+	// The lockfile is edited by hand
+	data := `{"functions": [{"driver": "python", "name": "echo", "version": "1.0.0", "checksum": "x"}]}`
+	if err := os.WriteFile(filepath.Join(home, lockfileName), []byte(data), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	list, err := List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	_, err = list[0].Dir()
+	assert.ErrorIs(t, err, ErrUnknownDriver)
+	assert.ErrorIs(t, list[0].Verify(), ErrUnknownDriver)
+
+	_, err = Uninstall("python:echo")
+	assert.ErrorIs(t, err, ErrUnknownDriver)
+}
+
+func TestIllegalPath(t *testing.T) {
+	home := setupHome(t)
+	// The path of the function mustn't escape from the directory of the driver
+	outside := filepath.Join(home, "outside")
+	if err := os.MkdirAll(outside, 0755); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	data := `{"functions": [{"driver": "shell", "name": "echo", "version": "1.0.0/../../outside", "checksum": "x"}]}`
+	if err := os.WriteFile(filepath.Join(home, lockfileName), []byte(data), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	list, err := List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	_, err = list[0].Dir()
+	assert.ErrorIs(t, err, ErrIllegalPath)
+
+	_, err = Uninstall("shell:echo")
+	assert.ErrorIs(t, err, ErrIllegalPath)
+	assert.DirExists(t, outside)
+}
+
+func TestLockFlow(t *testing.T) {
+	setupHome(t)
+	for _, version := range []string{"1.0.0", "1.2.0"} {
+		src := t.TempDir()
+		writePackage(t, src, version)
+		if _, err := Install(src, false); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+	}
+
+	flowl := filepath.Join(t.TempDir(), "testing.flowl")
+	os.WriteFile(flowl, []byte(`
+load "shell:echo@^1.0"
+load "go:print"
+co echo
+co print
+	`), 0644)
+
+	lock, err := LockFlow(flowl)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.Equal(t, map[string]string{"shell:echo": "1.2.0"}, lock.Versions())
+
+	lock, err = ReadFlowLock(flowl)
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Verify())
+
+	// the pinned version is used even if a newer version is installed
+	src := t.TempDir()
+	writePackage(t, src, "1.3.0")
+	Install(src, false)
+	f, _ := os.Open(flowl)
+	defer f.Close()
+	_, _, err = actuator.New(f, actuator.WithPinnedVersions(lock.Versions()))
+	assert.NoError(t, err)
+
+	_, err = Uninstall("shell:echo@1.2.0")
+	assert.NoError(t, err)
+	assert.ErrorIs(t, lock.Verify(), manifest.ErrVersionNotFound)
+
+	lock, err = ReadFlowLock(filepath.Join(t.TempDir(), "notlocked.flowl"))
+	assert.NoError(t, err)
+	assert.Nil(t, lock)
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/cofunclabs/cofunc/functiondriver"
 	"github.com/cofunclabs/cofunc/parser"
+	"github.com/cofunclabs/cofunc/pkg/semver"
 	"github.com/cofunclabs/cofunc/service/resource"
 	"github.com/sirupsen/logrus"
 )
@@ -21,27 +23,42 @@ type RunQueue struct {
 	triggers          []Trigger
 	global            *parser.Block
 	processingForNode *ForNode
+	// pinned stores the exact versions of the functions that be locked, the key is the location without version.
+	pinned map[string]string
 }
 
-func New(rd io.Reader) (*RunQueue, *parser.AST, error) {
+type Option func(*RunQueue)
+
+// WithPinnedVersions makes the 'load' statements resolve the exact versions that be locked, the key of
+// 'versions' is the location without version, e.g. 'shell:echo'.
+func WithPinnedVersions(versions map[string]string) Option {
+	return func(r *RunQueue) {
+		r.pinned = versions
+	}
+}
+
+func New(rd io.Reader, opts ...Option) (*RunQueue, *parser.AST, error) {
 	ast, err := parser.New(rd)
 	if err != nil {
 		return nil, nil, err
 	}
-	r, err := newRunQueue(ast)
+	r, err := newRunQueue(ast, opts...)
 	if err != nil {
 		return nil, nil, err
 	}
 	return r, ast, nil
 }
 
-func newRunQueue(ast *parser.AST) (*RunQueue, error) {
+func newRunQueue(ast *parser.AST, opts ...Option) (*RunQueue, error) {
 	r := &RunQueue{
 		locations:  functiondriver.NewLocationStore(),
 		configured: make(map[string]*TaskNode),
 		steps:      make([]Node, 0),
 		global:     ast.Global(),
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
 	loads, fns, runs := ast.GetBlocks()
 	if err := r.generateLocations(loads); err != nil {
 		return nil, err
@@ -58,6 +75,98 @@ func newRunQueue(ast *parser.AST) (*RunQueue, error) {
 	return r, nil
 }
 
+// Release stops and releases the drivers of all task nodes and triggers, it's the last stage of the lifecycle
+// of a run queue: load -> init -> run* -> release. All errors are collected, so that every driver has the
+// chance to be released.
+func (r *RunQueue) Release(ctx context.Context) error {
+	var errs []string
+	release := func(n Node) {
+		task, ok := n.(*TaskNode)
+		if !ok {
+			return
+		}
+		if err := task.release(ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", task.FormatString(), err))
+		}
+	}
+	r.WalkNode(func(n Node) error {
+		release(n)
+		return nil
+	})
+	for _, tg := range r.triggers {
+		release(tg)
+	}
+	if len(errs) != 0 {
+		return wrapErrorf(ErrReleaseFailed, "%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// CheckFieldRefs checks the references to the fields of the variables that save the return values of functions,
+// e.g. '$(out.now)', the field must be declared as a return value in the manifest of the function. If the function
+// doesn't declare any return values, only a warning is logged and returned, because its return values are unknown.
+// It must be invoked after the drivers are loaded.
+func (r *RunQueue) CheckFieldRefs(refs []parser.VarRef) (warnings []string, err error) {
+	type binding struct {
+		scope *parser.Block
+		name  string
+	}
+	var (
+		declared = make(map[binding]map[string]bool)
+		// undeclared stores the functions that don't declare any return values
+		undeclared = make(map[binding][]string)
+	)
+	collect := func(n Node) {
+		task, ok := n.(*TaskNode)
+		if !ok || !task.needReturns() {
+			return
+		}
+		key := binding{task.co.VarScope(task.returnVar), task.returnVar}
+		if !task.loaded || len(task.driver.Manifest().Usage.ReturnValues) == 0 {
+			undeclared[key] = append(undeclared[key], task.FormatString())
+			return
+		}
+		if declared[key] == nil {
+			declared[key] = make(map[string]bool)
+		}
+		for _, ret := range task.driver.Manifest().Usage.ReturnValues {
+			declared[key][ret.Name] = true
+		}
+	}
+	r.WalkNode(func(n Node) error {
+		collect(n)
+		return nil
+	})
+	for _, tg := range r.triggers {
+		collect(tg)
+	}
+
+	for _, ref := range refs {
+		key := binding{ref.Scope, ref.Var}
+		fields, ok := declared[key]
+		if ok && fields[ref.Field] {
+			continue
+		}
+		if fns, ok := undeclared[key]; ok {
+			warning := fmt.Sprintf("line %d: can't check '$(%s.%s)', the return values aren't declared by '%s'", ref.Line, ref.Var, ref.Field, strings.Join(fns, "', '"))
+			logrus.Warn(warning)
+			warnings = append(warnings, warning)
+			continue
+		}
+		if !ok {
+			// the variable doesn't save the return values
+			continue
+		}
+		var names []string
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return warnings, fmt.Errorf("%w: line %d: '$(%s.%s)', the declared return values are '%s'", ErrReturnValueNotDeclared, ref.Line, ref.Var, ref.Field, strings.Join(names, "', '"))
+	}
+	return warnings, nil
+}
+
 // GetTriggers returns all event triggers
 func (r *RunQueue) GetTriggers() []Trigger {
 	return r.triggers
@@ -159,13 +268,39 @@ func (r *RunQueue) createNode(nodename, fname string) (*TaskNode, error) {
 func (r *RunQueue) generateLocations(blocks []*parser.Block) error {
 	for _, b := range blocks {
 		s := b.Target1().String()
-		if l, err := r.locations.Add(s); err != nil {
+		l, err := r.locations.Add(s)
+		if err != nil {
 			return wrapErrorf(ErrLoadedFunctionDuplicated, "'%s' in load list", l.FuncName)
 		}
+		if err := r.pinLocation(l); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// pinLocation replaces the version constraint of the location with the locked version.
+func (r *RunQueue) pinLocation(l functiondriver.Location) error {
+	version, ok := r.pinned[l.DriverName+":"+l.FuncPath]
+	if !ok {
+		return nil
+	}
+	c, err := semver.ParseConstraint(l.Version)
+	if err != nil {
+		return err
+	}
+	v, err := semver.Parse(version)
+	if err != nil {
+		return err
+	}
+	if !c.Match(v) {
+		return wrapErrorf(ErrLockOutdated, "'%s' is locked to version '%s'", l, version)
+	}
+	l.Version = version
+	r.locations[l.FuncName] = l
+	return nil
+}
+
 func (r *RunQueue) generateConfiguredFn(blocks []*parser.Block) error {
 	for _, b := range blocks {
 		nodename, fname := b.Target1().String(), b.Target2().String()
@@ -247,7 +382,10 @@ func (r *RunQueue) generateSteps(blocks []*parser.Block) error {
 		if !b.Target1().IsEmpty() {
 			names = append(names, b.Target1().String()) // only one
 		} else {
-			names = b.Body().(*parser.ListBody).ToSlice()
+			var err error
+			if names, err = b.Body().(*parser.ListBody).ToSlice(); err != nil {
+				return err
+			}
 		}
 		for _, name := range names {
 			node := r.getConfigured(name)
@@ -342,7 +480,11 @@ func (n *ForNode) Exec(ctx context.Context) error {
 
 func (n *ForNode) execCondition(ctx context.Context) error {
 	// exec 'for condition' expression
-	if !n.b.ExecCondition() {
+	ok, err := n.b.ExecCondition()
+	if err != nil {
+		return err
+	}
+	if !ok {
 		return ErrConditionIsFalse
 	}
 	return nil
@@ -391,6 +533,10 @@ type TaskNode struct {
 
 	_args    *parser.MapBody
 	parallel *TaskNode
+	// loaded is true when the driver is loaded, the loaded driver must be released
+	loaded bool
+	// stub replaces the driver to run the function if it's not nil, see 'WithStub'
+	stub Stub
 }
 
 func (n *TaskNode) Step() int {
@@ -435,8 +581,14 @@ func (n *TaskNode) Name() string {
 	return n.name
 }
 
+// ReturnVar returns the name of the variable that saves the function's return values, it's empty if the return
+// values aren't saved.
+func (n *TaskNode) ReturnVar() string {
+	return n.returnVar
+}
+
 func (n *TaskNode) Init(ctx context.Context, with ...func(context.Context, Node) error) error {
-	with = append(with, withArgs())
+	with = append(with, withArgs(), withArgsValidation())
 	for _, f := range with {
 		if err := f(ctx, n); err != nil {
 			return err
@@ -458,7 +610,22 @@ func (n *TaskNode) Exec(ctx context.Context) error {
 		}
 	}
 
-	rets, err := n.driver.Run(ctx, n.args())
+	// the arguments that contain variables are validated after they are resolved
+	mf := n.driver.Manifest()
+	args, err := n.args()
+	if err != nil {
+		return fmt.Errorf("%w: co '%s'", err, n.name)
+	}
+	args = mf.ApplyDefaults(args)
+	if err := mf.ValidateArgs(args); err != nil {
+		return fmt.Errorf("%w: co '%s'", err, n.name)
+	}
+	var rets map[string]string
+	if n.stub != nil {
+		rets, err = n.stub(ctx, n, args)
+	} else {
+		rets, err = n.driver.Run(ctx, args)
+	}
 	if err != nil {
 		return err
 	}
@@ -468,18 +635,31 @@ func (n *TaskNode) Exec(ctx context.Context) error {
 	return nil
 }
 
+// release stops and releases the driver if it's loaded, it's safe to call it multiple times.
+func (n *TaskNode) release(ctx context.Context) error {
+	if !n.loaded {
+		return nil
+	}
+	n.loaded = false
+	return n.driver.StopAndRelease(ctx)
+}
+
 func (n *TaskNode) execCondition(ctx context.Context) error {
 	if n.co.InSwitch() {
-		if !n.co.ExecCondition() {
+		ok, err := n.co.ExecCondition()
+		if err != nil {
+			return err
+		}
+		if !ok {
 			return ErrConditionIsFalse
 		}
 	}
 	return nil
 }
 
-func (n *TaskNode) args() map[string]string {
+func (n *TaskNode) args() (map[string]string, error) {
 	if n._args == nil {
-		return map[string]string{}
+		return map[string]string{}, nil
 	}
 	return n._args.ToMap()
 }
@@ -503,28 +683,81 @@ func (n *TaskNode) needReturns() bool {
 	return len(n.returnVar) != 0
 }
 
+// argsBlock returns the block that contains the arguments of the node, it's the 'co' block with the arguments
+// or the 'args' block of the 'fn', it returns nil if the node has no arguments.
+func (n *TaskNode) argsBlock() *parser.Block {
+	if n.co.Body() != nil {
+		if _, ok := n.co.Body().(*parser.MapBody); ok {
+			return n.co
+		}
+	}
+	if n.fn != nil {
+		for _, b := range n.fn.Child() {
+			if b.IsArgs() {
+				return b
+			}
+		}
+	}
+	return nil
+}
+
 func withArgs() func(context.Context, Node) error {
 	return func(ctx context.Context, n Node) error {
 		funcnode, ok := n.(*TaskNode)
 		if !ok {
 			return nil
 		}
-		if funcnode.co.Body() != nil {
-			m, ok := funcnode.co.Body().(*parser.MapBody)
-			if ok {
-				funcnode._args = m
-				return nil
-			}
+		if b := funcnode.argsBlock(); b != nil {
+			funcnode._args = b.Body().(*parser.MapBody)
 		}
+		return nil
+	}
+}
 
-		if funcnode.fn != nil {
-			for _, b := range funcnode.fn.Child() {
-				if b.IsArgs() {
-					funcnode._args = b.Body().(*parser.MapBody)
-					return nil
-				}
+// withArgsValidation validates the arguments of the function node by the manifest, only the arguments that
+// don't contain variables are validated, it makes the invalid arguments can be found before the flow running.
+func withArgsValidation() func(context.Context, Node) error {
+	return func(ctx context.Context, n Node) error {
+		funcnode, ok := n.(*TaskNode)
+		if !ok || !funcnode.loaded {
+			return nil
+		}
+		mf := funcnode.driver.Manifest()
+		if err := mf.Validate(); err != nil {
+			return err
+		}
+		var (
+			literals map[string]string
+			// only the keys are checked, the values may reference the variables that aren't calculated yet
+			keys = make(map[string]string)
+		)
+		if funcnode._args != nil {
+			literals = funcnode._args.Literals()
+			for _, k := range funcnode._args.Keys() {
+				keys[k.String()] = ""
 			}
 		}
+		if err := mf.CheckRequired(keys); err != nil {
+			return fmt.Errorf("%w: co '%s'", err, funcnode.name)
+		}
+		if err := mf.ValidateArgs(mf.ApplyDefaults(literals)); err != nil {
+			return fmt.Errorf("%w: co '%s'", err, funcnode.name)
+		}
+		return nil
+	}
+}
+
+// Stub is invoked instead of the function driver when the function node is executed, it receives the resolved
+// arguments and returns the return values of the function.
+type Stub func(ctx context.Context, n *TaskNode, args map[string]string) (map[string]string, error)
+
+// WithStub makes the function node to be executed by the stub rather than the driver, it's used by the dry-run
+// mode. The driver is still loaded, so the arguments and the return values are checked as usual.
+func WithStub(stub Stub) func(context.Context, Node) error {
+	return func(ctx context.Context, n Node) error {
+		if funcnode, ok := n.(*TaskNode); ok {
+			funcnode.stub = stub
+		}
 		return nil
 	}
 }
@@ -535,6 +768,10 @@ func WithResources(resources resource.Resources) func(context.Context, Node) err
 		if !ok {
 			return nil
 		}
-		return funcnode.driver.Load(ctx, resources)
+		if err := funcnode.driver.Load(ctx, resources); err != nil {
+			return err
+		}
+		funcnode.loaded = true
+		return nil
 	}
 }
@@ -116,19 +116,21 @@ func TestParseFullWithRunq(t *testing.T) {
 
 		rq.WalkAndExec(context.Background(), func(nodes []Node) error {
 			node := nodes[0].(*TaskNode)
+			args, err := node.args()
+			assert.NoError(t, err)
 			if node.step == 1 {
 				assert.Equal(t, "f1", node.name)
-				assert.Len(t, node.args(), 2)
-				assert.Equal(t, "v1", node.args()["k"])
+				assert.Len(t, args, 2)
+				assert.Equal(t, "v1", args["k"])
 			}
 			if node.step == 2 {
 				assert.Equal(t, "function2", node.name)
-				assert.Len(t, node.args(), 1)
-				assert.Equal(t, "v2", node.args()["k"])
+				assert.Len(t, args, 1)
+				assert.Equal(t, "v2", args["k"])
 			}
 			if node.step == 3 {
 				assert.Equal(t, "function3", node.name)
-				assert.Len(t, node.args(), 0)
+				assert.Len(t, args, 0)
 			}
 			if node.step == 4 {
 				assert.Equal(t, "function4", node.name)
@@ -137,8 +139,8 @@ func TestParseFullWithRunq(t *testing.T) {
 			}
 			if node.step == 5 {
 				assert.Equal(t, "function3", node.name)
-				assert.Len(t, node.args(), 1)
-				assert.Equal(t, "v3", node.args()["k"])
+				assert.Len(t, args, 1)
+				assert.Equal(t, "v3", args["k"])
 			}
 			return nil
 		})
@@ -167,3 +169,31 @@ func TestParseFullWithRunqWithErr(t *testing.T) {
 		_ = rq
 	}
 }
+
+func TestPinnedVersions(t *testing.T) {
+	const testingdata string = `
+load "shell:echo@^1.0"
+load "go:print"
+	`
+	{
+		rq, _, err := New(strings.NewReader(testingdata), WithPinnedVersions(map[string]string{
+			"shell:echo": "1.2.0",
+		}))
+		if err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		loc, ok := rq.locations.Get("echo")
+		assert.True(t, ok)
+		assert.Equal(t, "1.2.0", loc.Version)
+
+		loc, ok = rq.locations.Get("print")
+		assert.True(t, ok)
+		assert.Equal(t, "latest", loc.Version)
+	}
+	{
+		_, _, err := New(strings.NewReader(testingdata), WithPinnedVersions(map[string]string{
+			"shell:echo": "2.0.0",
+		}))
+		assert.ErrorContains(t, err, ErrLockOutdated.Error())
+	}
+}
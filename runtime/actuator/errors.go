@@ -14,6 +14,9 @@ var (
 	ErrNameConflict               error = errors.New("name conflict")
 	ErrConditionIsFalse           error = errors.New("condition is false")
 	ErrNodeReused                 error = errors.New("node reused")
+	ErrLockOutdated               error = errors.New("lock outdated")
+	ErrReleaseFailed              error = errors.New("release failed")
+	ErrReturnValueNotDeclared     error = errors.New("return value not declared")
 )
 
 func wrapErrorf(err error, format string, args ...interface{}) error {
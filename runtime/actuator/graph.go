@@ -0,0 +1,413 @@
+package actuator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cofunclabs/cofunc/parser"
+)
+
+// Graph is the graph of a run queue, it contains the steps, the parallel groups, the 'for' loops, the 'switch'
+// branches with their conditions, the event triggers and the data dependencies between the return values of
+// functions and their consumers. It's rendered as Graphviz DOT or Mermaid.
+type Graph struct {
+	root  graphCluster
+	edges []graphEdge
+	// ids stores the ids of task nodes, a node is added to the graph only once
+	ids map[*TaskNode]string
+}
+
+type graphNode struct {
+	id    string
+	label string
+	shape string
+}
+
+// The shapes of the nodes in the graph
+const (
+	shapeTask     = "box"
+	shapeDecision = "diamond"
+	shapeTerminal = "circle"
+)
+
+type graphCluster struct {
+	id       string
+	label    string
+	nodes    []graphNode
+	clusters []*graphCluster
+}
+
+type graphEdge struct {
+	from  string
+	to    string
+	label string
+	// data is true if the edge is a data dependency, e.g. '$(out.now)' references the return value of 'co time -> out'
+	data bool
+}
+
+// port is the end of a node that connects to the next node, the label is the label of the edge.
+type port struct {
+	id    string
+	label string
+}
+
+const (
+	graphStart = "flow_start"
+	graphEnd   = "flow_end"
+)
+
+// Graph builds the graph of the run queue, the drivers don't need to be loaded.
+func (r *RunQueue) Graph() *Graph {
+	g := &Graph{
+		ids: make(map[*TaskNode]string),
+	}
+	g.root.nodes = append(g.root.nodes, graphNode{id: graphStart, label: "start", shape: shapeTerminal})
+
+	exits := g.walk(r.steps, 0, len(r.steps), &g.root, []port{{id: graphStart}})
+	g.root.nodes = append(g.root.nodes, graphNode{id: graphEnd, label: "end", shape: shapeTerminal})
+	g.connect(exits, graphEnd)
+
+	if len(r.triggers) != 0 {
+		c := &graphCluster{id: "event", label: "event"}
+		for _, tg := range r.triggers {
+			if task, ok := tg.(*TaskNode); ok {
+				id := g.addTask(c, task)
+				g.edges = append(g.edges, graphEdge{from: id, to: graphStart, label: "trigger"})
+			}
+		}
+		g.root.clusters = append(g.root.clusters, c)
+	}
+
+	g.dataEdges(r)
+	return g
+}
+
+// walk adds the steps in [from, to) into the cluster, the 'prev' are connected to the first step. It returns the
+// ports of the last step.
+func (g *Graph) walk(steps []Node, from, to int, c *graphCluster, prev []port) []port {
+	for i := from; i < to; {
+		switch n := steps[i].(type) {
+		case *ForNode:
+			prev = g.addFor(steps, n, c, prev)
+			i = n.btfIdx + 1
+		case *TaskNode:
+			if n.co.InSwitch() {
+				j := i
+				sw := n.co.Parent().Parent()
+				for j < to {
+					t, ok := steps[j].(*TaskNode)
+					if !ok || !t.co.InSwitch() || t.co.Parent().Parent() != sw {
+						break
+					}
+					j++
+				}
+				prev = g.addSwitch(steps[i:j], sw, c, prev)
+				i = j
+				continue
+			}
+			prev = g.addParallel(n, c, prev)
+			i++
+		default:
+			i++
+		}
+	}
+	return prev
+}
+
+func (g *Graph) addFor(steps []Node, n *ForNode, c *graphCluster, prev []port) []port {
+	id := fmt.Sprintf("for_%d", n.idx)
+	cond := condition(n.b)
+	label := "for"
+	if cond != "" {
+		label += " " + cond
+	}
+	loop := &graphCluster{id: id + "_loop", label: "for"}
+	loop.nodes = append(loop.nodes, graphNode{id: id, label: label, shape: shapeDecision})
+	c.clusters = append(c.clusters, loop)
+	g.connect(prev, id)
+
+	entry := port{id: id}
+	if cond != "" {
+		entry.label = "true"
+	}
+	exits := g.walk(steps, n.idx+1, n.btfIdx, loop, []port{entry})
+	for _, p := range exits {
+		g.edges = append(g.edges, graphEdge{from: p.id, to: id, label: joinLabel(p.label, "next")})
+	}
+	if cond == "" {
+		// the infinite loop never exits
+		return nil
+	}
+	return []port{{id: id, label: "false"}}
+}
+
+func (g *Graph) addSwitch(steps []Node, sw *parser.Block, c *graphCluster, prev []port) []port {
+	id := fmt.Sprintf("switch_%d", sw.Line())
+	branch := &graphCluster{id: id + "_branch", label: "switch"}
+	branch.nodes = append(branch.nodes, graphNode{id: id, label: "switch", shape: shapeDecision})
+	c.clusters = append(c.clusters, branch)
+	g.connect(prev, id)
+
+	var (
+		exits      []port
+		hasDefault bool
+		cases      []*parser.Block
+		tasks      = make(map[*parser.Block][]*TaskNode)
+	)
+	for _, s := range steps {
+		t := s.(*TaskNode)
+		cb := t.co.Parent()
+		if _, ok := tasks[cb]; !ok {
+			cases = append(cases, cb)
+		}
+		tasks[cb] = append(tasks[cb], t)
+	}
+	for _, cb := range cases {
+		label := "default"
+		if cb.IsDefault() {
+			hasDefault = true
+		} else {
+			label = condition(cb)
+		}
+		ports := []port{{id: id, label: label}}
+		for _, t := range tasks[cb] {
+			ports = g.addParallel(t, branch, ports)
+		}
+		exits = append(exits, ports...)
+	}
+	if !hasDefault {
+		// every case may be false
+		exits = append(exits, port{id: id, label: "no case"})
+	}
+	return exits
+}
+
+// addParallel adds the node and the nodes running in parallel with it, they are in a cluster if there are more
+// than one.
+func (g *Graph) addParallel(n *TaskNode, c *graphCluster, prev []port) []port {
+	var nodes []*TaskNode
+	for p := n; p != nil; p = p.parallel {
+		nodes = append(nodes, p)
+	}
+	if len(nodes) > 1 {
+		group := &graphCluster{id: fmt.Sprintf("parallel_%d", n.seq), label: "parallel"}
+		c.clusters = append(c.clusters, group)
+		c = group
+	}
+	var exits []port
+	for _, p := range nodes {
+		id := g.addTask(c, p)
+		g.connect(prev, id)
+		exits = append(exits, port{id: id})
+	}
+	return exits
+}
+
+func (g *Graph) addTask(c *graphCluster, n *TaskNode) string {
+	if id, ok := g.ids[n]; ok {
+		return id
+	}
+	id := fmt.Sprintf("task_%d", n.seq)
+	g.ids[n] = id
+	label := n.name
+	if fname := n.driver.Name() + ":" + n.driver.FunctionName(); fname != n.name {
+		label += "\n" + fname
+	}
+	if n.needReturns() {
+		label += "\n-> " + n.returnVar
+	}
+	c.nodes = append(c.nodes, graphNode{id: id, label: label, shape: shapeTask})
+	return id
+}
+
+func (g *Graph) connect(prev []port, to string) {
+	for _, p := range prev {
+		g.edges = append(g.edges, graphEdge{from: p.id, to: to, label: p.label})
+	}
+}
+
+// dataEdges adds the edges from the nodes that save the return values to the nodes and the conditions that
+// reference them, e.g. '$(out.now)'.
+func (g *Graph) dataEdges(r *RunQueue) {
+	type binding struct {
+		scope *parser.Block
+		name  string
+	}
+	producers := make(map[binding][]string)
+	var tasks []*TaskNode
+	for task, id := range g.ids {
+		tasks = append(tasks, task)
+		if task.needReturns() {
+			key := binding{task.co.VarScope(task.returnVar), task.returnVar}
+			producers[key] = append(producers[key], id)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].seq < tasks[j].seq
+	})
+
+	// the references between the same nodes are merged into one edge, the labels are joined
+	type pair struct {
+		from, to string
+	}
+	var (
+		pairs  []pair
+		labels = make(map[pair][]string)
+		seen   = make(map[pair]map[string]bool)
+	)
+	consume := func(b *parser.Block, to string, skip map[parser.VarRef]bool) {
+		if b == nil {
+			return
+		}
+		for _, ref := range b.FieldRefs() {
+			if skip[ref] {
+				continue
+			}
+			ids := producers[binding{ref.Scope, ref.Var}]
+			sort.Strings(ids)
+			label := "$(" + ref.Var + "." + ref.Field + ")"
+			for _, from := range ids {
+				p := pair{from, to}
+				if seen[p] == nil {
+					seen[p] = make(map[string]bool)
+					pairs = append(pairs, p)
+				}
+				if !seen[p][label] {
+					seen[p][label] = true
+					labels[p] = append(labels[p], label)
+				}
+			}
+		}
+	}
+	for _, task := range tasks {
+		// the 'co' in the case has the condition of the case, the references in the condition are consumed by
+		// the switch
+		cond := make(map[parser.VarRef]bool)
+		if task.co.InSwitch() {
+			cb := task.co.Parent()
+			for _, ref := range cb.FieldRefs() {
+				cond[ref] = true
+			}
+			consume(cb, fmt.Sprintf("switch_%d", cb.Parent().Line()), nil)
+		}
+		consume(task.argsBlock(), g.ids[task], cond)
+	}
+	for _, step := range r.steps {
+		if n, ok := step.(*ForNode); ok {
+			consume(n.b, fmt.Sprintf("for_%d", n.idx), nil)
+		}
+	}
+	for _, p := range pairs {
+		g.edges = append(g.edges, graphEdge{from: p.from, to: p.to, label: joinLabel(labels[p]...), data: true})
+	}
+}
+
+// condition returns the condition expression of the 'for' or 'case' block, it's empty if there is no condition.
+func condition(b *parser.Block) string {
+	if b.Target2().IsEmpty() {
+		return ""
+	}
+	return b.Target2().String()
+}
+
+func joinLabel(labels ...string) string {
+	var ls []string
+	for _, l := range labels {
+		if l != "" {
+			ls = append(ls, l)
+		}
+	}
+	return strings.Join(ls, ", ")
+}
+
+// DOT renders the graph in the Graphviz DOT language
+func (g *Graph) DOT(name string) string {
+	var builder strings.Builder
+	builder.WriteString("digraph " + dotQuote(name) + " {\n")
+	builder.WriteString("\tnode [shape=box];\n")
+	g.dotCluster(&builder, &g.root, "\t")
+	for _, e := range g.edges {
+		var attrs []string
+		if e.label != "" {
+			attrs = append(attrs, "label="+dotQuote(e.label))
+		}
+		if e.data {
+			attrs = append(attrs, "style=dashed")
+		}
+		builder.WriteString("\t" + e.from + " -> " + e.to)
+		if len(attrs) != 0 {
+			builder.WriteString(" [" + strings.Join(attrs, ", ") + "]")
+		}
+		builder.WriteString(";\n")
+	}
+	builder.WriteString("}\n")
+	return builder.String()
+}
+
+func (g *Graph) dotCluster(builder *strings.Builder, c *graphCluster, indent string) {
+	for _, n := range c.nodes {
+		builder.WriteString(indent + n.id + " [label=" + dotQuote(n.label))
+		if n.shape != shapeTask {
+			builder.WriteString(", shape=" + n.shape)
+		}
+		builder.WriteString("];\n")
+	}
+	for _, sub := range c.clusters {
+		builder.WriteString(indent + "subgraph cluster_" + sub.id + " {\n")
+		builder.WriteString(indent + "\tlabel=" + dotQuote(sub.label) + ";\n")
+		g.dotCluster(builder, sub, indent+"\t")
+		builder.WriteString(indent + "}\n")
+	}
+}
+
+var dotReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotQuote(s string) string {
+	return `"` + dotReplacer.Replace(s) + `"`
+}
+
+// Mermaid renders the graph as a Mermaid flowchart
+func (g *Graph) Mermaid(name string) string {
+	var builder strings.Builder
+	builder.WriteString("---\ntitle: " + name + "\n---\n")
+	builder.WriteString("flowchart TD\n")
+	g.mermaidCluster(&builder, &g.root, "\t")
+	for _, e := range g.edges {
+		arrow := "-->"
+		if e.data {
+			arrow = "-.->"
+		}
+		builder.WriteString("\t" + e.from + " " + arrow)
+		if e.label != "" {
+			builder.WriteString("|" + mermaidQuote(e.label) + "|")
+		}
+		builder.WriteString(" " + e.to + "\n")
+	}
+	return builder.String()
+}
+
+func (g *Graph) mermaidCluster(builder *strings.Builder, c *graphCluster, indent string) {
+	for _, n := range c.nodes {
+		label := mermaidQuote(n.label)
+		switch n.shape {
+		case shapeDecision:
+			builder.WriteString(indent + n.id + "{" + label + "}\n")
+		case shapeTerminal:
+			builder.WriteString(indent + n.id + "((" + label + "))\n")
+		default:
+			builder.WriteString(indent + n.id + "[" + label + "]\n")
+		}
+	}
+	for _, sub := range c.clusters {
+		builder.WriteString(indent + "subgraph " + sub.id + " [" + mermaidQuote(sub.label) + "]\n")
+		g.mermaidCluster(builder, sub, indent+"\t")
+		builder.WriteString(indent + "end\n")
+	}
+}
+
+var mermaidReplacer = strings.NewReplacer(`"`, "#quot;", "\n", "<br/>")
+
+func mermaidQuote(s string) string {
+	return `"` + mermaidReplacer.Replace(s) + `"`
+}
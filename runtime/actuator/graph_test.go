@@ -0,0 +1,87 @@
+package actuator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraph(t *testing.T) {
+	const testingdata string = `
+load "go:print"
+load "go:sleep"
+load "go:time"
+load "go:event_tick"
+
+var t
+var counter = 0
+
+event {
+	co event_tick
+}
+
+co time -> t
+co {
+	print
+	sleep
+}
+for $(counter) < 3 {
+	counter <- $(counter) + 1
+	switch {
+		case $(t.year) > 2000 {
+			co print {
+				"now": "$(t.now)"
+			}
+		}
+		default {
+			co sleep
+		}
+	}
+}
+`
+	rq, _, err := New(strings.NewReader(testingdata))
+	if !assert.NoError(t, err) {
+		return
+	}
+	g := rq.Graph()
+
+	dot := g.DOT("demo")
+	for _, s := range []string{
+		`digraph "demo" {`,
+		`flow_start [label="start", shape=circle];`,
+		`task_1000 [label="time\ngo:time\n-> t"];`,
+		"subgraph cluster_parallel_1001 {",
+		`for_2 [label="for $(counter)<3", shape=diamond];`,
+		"flow_start -> task_1000;",
+		"task_1000 -> task_1001;",
+		"task_1000 -> task_1002;",
+		"task_1001 -> for_2;",
+		"task_1002 -> for_2;",
+		`for_2 -> switch_21 [label="true"];`,
+		`switch_21 -> task_1003 [label="$(t.year)>2000"];`,
+		`switch_21 -> task_1004 [label="default"];`,
+		`task_1003 -> for_2 [label="next"];`,
+		`for_2 -> flow_end [label="false"];`,
+		`task_10000 -> flow_start [label="trigger"];`,
+		`task_1000 -> task_1003 [label="$(t.now)", style=dashed];`,
+		`task_1000 -> switch_21 [label="$(t.year)", style=dashed];`,
+	} {
+		assert.Contains(t, dot, s)
+	}
+	// the reference in the condition isn't consumed by the function in the case
+	assert.NotContains(t, dot, `task_1000 -> task_1003 [label="$(t.year)", style=dashed];`)
+
+	mermaid := g.Mermaid("demo")
+	for _, s := range []string{
+		"flowchart TD",
+		`flow_start(("start"))`,
+		`task_1000["time<br/>go:time<br/>-> t"]`,
+		`subgraph parallel_1001 ["parallel"]`,
+		`switch_21{"switch"}`,
+		`switch_21 -->|"$(t.year)>2000"| task_1003`,
+		`task_1000 -.->|"$(t.now)"| task_1003`,
+	} {
+		assert.Contains(t, mermaid, s)
+	}
+}
@@ -3,8 +3,10 @@ package runtime
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -26,6 +28,8 @@ const (
 	StatusCanceled = StatusType("CANCELED")
 )
 
+var ErrFlowReleased = errors.New("flow released")
+
 type FlowOption func(*FlowBody)
 
 // Flow
@@ -40,6 +44,7 @@ func newflow(id nameid.ID, runq *actuator.RunQueue, ast *parser.AST) *Flow {
 		FlowBody: FlowBody{
 			id:         id,
 			statistics: make(map[int]*functionStatistics),
+			subflows:   make(map[int]*subflow),
 			status:     StatusAdded,
 			runq:       runq,
 			ast:        ast,
@@ -87,6 +92,48 @@ func WithCopyResources(copy func() resource.Resources) FlowOption {
 	}
 }
 
+// WithParams binds the values of params declared by the 'param' statements, they are bound to the flow only,
+// the environment variables of the process aren't changed.
+func WithParams(params map[string]string) FlowOption {
+	return func(fb *FlowBody) {
+		fb.params = params
+	}
+}
+
+// WithPath initializes the path of the flowl source file, the nested flows that are the ancestors of themselves
+// are detected by the paths.
+func WithPath(path string) FlowOption {
+	return func(fb *FlowBody) {
+		fb.path = normalizePath(path)
+	}
+}
+
+// normalizePath returns the absolute path without the symbolic links, so the same file has the same path.
+func normalizePath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	return filepath.Clean(path)
+}
+
+// WithCheckOnly makes the flow be initialized only to check it, e.g. the arguments of functions are checked by
+// their manifests, the flow isn't executed, so the required environment variables and the secrets aren't resolved.
+func WithCheckOnly() FlowOption {
+	return func(fb *FlowBody) {
+		fb.checkOnly = true
+	}
+}
+
+// withAncestors initializes the ancestors of the nested flow.
+func withAncestors(ancestors []string) FlowOption {
+	return func(fb *FlowBody) {
+		fb.ancestors = ancestors
+	}
+}
+
 // WithLock read/write the fields of the flow with the lock.
 func (f *Flow) WithLock(exec func(body *FlowBody) error) error {
 	f.Lock()
@@ -205,6 +252,33 @@ func (f *Flow) ToStopped() {
 	})
 }
 
+// activate marks the flow has a running execution or event trigger, it returns an error if the flow is
+// released. The caller must call 'f.active.Done()' when the execution or event trigger is finished.
+func (f *Flow) activate() error {
+	return f.WithLock(func(body *FlowBody) error {
+		if body.released {
+			return fmt.Errorf("%w: flow %s", ErrFlowReleased, body.id.ID())
+		}
+		body.active.Add(1)
+		return nil
+	})
+}
+
+// wait waits for all running executions and event triggers of the flow to finish.
+func (f *Flow) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		f.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (f *Flow) RunQ() *actuator.RunQueue {
 	f.Lock()
 	defer f.Unlock()
@@ -254,11 +328,54 @@ type FlowBody struct {
 	copyResources func() resource.Resources
 	// cancel is used to cancel the flow through the context.
 	cancel context.CancelFunc
+	// path is the path of the flowl source file, it's empty if the flow isn't parsed from a file.
+	path string
+	// ancestors stores the paths of all ancestors of a nested flow, it's used to detect the recursion cycle.
+	ancestors []string
+	// subflows stores the nested flows that added by the function nodes, the key is the seq of the function node.
+	subflows map[int]*subflow
+	// params are the values of params of the flow
+	params map[string]string
+	// plan isn't nil if the functions are replaced by the 'stub', e.g. in the dry-run mode, it records the
+	// walked nodes.
+	plan *Plan
+	stub actuator.Stub
+	// initOpts are saved to initialize the flow again when it's reloaded.
+	initOpts []FlowOption
+	// warnings are the problems found by checking the flow when it's initialized, they don't fail the flow.
+	warnings []string
+	// checkOnly is true if the flow is initialized only to be checked, see WithCheckOnly.
+	checkOnly bool
+	// active counts the running executions and event triggers of the flow, the flow can be released only
+	// when all of them are finished.
+	active sync.WaitGroup
+	// released is true when the flow is being released, it can't be executed anymore.
+	released bool
 
 	runq *actuator.RunQueue
 	ast  *parser.AST
 }
 
+// GetVarValue returns the value of the global variable of the flow, e.g. 'bins' or 'bins.outcome'.
+func (b *FlowBody) GetVarValue(name string) string {
+	return b.ast.Global().GetVarValue(name)
+}
+
+// Warnings returns the problems found by checking the flow when it's initialized.
+func (b *FlowBody) Warnings() []string {
+	return b.warnings
+}
+
+// NodeNames returns the names of all function nodes of the flow, the nodes of the nested flows aren't included.
+func (b *FlowBody) NodeNames() []string {
+	var names []string
+	b.runq.WalkNode(func(n actuator.Node) error {
+		names = append(names, n.Name())
+		return nil
+	})
+	return names
+}
+
 // SetCancel set the context cancel function to the flow.
 func (b *FlowBody) SetCancel(cancel context.CancelFunc) {
 	b.cancel = cancel
@@ -276,10 +393,16 @@ func (b *FlowBody) Export() exported.FlowRunningInsight {
 		Running:  len(b.progress.running),
 		Done:     len(b.progress.done),
 	}
+	insight.Nodes = b.exportNodes()
+	return insight
+}
+
+func (b *FlowBody) exportNodes() []exported.NodeRunningInsight {
+	var nodes []exported.NodeRunningInsight
 	for _, seq := range b.progress.nodes {
 		fm := b.statistics[seq]
 		fm.WithLock(func(mb *functionStatisticsBody) {
-			insight.Nodes = append(insight.Nodes, exported.NodeRunningInsight{
+			nodes = append(nodes, exported.NodeRunningInsight{
 				Seq:       seq,
 				Step:      mb.node.(actuator.Task).Step(),
 				Function:  mb.node.(actuator.Task).Driver().FunctionName(),
@@ -291,8 +414,15 @@ func (b *FlowBody) Export() exported.FlowRunningInsight {
 				Duration:  mb.duration,
 			})
 		})
+		// The nodes of the nested flow are shown as the children of the function node
+		if sub, ok := b.subflows[seq]; ok {
+			sub.flow.WithLock(func(body *FlowBody) error {
+				nodes[len(nodes)-1].Nodes = body.exportNodes()
+				return nil
+			})
+		}
 	}
-	return insight
+	return nodes
 }
 
 type functionStatisticsBody struct {
@@ -311,6 +441,8 @@ type functionStatisticsBody struct {
 
 	status StatusType
 	node   actuator.Node
+	// logwriter is the log writer of the function node
+	logwriter io.Writer
 }
 
 type functionStatistics struct {
@@ -330,6 +462,18 @@ func (fs *functionStatistics) IsStatus(status StatusType) bool {
 	return fs.status == status
 }
 
+// FlushLog writes the data held by the log writer, e.g. the tail that may be the beginning of a secret is held
+// for redacting.
+func (fs *functionStatistics) FlushLog() error {
+	fs.Lock()
+	w := fs.logwriter
+	fs.Unlock()
+	if f, ok := w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
 func (fs *functionStatistics) ToRuning() {
 	fs.WithLock(func(body *functionStatisticsBody) {
 		body.begin = time.Now()
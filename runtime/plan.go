@@ -0,0 +1,134 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cofunclabs/cofunc/runtime/actuator"
+)
+
+// ErrPlanTooLong is returned if the stubbed flow walks too many function nodes, e.g. an infinite 'for' loop
+var ErrPlanTooLong = errors.New("plan too long")
+
+// MaxPlanSteps is the max number of the function nodes that the stubbed flow walks
+const MaxPlanSteps = 1000
+
+// PlanStep records a function node walked by the stubbed flow
+type PlanStep struct {
+	// Step and Seq are the step and the sequence number of the node in the run queue
+	Step int
+	Seq  int
+	// Name is the name of the node, Node is its description that's the same as the one printed in the log
+	Name string
+	Node string
+	// Args are the resolved arguments that the function would run with
+	Args map[string]string
+	// Returns and Err are returned by the stub
+	Returns map[string]string
+	Err     error
+	// Skipped is true when the node isn't run, because the condition of its 'case' is false
+	Skipped bool
+}
+
+// Plan records the function nodes in the order they are walked by the stubbed flow, the nodes at the same step
+// are ordered by their sequence numbers. It's safe for concurrent use.
+type Plan struct {
+	sync.Mutex
+	steps []PlanStep
+}
+
+// Steps returns a copy of the steps recorded by the plan.
+func (p *Plan) Steps() []PlanStep {
+	p.Lock()
+	defer p.Unlock()
+	steps := make([]PlanStep, len(p.steps))
+	copy(steps, p.steps)
+	return steps
+}
+
+func (p *Plan) record(step PlanStep) error {
+	p.Lock()
+	defer p.Unlock()
+	if len(p.steps) >= MaxPlanSteps {
+		return fmt.Errorf("%w: more than %d steps", ErrPlanTooLong, MaxPlanSteps)
+	}
+	p.steps = append(p.steps, step)
+	return nil
+}
+
+// sortFrom sorts the steps recorded since 'from' by their sequence numbers, the nodes at a step run in parallel,
+// so they are recorded in a random order.
+func (p *Plan) sortFrom(from int) {
+	p.Lock()
+	defer p.Unlock()
+	if from > len(p.steps) {
+		return
+	}
+	batch := p.steps[from:]
+	sort.SliceStable(batch, func(i, j int) bool {
+		return batch[i].Seq < batch[j].Seq
+	})
+}
+
+func (p *Plan) len() int {
+	p.Lock()
+	defer p.Unlock()
+	return len(p.steps)
+}
+
+func (p *Plan) skip(n *actuator.TaskNode) error {
+	return p.record(PlanStep{
+		Step:    n.Step(),
+		Seq:     n.Seq(),
+		Name:    n.Name(),
+		Node:    n.FormatString(),
+		Skipped: true,
+	})
+}
+
+// wrap returns a stub that records the node, its arguments and the results of the 'stub'.
+func (p *Plan) wrap(stub actuator.Stub) actuator.Stub {
+	return func(ctx context.Context, n *actuator.TaskNode, args map[string]string) (map[string]string, error) {
+		rets, err := stub(ctx, n, args)
+		if err := p.record(PlanStep{
+			Step:    n.Step(),
+			Seq:     n.Seq(),
+			Name:    n.Name(),
+			Node:    n.FormatString(),
+			Args:    args,
+			Returns: rets,
+			Err:     err,
+		}); err != nil {
+			return nil, err
+		}
+		return rets, err
+	}
+}
+
+// CannedReturns returns a stub that returns the canned return values rather than running the functions, the key
+// of 'returns' is the name of the return variable, e.g. 't' of 'co time -> t'.
+func CannedReturns(returns map[string]map[string]string) actuator.Stub {
+	return func(ctx context.Context, n *actuator.TaskNode, args map[string]string) (map[string]string, error) {
+		return returns[n.ReturnVar()], nil
+	}
+}
+
+// WithStub makes the functions of the flow to be run by the 'stub', the walked nodes and their arguments and
+// results are recorded in the 'plan'. The stub can run the driver of the node for the functions that aren't
+// replaced.
+func WithStub(plan *Plan, stub actuator.Stub) FlowOption {
+	return func(fb *FlowBody) {
+		fb.plan = plan
+		fb.stub = plan.wrap(stub)
+	}
+}
+
+// WithDryRun makes the flow run in the dry-run mode, the conditions and the variables are evaluated as usual, but
+// the functions aren't run, the walked nodes and their resolved arguments are recorded in the 'plan'.
+// The 'returns' are the canned return values of the functions, the key is the name of the return variable.
+func WithDryRun(plan *Plan, returns map[string]map[string]string) FlowOption {
+	return WithStub(plan, CannedReturns(returns))
+}
@@ -6,14 +6,20 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/cofunclabs/cofunc/pkg/nameid"
 	"github.com/cofunclabs/cofunc/runtime/actuator"
+	"github.com/cofunclabs/cofunc/secret"
 )
 
+// ErrEnvMissing is returned by InitFlow if the environment variables declared by 'require env' are unset or empty
+var ErrEnvMissing = errors.New("required environment variables missing")
+
 // Event is from the event trigger, it will be used to make the flow run
 type Event struct {
 	id      nameid.ID
@@ -21,6 +27,12 @@ type Event struct {
 	execute func(id nameid.ID) error
 }
 
+// Runtime manages the lifecycle of flows, the lifecycle of a flow is:
+//
+//	load(ParseFlow) -> init(InitFlow) -> run(ExecFlow)* -> release(DeleteFlow)
+//
+// The function drivers are loaded at the init stage, and they must be released at the release stage, which
+// is invoked when the flow is deleted, reloaded or the runtime is shut down.
 type Runtime struct {
 	store  *flowstore
 	events chan Event
@@ -42,9 +54,10 @@ func New() *Runtime {
 
 // ParseFlow parse one flowl source file, and add a flow into runtime, the argument 'rd' is a reader for
 // a flow source file.
+// The 'opts' are used to create the run queue of the flow.
 // After invoking this method, the flow's status is ADDED.
-func (rt *Runtime) ParseFlow(ctx context.Context, id nameid.ID, rd io.Reader) error {
-	rq, ast, err := actuator.New(rd)
+func (rt *Runtime) ParseFlow(ctx context.Context, id nameid.ID, rd io.Reader, opts ...actuator.Option) error {
+	rq, ast, err := actuator.New(rd, opts...)
 	if err != nil {
 		return err
 	}
@@ -70,6 +83,19 @@ func (rt *Runtime) InitFlow(ctx context.Context, id nameid.ID, opts ...FlowOptio
 		for _, opt := range opts {
 			opt(fb)
 		}
+		fb.initOpts = opts
+
+		if !fb.checkOnly {
+			if err := resolveEnvAndSecrets(fb); err != nil {
+				return err
+			}
+		}
+
+		if err := fb.ast.BindParams(fb.params); err != nil {
+			return err
+		}
+		// The initialized values are restored before every run of the nested flow
+		fb.ast.Global().SaveValues()
 
 		// Initialize all task nodes
 		err := fb.runq.WalkNode(func(node actuator.Node) error {
@@ -89,9 +115,25 @@ func (rt *Runtime) InitFlow(ctx context.Context, id nameid.ID, opts ...FlowOptio
 			if err != nil {
 				return err
 			}
+			fb.statistics[seq].logwriter = logwriter
 			resources := fb.copyResources()
 			resources.Logwriter = logwriter
-			return node.Init(ctx, actuator.WithResources(resources))
+			runner := &subflowRunner{
+				rt:     rt,
+				parent: fb,
+				seq:    seq,
+			}
+			resources.FlowRunner = runner
+			with := []func(context.Context, actuator.Node) error{actuator.WithResources(resources)}
+			if fb.stub != nil {
+				with = append(with, actuator.WithStub(fb.stub))
+			}
+			err = node.Init(ctx, with...)
+			// The nested flow is saved even if the node fails to init, so it can be released
+			if runner.child != nil {
+				fb.subflows[seq] = runner.child
+			}
+			return err
 		})
 		if err != nil {
 			return err
@@ -111,11 +153,34 @@ func (rt *Runtime) InitFlow(ctx context.Context, id nameid.ID, opts ...FlowOptio
 			}
 		}
 
+		// The return values of functions can be checked after the drivers are loaded
+		warnings, err := fb.runq.CheckFieldRefs(fb.ast.FieldRefs())
+		fb.warnings = warnings
+		if err != nil {
+			return err
+		}
+
 		fb.status = StatusReady
 		return nil
 	}
 
-	if err := flow.WithLock(ready); err != nil {
+	var subflows []*subflow
+	err = flow.WithLock(func(fb *FlowBody) error {
+		err := ready(fb)
+		if err != nil {
+			for seq, sub := range fb.subflows {
+				subflows = append(subflows, sub)
+				delete(fb.subflows, seq)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		// Release the drivers that have been loaded and the nested flows that have been added
+		flow.RunQ().Release(ctx)
+		for _, sub := range subflows {
+			rt.DeleteFlow(ctx, sub.id)
+		}
 		return err
 	}
 	if err := flow.Refresh(); err != nil {
@@ -124,6 +189,38 @@ func (rt *Runtime) InitFlow(ctx context.Context, id nameid.ID, opts ...FlowOptio
 	return nil
 }
 
+// resolveEnvAndSecrets checks the required environment variables before loading any function driver, and binds
+// the values of the secrets to the flow, all missing variables or secrets are reported at once.
+func resolveEnvAndSecrets(fb *FlowBody) error {
+	var missing []string
+	for _, name := range fb.ast.RequiredEnv() {
+		if os.Getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("%w: %s", ErrEnvMissing, strings.Join(missing, ", "))
+	}
+	// Resolving the secrets also makes their values redacted from the logs
+	secrets := make(map[string]string)
+	for _, name := range fb.ast.SecretRefs() {
+		v, err := secret.Resolve(name)
+		if err != nil {
+			if !errors.Is(err, secret.ErrSecretNotFound) {
+				return err
+			}
+			missing = append(missing, name)
+			continue
+		}
+		secrets[name] = v
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("%w: %s", secret.ErrSecretNotFound, strings.Join(missing, ", "))
+	}
+	fb.ast.BindSecrets(secrets)
+	return nil
+}
+
 // Stopped2Ready will reset the status of the flow and all nodes to ready, but only when all nodes are stopped
 // When re-executing the flow, You need to call this method
 func (rt *Runtime) Stopped2Ready(ctx context.Context, id nameid.ID) error {
@@ -163,7 +260,92 @@ func (rt *Runtime) CancelFlow(ctx context.Context, id nameid.ID) error {
 	})
 }
 
+// DeleteFlow cancels the flow and waits for its running executions and event triggers to finish, then releases
+// the function drivers and the nested flows, finally removes the flow from runtime.
 func (rt *Runtime) DeleteFlow(ctx context.Context, id nameid.ID) error {
+	flow, err := rt.store.get(id.ID())
+	if err != nil {
+		return err
+	}
+	var subflows []*subflow
+	flow.WithLock(func(fb *FlowBody) error {
+		fb.released = true
+		if fb.cancel != nil {
+			fb.cancel()
+		}
+		fb.cancel = nil
+		for _, sub := range fb.subflows {
+			subflows = append(subflows, sub)
+		}
+		return nil
+	})
+	if err := flow.wait(ctx); err != nil {
+		return err
+	}
+
+	var errs []string
+	if err := flow.RunQ().Release(ctx); err != nil {
+		errs = append(errs, err.Error())
+	}
+	for _, sub := range subflows {
+		if err := rt.DeleteFlow(ctx, sub.id); err != nil && !errors.Is(err, ErrFlowNotFound) {
+			errs = append(errs, err.Error())
+		}
+	}
+	rt.store.delete(id.ID())
+	if len(errs) != 0 {
+		return fmt.Errorf("delete flow %s: %s", id.ID(), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ReloadFlow releases the flow, then parses the new flowl source file with the 'opts', they aren't inherited
+// from the last parsing, because the options such as the pinned versions may be changed with the source file.
+// If the flow has been initialized, it will be initialized again with the same options, so the status of the
+// reloaded flow is ADDED or READY.
+func (rt *Runtime) ReloadFlow(ctx context.Context, id nameid.ID, rd io.Reader, opts ...actuator.Option) error {
+	flow, err := rt.store.get(id.ID())
+	if err != nil {
+		return err
+	}
+	var (
+		initOpts    []FlowOption
+		initialized bool
+	)
+	flow.WithLock(func(fb *FlowBody) error {
+		initOpts = fb.initOpts
+		initialized = fb.status != StatusAdded
+		return nil
+	})
+
+	if err := rt.DeleteFlow(ctx, id); err != nil {
+		return err
+	}
+	if err := rt.ParseFlow(ctx, id, rd, opts...); err != nil {
+		return err
+	}
+	if initialized {
+		return rt.InitFlow(ctx, id, initOpts...)
+	}
+	return nil
+}
+
+// Shutdown deletes all flows in runtime, so that all function drivers are released.
+func (rt *Runtime) Shutdown(ctx context.Context) error {
+	var errs []string
+	for _, k := range rt.store.keys() {
+		flow, err := rt.store.get(k)
+		if err != nil {
+			// It has been deleted as a nested flow
+			continue
+		}
+		if err := rt.DeleteFlow(ctx, flow.id); err != nil && !errors.Is(err, ErrFlowNotFound) {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("shutdown: %s", strings.Join(errs, "; "))
+	}
 	return nil
 }
 
@@ -189,6 +371,10 @@ func (rt *Runtime) StartEventTrigger(ctx context.Context, id nameid.ID) error {
 	if n == 0 {
 		return nil
 	}
+	if err := flow.activate(); err != nil {
+		return err
+	}
+	defer flow.active.Done()
 	var wg sync.WaitGroup
 	wg.Add(n)
 	for _, tg := range triggers {
@@ -267,6 +453,10 @@ func (rt *Runtime) ExecFlow(ctx context.Context, id nameid.ID) (err0 error) {
 	if err != nil {
 		return err
 	}
+	if err := flow.activate(); err != nil {
+		return err
+	}
+	defer flow.active.Done()
 	if !flow.IsReady() {
 		return fmt.Errorf("not ready: flow %s", id.ID())
 	}
@@ -292,6 +482,9 @@ func (rt *Runtime) execStepFunc(ctx context.Context, f *Flow) func([]actuator.No
 	return func(batch []actuator.Node) error {
 		ch := make(chan *functionStatistics, len(batch))
 		nodes := len(batch)
+		if f.plan != nil {
+			defer f.plan.sortFrom(f.plan.len())
+		}
 
 		// parallel run functions at the step
 		for _, n := range batch {
@@ -309,12 +502,18 @@ func (rt *Runtime) execStepFunc(ctx context.Context, f *Flow) func([]actuator.No
 						break
 					}
 					if err == actuator.ErrConditionIsFalse {
+						if f.plan != nil {
+							if err := f.plan.skip(node.(*actuator.TaskNode)); err != nil {
+								fs.ToStopped(err)
+							}
+						}
 						break
 					}
 					if errors.Is(err, context.Canceled) {
 						break
 					}
 				}
+				fs.FlushLog()
 				// Send the result of the function execution to make it stopped really
 				ch <- fs
 			}(n)
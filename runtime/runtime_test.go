@@ -4,12 +4,20 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/cofunclabs/cofunc/manifest"
+	"github.com/cofunclabs/cofunc/parser"
+	"github.com/cofunclabs/cofunc/pkg/eval"
 	"github.com/cofunclabs/cofunc/pkg/nameid"
+	"github.com/cofunclabs/cofunc/runtime/actuator"
+	"github.com/cofunclabs/cofunc/secret"
+	"github.com/cofunclabs/cofunc/service/exported"
 	"github.com/cofunclabs/cofunc/service/resource"
 	"github.com/stretchr/testify/assert"
 )
@@ -236,3 +244,593 @@ func newTestingFlowCase(t *testing.T, rt *Runtime, testingdata string, id nameid
 		}
 	}
 }
+
+func installTestingFlow(t *testing.T, home, name, data string) {
+	path := filepath.Join(home, "flowls", name+".flowl")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+}
+
+func TestSubflow(t *testing.T) {
+	home := t.TempDir()
+	os.Setenv("COFUNC_HOME", home)
+	defer os.Unsetenv("COFUNC_HOME")
+
+	installTestingFlow(t, home, "common/greet", `
+load "go:print"
+
+var name = "world"
+var greeting = "hello $(name)"
+
+co print {
+    "_": "$(greeting)"
+}
+	`)
+
+	const testingdata string = `
+load "flow:common/greet"
+load "go:print"
+
+var out
+co greet -> out {
+    "name": "cofunc"
+    "_returns": "greeting"
+}
+co print {
+    "_": "$(out.greeting)!"
+}
+	`
+
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	e := expect{
+		nodes:  2,
+		output: "hello cofunc\nhello cofunc!",
+	}
+	newTestingFlowCase(t, rt, testingdata, id, e)
+
+	var fi exported.FlowRunningInsight
+	err := rt.FetchFlow(context.Background(), id, func(fb *FlowBody) error {
+		fi = fb.Export()
+		return nil
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, fi.Nodes, 2) {
+		assert.Equal(t, "flow", fi.Nodes[0].Driver)
+		if assert.Len(t, fi.Nodes[0].Nodes, 1) {
+			assert.Equal(t, "print", fi.Nodes[0].Nodes[0].Function)
+			assert.Equal(t, string(StatusStopped), fi.Nodes[0].Nodes[0].Status)
+		}
+		assert.Len(t, fi.Nodes[1].Nodes, 0)
+	}
+}
+
+func TestSubflowCycle(t *testing.T) {
+	home := t.TempDir()
+	os.Setenv("COFUNC_HOME", home)
+	defer os.Unsetenv("COFUNC_HOME")
+
+	installTestingFlow(t, home, "a", `
+load "flow:b"
+co b
+	`)
+	installTestingFlow(t, home, "b", `
+load "flow:a"
+co a
+	`)
+
+	ctx := context.Background()
+	rt := New()
+	id := nameid.New("a.flowl")
+	path := filepath.Join(home, "flowls", "a.flowl")
+	rd, err := os.Open(path)
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	defer rd.Close()
+	if err := rt.ParseFlow(ctx, id, rd); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	err = rt.InitFlow(ctx, id, WithPath(path))
+	assert.ErrorIs(t, err, ErrFlowHasCycle)
+	// The cycle a -> b -> a is detected by the path of the top flow
+	if assert.Error(t, err) {
+		assert.Equal(t, 1, strings.Count(err.Error(), "b.flowl"), err.Error())
+	}
+}
+
+func TestSubflowInitFailed(t *testing.T) {
+	home := t.TempDir()
+	os.Setenv("COFUNC_HOME", home)
+	defer os.Unsetenv("COFUNC_HOME")
+
+	installTestingFlow(t, home, "common/greet", `
+load "go:print"
+co print {
+    "_": "hello"
+}
+	`)
+
+	const testingdata string = `
+load "flow:common/greet"
+load "go:sleep"
+
+co greet
+co sleep {
+	"duration": "abc"
+}
+	`
+	ctx := context.Background()
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	err := rt.InitFlow(ctx, id)
+	assert.ErrorIs(t, err, manifest.ErrInvalidArgument)
+	// The nested flow added by the first node is deleted
+	assert.Equal(t, []string{id.ID()}, rt.store.keys())
+	rt.FetchFlow(ctx, id, func(fb *FlowBody) error {
+		assert.Len(t, fb.subflows, 0)
+		return nil
+	})
+}
+
+type testingCron struct {
+	sync.Mutex
+	entries map[int]chan<- time.Time
+	next    int
+}
+
+func (c *testingCron) Add(format string, ch chan<- time.Time) (interface{}, error) {
+	c.Lock()
+	defer c.Unlock()
+	c.next++
+	c.entries[c.next] = ch
+	return c.next, nil
+}
+
+func (c *testingCron) Remove(v interface{}) error {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.entries, v.(int))
+	return nil
+}
+
+func (c *testingCron) len() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.entries)
+}
+
+func TestDeleteFlow(t *testing.T) {
+	const testingdata string = `
+load "go:event_cron"
+load "go:print"
+
+event {
+	co event_cron {
+		"expr": "* * * * * *"
+	}
+}
+
+co print {
+	"_": "triggered"
+}
+	`
+
+	rt := New()
+	ctx := context.Background()
+	id := nameid.New("testingdata.flowl")
+	cron := &testingCron{entries: make(map[int]chan<- time.Time)}
+
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	err := rt.InitFlow(ctx, id, WithCopyResources(func() resource.Resources {
+		return resource.Resources{CronTrigger: cron}
+	}), WithCreateLogwriter(func(string, string) (io.Writer, error) {
+		return io.Discard, nil
+	}))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	triggerCtx, cancel := context.WithCancel(ctx)
+	rt.FetchFlow(ctx, id, func(fb *FlowBody) error {
+		fb.SetCancel(cancel)
+		return nil
+	})
+	done := make(chan error, 1)
+	go func() {
+		done <- rt.StartEventTrigger(triggerCtx, id)
+	}()
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, 1, cron.len())
+
+	// the trigger is canceled and the cron entry is removed
+	assert.NoError(t, rt.DeleteFlow(ctx, id))
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		assert.FailNow(t, "the event trigger isn't stopped")
+	}
+	assert.Equal(t, 0, cron.len())
+	assert.ErrorIs(t, rt.ExecFlow(ctx, id), ErrFlowNotFound)
+	assert.ErrorIs(t, rt.DeleteFlow(ctx, id), ErrFlowNotFound)
+}
+
+func TestReloadFlow(t *testing.T) {
+	rt := New()
+	ctx := context.Background()
+	id := nameid.New("testingdata.flowl")
+
+	var buf bytes.Buffer
+	logwriter := WithCreateLogwriter(func(string, string) (io.Writer, error) {
+		return &buf, nil
+	})
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(`
+load "go:print"
+co print {
+	"_": "before"
+}
+	`)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	if err := rt.InitFlow(ctx, id, logwriter); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+
+	err := rt.ReloadFlow(ctx, id, strings.NewReader(`
+load "go:print"
+co print {
+	"_": "after"
+}
+	`))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	// the reloaded flow is initialized with the same options
+	assert.NoError(t, rt.ExecFlow(ctx, id))
+	assert.Equal(t, "after", strings.TrimSpace(buf.String()))
+
+	assert.NoError(t, rt.Shutdown(ctx))
+	assert.Len(t, rt.store.keys(), 0)
+}
+
+func TestFunctionFailed(t *testing.T) {
+	const testingdata string = `
+load "go:print"
+
+var x = "notjson"
+co print {
+	"_": "$(json_get($(x), 'a'))"
+}
+	`
+	ctx := context.Background()
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.NoError(t, rt.InitFlow(ctx, id))
+	// the flow fails by the error of function, it doesn't panic
+	err := rt.ExecFlow(ctx, id)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), eval.ErrArgumentIllegal.Error())
+	assert.Contains(t, err.Error(), "co 'print'")
+}
+
+func TestValidateArgs(t *testing.T) {
+	cases := []struct {
+		data string
+		err  error
+	}{
+		{
+			data: `
+load "go:sleep"
+co sleep {
+	"duration": "abc"
+}
+			`,
+			err: manifest.ErrInvalidArgument,
+		},
+		{
+			data: `
+load "go:command"
+co command
+			`,
+			err: manifest.ErrMissingArgument,
+		},
+		{
+			data: `
+load "go:time"
+co time {
+	"format": "YYYY"
+}
+			`,
+			err: manifest.ErrInvalidArgument,
+		},
+	}
+	ctx := context.Background()
+	for _, c := range cases {
+		rt := New()
+		id := nameid.New("testingdata.flowl")
+		if err := rt.ParseFlow(ctx, id, strings.NewReader(c.data)); err != nil {
+			assert.FailNow(t, err.Error())
+		}
+		err := rt.InitFlow(ctx, id)
+		assert.ErrorIs(t, err, c.err)
+	}
+
+	// The argument that contains variables is validated when it's resolved
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	err := rt.ParseFlow(ctx, id, strings.NewReader(`
+load "go:sleep"
+var d = "abc"
+co sleep {
+	"duration": "$(d)"
+}
+	`))
+	if err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.NoError(t, rt.InitFlow(ctx, id))
+	err = rt.ExecFlow(ctx, id)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), manifest.ErrInvalidArgument.Error())
+}
+
+func TestCheckFieldRefs(t *testing.T) {
+	const testingdata string = `
+load "go:time"
+load "go:print"
+load "go:sleep"
+
+var out
+var s
+co time -> out
+co sleep -> s
+co print {
+	"now": "$(out.now)"
+	// sleep doesn't declare the return values, so it isn't checked
+	"slept": "$(s.x)"
+}
+co print {
+	"year": "$(out.yaer)"
+}
+	`
+	ctx := context.Background()
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	err := rt.InitFlow(ctx, id)
+	assert.ErrorIs(t, err, actuator.ErrReturnValueNotDeclared)
+	assert.Contains(t, err.Error(), "line 16: '$(out.yaer)'")
+
+	// The references that can't be checked are the warnings of the flow
+	id = nameid.New("warnings.flowl")
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(`
+load "go:sleep"
+load "go:print"
+
+var s
+co sleep -> s
+co print {
+	"slept": "$(s.x)"
+}
+	`)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	assert.NoError(t, rt.InitFlow(ctx, id))
+	rt.FetchFlow(ctx, id, func(fb *FlowBody) error {
+		if assert.Len(t, fb.Warnings(), 1) {
+			assert.Contains(t, fb.Warnings()[0], "line 8: can't check '$(s.x)'")
+		}
+		return nil
+	})
+}
+
+func TestRequireEnv(t *testing.T) {
+	const testingdata string = `
+require env COFUNC_TEST_A, COFUNC_TEST_B
+require env COFUNC_TEST_C
+load "go:print"
+
+co print {
+	"_": "$(env.COFUNC_TEST_A)"
+}
+	`
+	t.Setenv("COFUNC_TEST_A", "a")
+	t.Setenv("COFUNC_TEST_B", "")
+
+	ctx := context.Background()
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	err := rt.InitFlow(ctx, id)
+	assert.ErrorIs(t, err, ErrEnvMissing)
+	assert.Contains(t, err.Error(), "COFUNC_TEST_B, COFUNC_TEST_C")
+
+	t.Setenv("COFUNC_TEST_B", "b")
+	t.Setenv("COFUNC_TEST_C", "c")
+	assert.NoError(t, rt.InitFlow(ctx, id))
+}
+
+func TestParams(t *testing.T) {
+	const testingdata string = `
+load "go:print"
+
+param target
+param count int = 1
+
+co print {
+	"_": "$(target) $(count)"
+}
+	`
+	ctx := context.Background()
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	err := rt.InitFlow(ctx, id)
+	assert.ErrorIs(t, err, parser.ErrParamMissing)
+
+	params := map[string]string{"target": "linux", "count": "2"}
+	if !assert.NoError(t, rt.InitFlow(ctx, id, WithParams(params))) {
+		return
+	}
+	rt.FetchFlow(ctx, id, func(fb *FlowBody) error {
+		assert.Equal(t, "linux", fb.ast.Global().GetVarValue("target"))
+		assert.Equal(t, "2", fb.ast.Global().GetVarValue("count"))
+		return nil
+	})
+	// the params aren't bound to the environment variables
+	assert.Equal(t, "", os.Getenv("target"))
+}
+
+func TestSecrets(t *testing.T) {
+	const testingdata string = `
+load "go:print"
+
+var token = "token=$(secret.COFUNC_TEST_TOKEN)"
+co print {
+	"_": "$(secret.COFUNC_TEST_TOKEN) $(secret.COFUNC_TEST_A) $(secret.COFUNC_TEST_B)"
+}
+	`
+	t.Setenv("COFUNC_HOME", t.TempDir())
+	assert.NoError(t, secret.Default().Set("COFUNC_TEST_TOKEN", "s3cr3t"))
+
+	ctx := context.Background()
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	err := rt.InitFlow(ctx, id)
+	assert.ErrorIs(t, err, secret.ErrSecretNotFound)
+	assert.Contains(t, err.Error(), "COFUNC_TEST_A, COFUNC_TEST_B")
+
+	assert.NoError(t, secret.Default().Set("COFUNC_TEST_A", "secret-value-a"))
+	assert.NoError(t, secret.Default().Set("COFUNC_TEST_B", "secret-value-b"))
+	assert.NoError(t, rt.InitFlow(ctx, id))
+	// the resolved secrets are redacted
+	assert.Equal(t, "token: ***", string(secret.Redact([]byte("token: s3cr3t"))))
+	// the resolved secrets are bound to the flow
+	rt.FetchFlow(ctx, id, func(fb *FlowBody) error {
+		assert.Equal(t, "token=s3cr3t", fb.GetVarValue("token"))
+		return nil
+	})
+}
+
+func TestCheckOnly(t *testing.T) {
+	const testingdata string = `
+require env COFUNC_TEST_A
+load "go:print"
+
+var token = "token=$(secret.COFUNC_TEST_TOKEN)"
+co print {
+	"_": "$(env.COFUNC_TEST_A) $(token)"
+}
+	`
+	t.Setenv("COFUNC_HOME", t.TempDir())
+	t.Setenv("COFUNC_TEST_A", "")
+
+	ctx := context.Background()
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	// the missing environment variables and secrets don't fail the flow that's only checked
+	assert.NoError(t, rt.InitFlow(ctx, id, WithCheckOnly()))
+}
+
+func TestDryRun(t *testing.T) {
+	const testingdata string = `
+load "go:print"
+load "go:time"
+
+param build bool = "false"
+var t
+
+co time -> t
+
+switch {
+	case $(build) == true {
+		co print {
+			"_": "build at $(t.now)"
+		}
+	}
+	default {
+		co print {
+			"_": "skip build"
+		}
+	}
+}
+	`
+	ctx := context.Background()
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	plan := &Plan{}
+	returns := map[string]map[string]string{
+		"t": {"now": "2022-10-01 00:00:00"},
+	}
+	opts := []FlowOption{WithParams(map[string]string{"build": "true"}), WithDryRun(plan, returns)}
+	if !assert.NoError(t, rt.InitFlow(ctx, id, opts...)) {
+		return
+	}
+	if !assert.NoError(t, rt.ExecFlow(ctx, id)) {
+		return
+	}
+
+	steps := plan.Steps()
+	if !assert.Len(t, steps, 3) {
+		return
+	}
+	assert.Equal(t, "time ➜ go:time", steps[0].Node)
+	assert.Equal(t, returns["t"], steps[0].Returns)
+	assert.False(t, steps[1].Skipped)
+	assert.Equal(t, "build at 2022-10-01 00:00:00", steps[1].Args["_"])
+	assert.True(t, steps[2].Skipped)
+	assert.Nil(t, steps[2].Args)
+}
+
+func TestDryRunTooLong(t *testing.T) {
+	const testingdata string = `
+load "go:sleep"
+
+for {
+	co sleep {
+		"duration": "1h"
+	}
+}
+	`
+	ctx := context.Background()
+	rt := New()
+	id := nameid.New("testingdata.flowl")
+	if err := rt.ParseFlow(ctx, id, strings.NewReader(testingdata)); err != nil {
+		assert.FailNow(t, err.Error())
+	}
+	plan := &Plan{}
+	if !assert.NoError(t, rt.InitFlow(ctx, id, WithDryRun(plan, nil))) {
+		return
+	}
+	err := rt.ExecFlow(ctx, id)
+	assert.ErrorContains(t, err, ErrPlanTooLong.Error())
+	assert.Len(t, plan.Steps(), MaxPlanSteps)
+}
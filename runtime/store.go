@@ -2,9 +2,12 @@ package runtime
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 )
 
+var ErrFlowNotFound = errors.New("flow not found")
+
 type flowstore struct {
 	sync.RWMutex
 	entity map[string]*Flow
@@ -29,7 +32,25 @@ func (s *flowstore) get(k string) (*Flow, error) {
 	defer s.RUnlock()
 	v, ok := s.entity[k]
 	if !ok {
-		return nil, errors.New("not found flow: " + k)
+		return nil, fmt.Errorf("%w: %s", ErrFlowNotFound, k)
 	}
 	return v, nil
 }
+
+// delete removes the flow from flowstore
+func (s *flowstore) delete(k string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.entity, k)
+}
+
+// keys returns the keys of all flows in flowstore
+func (s *flowstore) keys() []string {
+	s.RLock()
+	defer s.RUnlock()
+	keys := make([]string, 0, len(s.entity))
+	for k := range s.entity {
+		keys = append(keys, k)
+	}
+	return keys
+}
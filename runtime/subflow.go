@@ -0,0 +1,118 @@
+package runtime
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cofunclabs/cofunc/pkg/nameid"
+	"github.com/cofunclabs/cofunc/service/resource"
+)
+
+var ErrFlowHasCycle = errors.New("flow has a cycle")
+
+// subflowRunner implements the 'resource.FlowRunner', it's created for every function node of a flow, the
+// nested flow added by the runner is a child of the function node.
+type subflowRunner struct {
+	rt *Runtime
+	// parent is the flow that the function node belongs to
+	parent *FlowBody
+	// seq is the sequence id of the function node
+	seq int
+	// child is the nested flow that added by the function node
+	child *subflow
+}
+
+// AddFlow parses and initializes the nested flow, the nested flow shares the log writer and resources with
+// its parent flow. It returns an error if the nested flow is one of the ancestors, that's a recursion cycle, the
+// flows are compared by the absolute paths of their flowl source files.
+func (r *subflowRunner) AddFlow(ctx context.Context, name, path string, rd io.Reader) (resource.NestedFlow, error) {
+	if r.child != nil {
+		return nil, fmt.Errorf("repeat to add nested flow: %s", name)
+	}
+	path = normalizePath(path)
+	ancestors := r.parent.ancestors[0:len(r.parent.ancestors):len(r.parent.ancestors)]
+	if r.parent.path != "" {
+		ancestors = append(ancestors, r.parent.path)
+	}
+	for _, ancestor := range ancestors {
+		if ancestor == path {
+			return nil, fmt.Errorf("%w: %s -> %s", ErrFlowHasCycle, strings.Join(ancestors, " -> "), path)
+		}
+	}
+
+	encoded := md5.Sum([]byte(fmt.Sprintf("%s/%d/%s", r.parent.id.ID(), r.seq, name)))
+	id := nameid.Wrap(name, fmt.Sprintf("%x", encoded))
+	if err := r.rt.ParseFlow(ctx, id, rd); err != nil {
+		return nil, fmt.Errorf("%w: nested flow '%s'", err, name)
+	}
+
+	createLogwriter := r.parent.createLogwriter
+	prefix := fmt.Sprintf("%d.", r.seq)
+	opts := []FlowOption{
+		WithCreateLogwriter(func(fileid, desc string) (io.Writer, error) {
+			return createLogwriter(prefix+fileid, desc)
+		}),
+		WithCopyResources(r.parent.copyResources),
+		WithPath(path),
+		withAncestors(ancestors),
+	}
+	if r.parent.checkOnly {
+		opts = append(opts, WithCheckOnly())
+	}
+	if err := r.rt.InitFlow(ctx, id, opts...); err != nil {
+		r.rt.DeleteFlow(ctx, id)
+		return nil, fmt.Errorf("%w: nested flow '%s'", err, name)
+	}
+	flow, err := r.rt.store.get(id.ID())
+	if err != nil {
+		return nil, err
+	}
+	r.child = &subflow{
+		rt:   r.rt,
+		id:   id,
+		flow: flow,
+	}
+	return r.child, nil
+}
+
+// subflow implements the 'resource.NestedFlow'
+type subflow struct {
+	rt   *Runtime
+	id   nameid.ID
+	flow *Flow
+}
+
+func (s *subflow) Globals() []string {
+	return s.flow.AST().Global().GetVarNames()
+}
+
+func (s *subflow) Desc() string {
+	return s.flow.AST().Desc()
+}
+
+func (s *subflow) Run(ctx context.Context, vars map[string]string, returns []string) (map[string]string, error) {
+	if err := s.rt.MustReady(ctx, s.id); err != nil {
+		return nil, err
+	}
+	// The global variables are reset to their initialized values, so the values of the last run, including the
+	// args and the changes made by the flow, don't leak into this run
+	global := s.flow.AST().Global()
+	global.RestoreValues()
+	for name, val := range vars {
+		if err := global.SetVarValue(name, val); err != nil {
+			return nil, fmt.Errorf("%w: nested flow '%s'", err, s.id.Name())
+		}
+	}
+	if err := s.rt.ExecFlow(ctx, s.id); err != nil {
+		return nil, err
+	}
+	retValues := make(map[string]string)
+	for _, name := range returns {
+		retValues[name] = global.GetVarValue(name)
+	}
+	return retValues, nil
+}
@@ -0,0 +1,330 @@
+// Package secret stores the secrets that can be referenced by '$(secret.NAME)' in flowl, they are encrypted at rest
+// by AES-GCM with a local key. The values of resolved secrets are redacted from the logs.
+package secret
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/cofunclabs/cofunc/config"
+)
+
+const (
+	keyFile   = "key"
+	storeFile = "store"
+	keySize   = 32
+)
+
+// MinLength is the minimum length of the secret value, the shorter values would be redacted everywhere in the
+// logs, e.g. a secret 'v' masks every 'v'.
+const MinLength = 4
+
+var (
+	ErrSecretNotFound = errors.New("secret not found")
+	ErrSecretName     = errors.New("secret name illegal")
+	ErrSecretValue    = errors.New("secret value illegal")
+	ErrStoreBroken    = errors.New("secret store broken")
+)
+
+// secretName is the pattern of the secret name, it's the same as the name of environment variable
+var secretName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Store is a file-backed secret store, the key and the encrypted secrets are stored in the directory.
+type Store struct {
+	sync.Mutex
+	dir string
+}
+
+// Open returns the store in the directory, the directory is created when the first secret is set.
+func Open(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Default returns the store in $COFUNC_HOME/secrets
+func Default() *Store {
+	return Open(config.SecretDir())
+}
+
+// Set adds or updates the secret
+func (s *Store) Set(name, value string) error {
+	if !secretName.MatchString(name) {
+		return fmt.Errorf("%w: '%s'", ErrSecretName, name)
+	}
+	if len(value) < MinLength {
+		return fmt.Errorf("%w: '%s' is shorter than %d characters", ErrSecretValue, name, MinLength)
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	return s.save(secrets)
+}
+
+// Get returns the value of the secret, it returns ErrSecretNotFound if the secret doesn't exist.
+func (s *Store) Get(name string) (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	v, ok := secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: '%s'", ErrSecretNotFound, name)
+	}
+	return v, nil
+}
+
+// Delete removes the secret, it returns ErrSecretNotFound if the secret doesn't exist.
+func (s *Store) Delete(name string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[name]; !ok {
+		return fmt.Errorf("%w: '%s'", ErrSecretNotFound, name)
+	}
+	delete(secrets, name)
+	return s.save(secrets)
+}
+
+// Names returns the names of all secrets in alphabetical order, the values aren't returned.
+func (s *Store) Names() ([]string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *Store) load() (map[string]string, error) {
+	secrets := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(s.dir, storeFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return secrets, nil
+		}
+		return nil, err
+	}
+	gcm, err := s.cipher(false)
+	if err != nil {
+		return nil, err
+	}
+	n := gcm.NonceSize()
+	if len(data) < n {
+		return nil, ErrStoreBroken
+	}
+	plain, err := gcm.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrStoreBroken, err)
+	}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrStoreBroken, err)
+	}
+	return secrets, nil
+}
+
+func (s *Store) save(secrets map[string]string) error {
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	gcm, err := s.cipher(true)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	// write to a temporary file first, so the store isn't broken if the writing fails
+	path := filepath.Join(s.dir, storeFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, gcm.Seal(nonce, nonce, plain, nil), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// cipher returns the AES-GCM cipher with the local key, the key is generated if it doesn't exist and 'create'
+// is true.
+func (s *Store) cipher(create bool) (cipher.AEAD, error) {
+	path := filepath.Join(s.dir, keyFile)
+	key, err := os.ReadFile(path)
+	if os.IsNotExist(err) && create {
+		if err := os.MkdirAll(s.dir, 0700); err != nil {
+			return nil, err
+		}
+		key = make([]byte, keySize)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, key, 0600); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: read key: %s", ErrStoreBroken, err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("%w: key size %d", ErrStoreBroken, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// mask replaces the values of secrets in the logs
+const mask = "***"
+
+var redaction = struct {
+	sync.RWMutex
+	values [][]byte
+}{}
+
+// Resolve returns the value of the secret in the default store, the value is redacted from the logs after it's
+// resolved.
+func Resolve(name string) (string, error) {
+	v, err := Default().Get(name)
+	if err != nil {
+		return "", err
+	}
+	track(v)
+	return v, nil
+}
+
+// track makes the value redacted from the logs, the values shorter than MinLength are ignored, they may be set
+// before the length is limited.
+func track(v string) {
+	if len(v) < MinLength {
+		return
+	}
+	redaction.Lock()
+	defer redaction.Unlock()
+	for _, tracked := range redaction.values {
+		if string(tracked) == v {
+			return
+		}
+	}
+	redaction.values = append(redaction.values, []byte(v))
+	// the longer values are replaced first, so a value that contains another is redacted entirely
+	sort.SliceStable(redaction.values, func(i, j int) bool {
+		return len(redaction.values[i]) > len(redaction.values[j])
+	})
+}
+
+// Redact replaces the values of resolved secrets in the data with '***', the data is returned directly if there
+// isn't any secret in it.
+func Redact(p []byte) []byte {
+	redaction.RLock()
+	defer redaction.RUnlock()
+	for _, v := range redaction.values {
+		if bytes.Contains(p, v) {
+			p = bytes.ReplaceAll(p, v, []byte(mask))
+		}
+	}
+	return p
+}
+
+// Writer redacts the values of secrets from the data written to the underlying writer. The data is written in
+// chunks, e.g. the output of a command through a pipe, so the tail that may be the beginning of a secret is held
+// until the following data is written or Flush is called.
+type Writer struct {
+	sync.Mutex
+	w       io.Writer
+	pending []byte
+}
+
+// NewWriter returns a Writer that writes the redacted data to 'w'.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write implements the io.Writer interface, it returns the length of 'p' if the redacted data is written.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	data := append(w.pending, p...)
+	cut := held(data)
+	w.pending = append([]byte(nil), data[cut:]...)
+	if cut == 0 {
+		return len(p), nil
+	}
+	if _, err := w.w.Write(Redact(data[:cut])); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes the held data.
+func (w *Writer) Flush() error {
+	w.Lock()
+	defer w.Unlock()
+	if len(w.pending) == 0 {
+		return nil
+	}
+	data := w.pending
+	w.pending = nil
+	_, err := w.w.Write(Redact(data))
+	return err
+}
+
+// held returns the position where the tail of data is held, the tail is the longest one that is the beginning of
+// a secret, and the data before the position doesn't end in the middle of a secret.
+func held(data []byte) int {
+	redaction.RLock()
+	defer redaction.RUnlock()
+	cut := len(data)
+	for _, v := range redaction.values {
+		for n := len(v) - 1; n > 0; n-- {
+			if n <= len(data) && bytes.HasSuffix(data, v[:n]) {
+				if len(data)-n < cut {
+					cut = len(data) - n
+				}
+				break
+			}
+		}
+	}
+	for moved := true; moved; {
+		moved = false
+		for _, v := range redaction.values {
+			from := cut - len(v) + 1
+			if from < 0 {
+				from = 0
+			}
+			if i := bytes.Index(data[from:], v); i != -1 && from+i < cut {
+				cut = from + i
+				moved = true
+			}
+		}
+	}
+	return cut
+}
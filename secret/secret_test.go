@@ -0,0 +1,91 @@
+package secret
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "secrets")
+	s := Open(dir)
+
+	names, err := s.Names()
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+
+	assert.NoError(t, s.Set("TOKEN", "s3cr3t-value"))
+	assert.NoError(t, s.Set("OTHER", "other"))
+	assert.ErrorIs(t, s.Set("a-b", "other"), ErrSecretName)
+	// the short value would be redacted everywhere
+	assert.ErrorIs(t, s.Set("SHORT", "v"), ErrSecretValue)
+
+	v, err := Open(dir).Get("TOKEN")
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cr3t-value", v)
+	names, err = s.Names()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"OTHER", "TOKEN"}, names)
+
+	// the secrets are encrypted at rest
+	data, err := os.ReadFile(filepath.Join(dir, storeFile))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "s3cr3t-value")
+	fi, err := os.Stat(filepath.Join(dir, keyFile))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	assert.NoError(t, s.Delete("OTHER"))
+	assert.ErrorIs(t, s.Delete("OTHER"), ErrSecretNotFound)
+	_, err = s.Get("OTHER")
+	assert.ErrorIs(t, err, ErrSecretNotFound)
+
+	// the store can't be decrypted by another key
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, keyFile), []byte(strings.Repeat("k", keySize)), 0600))
+	_, err = s.Get("TOKEN")
+	assert.ErrorIs(t, err, ErrStoreBroken)
+}
+
+func TestRedact(t *testing.T) {
+	t.Setenv("COFUNC_HOME", t.TempDir())
+	assert.NoError(t, Default().Set("TOKEN", "abc123"))
+	assert.NoError(t, Default().Set("LONG", "xxabc123xx"))
+
+	data := []byte("token=abc123, long=xxabc123xx")
+	assert.Equal(t, data, Redact(data))
+
+	_, err := Resolve("NOPE")
+	assert.ErrorIs(t, err, ErrSecretNotFound)
+	for _, name := range []string{"TOKEN", "LONG"} {
+		_, err := Resolve(name)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "token=***, long=***", string(Redact(data)))
+	assert.Equal(t, "nothing", string(Redact([]byte("nothing"))))
+}
+
+func TestWriter(t *testing.T) {
+	t.Setenv("COFUNC_HOME", t.TempDir())
+	assert.NoError(t, Default().Set("PASSWORD", "p@ssw0rd"))
+	_, err := Resolve("PASSWORD")
+	assert.NoError(t, err)
+	// the values shorter than MinLength aren't redacted, they may be set before the length is limited
+	track("v")
+	assert.Equal(t, "v=1", string(Redact([]byte("v=1"))))
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	// the secret is split across the writes
+	for _, s := range []string{"password: p@s", "sw", "0rd\n", "done p", "@ss"} {
+		n, err := w.Write([]byte(s))
+		assert.NoError(t, err)
+		assert.Equal(t, len(s), n)
+	}
+	assert.Equal(t, "password: ***\ndone ", buf.String())
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "password: ***\ndone p@ss", buf.String())
+}
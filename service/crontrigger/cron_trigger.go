@@ -19,7 +19,11 @@ func New() *CronTrigger {
 
 func (ct *CronTrigger) Add(format string, ch chan<- time.Time) (interface{}, error) {
 	entityid, err := ct.c.AddFunc(format, func() {
-		ch <- time.Now()
+		// Don't block the cron goroutine when nobody is waiting, the event is dropped
+		select {
+		case ch <- time.Now():
+		default:
+		}
 	})
 	if err != nil {
 		return nil, err
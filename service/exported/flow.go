@@ -16,6 +16,8 @@ type NodeRunningInsight struct {
 	Status    string `json:"status"`
 	Runs      int    `json:"runs"`
 	Duration  int64  `json:"duration"`
+	// Nodes are the nodes of the nested flow, only for the 'flow' driver
+	Nodes []NodeRunningInsight `json:"nodes,omitempty"`
 }
 
 type FlowRunningInsight struct {
@@ -0,0 +1,24 @@
+package exported
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+type InstalledFunction struct {
+	Driver      string    `json:"driver"`
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	Checksum    string    `json:"checksum"`
+	Source      string    `json:"source"`
+	InstalledAt time.Time `json:"installed_at"`
+	// Modified is true if the installed files are modified after installation
+	Modified bool `json:"modified"`
+}
+
+func (i InstalledFunction) JsonWrite(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(i)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+
+	"github.com/cofunclabs/cofunc/repository"
+	"github.com/cofunclabs/cofunc/service/exported"
+)
+
+// InstallFunction installs a function package from a local directory or tarball into the function repository.
+func (s *SVC) InstallFunction(ctx context.Context, src string, force bool) (exported.InstalledFunction, error) {
+	e, err := repository.Install(src, force)
+	if err != nil {
+		return exported.InstalledFunction{}, err
+	}
+	return exportEntry(e), nil
+}
+
+// UninstallFunction removes the installed function from the function repository, the 'target' is
+// 'driver:name[@version]'
+func (s *SVC) UninstallFunction(ctx context.Context, target string) ([]exported.InstalledFunction, error) {
+	entries, err := repository.Uninstall(target)
+	if err != nil {
+		return nil, err
+	}
+	var removed []exported.InstalledFunction
+	for _, e := range entries {
+		removed = append(removed, exportEntry(e))
+	}
+	return removed, nil
+}
+
+// ListInstalledFunctions returns all functions that installed in the function repository.
+func (s *SVC) ListInstalledFunctions(ctx context.Context) ([]exported.InstalledFunction, error) {
+	entries, err := repository.List()
+	if err != nil {
+		return nil, err
+	}
+	var list []exported.InstalledFunction
+	for _, e := range entries {
+		fi := exportEntry(e)
+		fi.Modified = e.Verify() != nil
+		list = append(list, fi)
+	}
+	return list, nil
+}
+
+// LockFlow pins the versions of the functions that loaded by the flowl source file, the lock file will
+// be used when the flow is added.
+func (s *SVC) LockFlow(ctx context.Context, path string) (map[string]string, error) {
+	lock, err := repository.LockFlow(path)
+	if err != nil {
+		return nil, err
+	}
+	return lock.Versions(), nil
+}
+
+func exportEntry(e repository.Entry) exported.InstalledFunction {
+	return exported.InstalledFunction{
+		Driver:      e.Driver,
+		Name:        e.Name,
+		Version:     e.Version,
+		Checksum:    e.Checksum,
+		Source:      e.Source,
+		InstalledAt: e.InstalledAt,
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/cofunclabs/cofunc/pkg/output"
+	"github.com/cofunclabs/cofunc/secret"
 )
 
 type LogsetOption func(*Logset)
@@ -169,6 +170,8 @@ type logFile struct {
 	sync.Mutex
 	file     *os.File
 	filePath string
+	// redact redacts the secrets from the data written to the file
+	redact *secret.Writer
 }
 
 // newLogFile2Write create a 'LogFile' object, use it to write the output content into a file, the argument
@@ -191,6 +194,7 @@ func newLogFile2Write(path string) (*logFile, error) {
 	return &logFile{
 		file:     f,
 		filePath: path,
+		redact:   secret.NewWriter(f),
 	}, nil
 }
 
@@ -207,11 +211,21 @@ func newLogFile2Read(path string) (*logFile, error) {
 	}, nil
 }
 
-// Write implements the io.Writer interface
+// Write implements the io.Writer interface, the values of secrets are redacted before writing
 func (lf *logFile) Write(p []byte) (int, error) {
 	lf.Lock()
 	defer lf.Unlock()
-	return lf.file.Write(p)
+	return lf.redact.Write(p)
+}
+
+// Flush writes the data held for redacting the secrets, it's called after the function finished.
+func (lf *logFile) Flush() error {
+	lf.Lock()
+	defer lf.Unlock()
+	if lf.redact == nil {
+		return nil
+	}
+	return lf.redact.Flush()
 }
 
 // Read implements the io.Reader interface
@@ -226,6 +240,9 @@ func (lf *logFile) Close() error {
 	lf.Lock()
 	defer lf.Unlock()
 
+	if lf.redact != nil {
+		lf.redact.Flush()
+	}
 	if lf.file != nil {
 		lf.file.Close()
 	}
@@ -246,6 +263,7 @@ func (lf *logFile) Reset() error {
 
 	lf.Lock()
 	lf.file = f
+	lf.redact = secret.NewWriter(f)
 	lf.Unlock()
 
 	return nil
@@ -255,6 +273,8 @@ type logStdout struct {
 	sync.Mutex
 	w   io.Writer
 	out *output.Output
+	// redact redacts the secrets from the data written to 'out'
+	redact *secret.Writer
 	// Usually, the id is the seq of this function
 	id string
 	// Usually, the desc is the name of this function
@@ -274,6 +294,7 @@ func newLogStdout(id, desc string) *logStdout {
 			ls.w.Write(line)
 		},
 	}
+	ls.redact = secret.NewWriter(ls.out)
 	return ls
 }
 
@@ -297,6 +318,12 @@ func (l *logStdout) PrintSummary() {
 func (l *logStdout) Write(p []byte) (int, error) {
 	l.Lock()
 	defer l.Unlock()
+	return l.redact.Write(p)
+}
 
-	return l.out.Write(p)
+// Flush writes the data held for redacting the secrets, it's called after the function finished.
+func (l *logStdout) Flush() error {
+	l.Lock()
+	defer l.Unlock()
+	return l.redact.Flush()
 }
@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"time"
@@ -18,6 +19,7 @@ type Resources struct {
 	Logwriter   io.Writer
 	CronTrigger CronTrigger
 	HttpTrigger HttpTrigger
+	FlowRunner  FlowRunner
 }
 
 // CronTrigger add and remove the cron job by trigger function, the CronTrigger is a resrouce for trigger.
@@ -31,3 +33,20 @@ type HttpTrigger interface {
 	AddRoute(path string, handler func(w http.ResponseWriter, r *http.Request)) error
 	RemoveRoute(path string) error
 }
+
+// FlowRunner adds another flow as a nested flow of the current flow, the FlowRunner is a resource for the
+// 'flow' driver. The 'path' is the path of the flowl source file that 'rd' reads from.
+type FlowRunner interface {
+	AddFlow(ctx context.Context, name, path string, rd io.Reader) (NestedFlow, error)
+}
+
+// NestedFlow is a flow that running inside its parent flow.
+type NestedFlow interface {
+	// Globals returns the names of the global variables of the nested flow.
+	Globals() []string
+	// Desc returns the description of the nested flow.
+	Desc() string
+	// Run sets the global variables by 'vars', then executes the nested flow, the values of the variables
+	// that specified by 'returns' will be returned.
+	Run(ctx context.Context, vars map[string]string, returns []string) (map[string]string, error)
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"context"
+
+	"github.com/cofunclabs/cofunc/secret"
+)
+
+// SetSecret adds or updates a secret in the secret store, it can be referenced by '$(secret.NAME)' in flowl.
+func (s *SVC) SetSecret(ctx context.Context, name, value string) error {
+	return secret.Default().Set(name, value)
+}
+
+// DeleteSecret removes a secret from the secret store
+func (s *SVC) DeleteSecret(ctx context.Context, name string) error {
+	return secret.Default().Delete(name)
+}
+
+// ListSecrets returns the names of all secrets, the values aren't returned.
+func (s *SVC) ListSecrets(ctx context.Context) ([]string, error) {
+	return secret.Default().Names()
+}
@@ -8,10 +8,12 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
 
 	co "github.com/cofunclabs/cofunc"
 	"github.com/cofunclabs/cofunc/config"
 	"github.com/cofunclabs/cofunc/pkg/nameid"
+	"github.com/cofunclabs/cofunc/repository"
 	"github.com/cofunclabs/cofunc/runtime"
 	"github.com/cofunclabs/cofunc/runtime/actuator"
 	"github.com/cofunclabs/cofunc/service/crontrigger"
@@ -31,6 +33,8 @@ type SVC struct {
 	stdout  *logset.Logset
 	// cron service for flow and function
 	cron *crontrigger.CronTrigger
+	// paths store the paths of the flowl source files of the added flows, the key is the string of flow's id.
+	paths sync.Map
 }
 
 // New create a service layer instance
@@ -129,17 +133,96 @@ func (s *SVC) CancelRunningFlow(ctx context.Context, id nameid.ID) error {
 	return s.rt.CancelFlow(ctx, id)
 }
 
-// AddFlow parse a flowl source file and add a flow instance into runtime
-func (s *SVC) AddFlow(ctx context.Context, id nameid.ID, rd io.ReadCloser) error {
-	defer rd.Close()
-	if err := s.rt.ParseFlow(ctx, id, rd); err != nil {
+// AddFlow parse the flowl source file of the 'path' and add a flow instance into runtime, if the flowl source
+// file is locked, the functions will be resolved to the locked versions.
+func (s *SVC) AddFlow(ctx context.Context, id nameid.ID, path string) error {
+	opts, err := parseOptions(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := s.rt.ParseFlow(ctx, id, f, opts...); err != nil {
 		return err
 	}
+	s.paths.Store(id.ID(), path)
 	return nil
 }
 
-// ReadyFlow initialize the flow and make it ready to run
-func (s *SVC) ReadyFlow(ctx context.Context, id nameid.ID, toStdout bool) (exported.FlowRunningInsight, error) {
+// parseOptions returns the options to parse the flowl source file of the 'path', the lockfile next to it is
+// read every time, so that the changes of the lockfile take effect when the flow is reloaded.
+func parseOptions(path string) ([]actuator.Option, error) {
+	lock, err := repository.ReadFlowLock(path)
+	if err != nil {
+		return nil, err
+	}
+	if lock == nil {
+		return nil, nil
+	}
+	if err := lock.Verify(); err != nil {
+		return nil, err
+	}
+	return []actuator.Option{actuator.WithPinnedVersions(lock.Versions())}, nil
+}
+
+// DeleteFlow releases the flow and removes it from runtime, the running flow will be canceled.
+func (s *SVC) DeleteFlow(ctx context.Context, id nameid.ID) error {
+	s.paths.Delete(id.ID())
+	return s.rt.DeleteFlow(ctx, id)
+}
+
+// ReloadFlow releases the flow, then parse the flowl source file of the 'path' and its lockfile again, the
+// status of the flow is kept
+func (s *SVC) ReloadFlow(ctx context.Context, id nameid.ID, path string) error {
+	opts, err := parseOptions(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s.paths.Store(id.ID(), path)
+	return s.rt.ReloadFlow(ctx, id, f, opts...)
+}
+
+// Shutdown releases all flows and stops the services, it should be invoked before the process exits.
+func (s *SVC) Shutdown(ctx context.Context) error {
+	defer s.cron.Stop()
+	return s.rt.Shutdown(ctx)
+}
+
+// ReadyFlow initialize the flow and make it ready to run, the 'params' are the values of params of the flow.
+func (s *SVC) ReadyFlow(ctx context.Context, id nameid.ID, toStdout bool, params map[string]string) (exported.FlowRunningInsight, error) {
+	if err := s.rt.InitFlow(ctx, id, s.flowOptions(id, toStdout, params)...); err != nil {
+		return exported.FlowRunningInsight{}, err
+	}
+	fi, err := s.InsightFlow(ctx, id)
+	if err != nil {
+		return exported.FlowRunningInsight{}, err
+	}
+	return fi, nil
+}
+
+// DryRunFlow initializes the flow in the dry-run mode and walks it once without event triggers, the functions
+// aren't run, the 'returns' are their canned return values. It returns the plan of the walked nodes.
+func (s *SVC) DryRunFlow(ctx context.Context, id nameid.ID, params map[string]string, returns map[string]map[string]string) (*runtime.Plan, error) {
+	plan := &runtime.Plan{}
+	opts := append(s.flowOptions(id, true, params), runtime.WithDryRun(plan, returns))
+	if err := s.rt.InitFlow(ctx, id, opts...); err != nil {
+		return nil, err
+	}
+	if err := s.StartFlowAndWait(ctx, id); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
+
+func (s *SVC) flowOptions(id nameid.ID, toStdout bool, params map[string]string) []runtime.FlowOption {
 	createLogWriter := func(writerid, desc string) (io.Writer, error) {
 		if toStdout {
 			return s.stdout.CreateBucket(id.ID()).CreateWriter(writerid, desc)
@@ -165,15 +248,12 @@ func (s *SVC) ReadyFlow(ctx context.Context, id nameid.ID, toStdout bool) (expor
 		runtime.WithAfterFunc(afterExec),
 		runtime.WithCopyResources(copy),
 		runtime.WithCreateLogwriter(createLogWriter),
+		runtime.WithParams(params),
 	}
-	if err := s.rt.InitFlow(ctx, id, opts...); err != nil {
-		return exported.FlowRunningInsight{}, err
-	}
-	fi, err := s.InsightFlow(ctx, id)
-	if err != nil {
-		return exported.FlowRunningInsight{}, err
+	if path, ok := s.paths.Load(id.ID()); ok {
+		opts = append(opts, runtime.WithPath(path.(string)))
 	}
-	return fi, nil
+	return opts
 }
 
 // StartFlow starts a flow without event triggers, it will return a channel that can be used to
@@ -264,7 +344,7 @@ func restoreAvailables(dir string) (map[string]exported.FlowMetaInsight, error)
 		if err != nil {
 			return fmt.Errorf("%w: access path '%s'", err, path)
 		}
-		if info.IsDir() {
+		if info.IsDir() || !co.IsFlowl(path) {
 			return nil
 		}
 		sources = append(sources, path)
@@ -11,8 +11,9 @@ import (
 )
 
 var cmdArg = manifest.UsageDesc{
-	Name: "cmd",
-	Desc: "specify a command to run",
+	Name:     "cmd",
+	Desc:     "specify a command to run",
+	Required: true,
 }
 
 var _manifest = manifest.Manifest{
@@ -2,6 +2,7 @@ package eventcron
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/cofunclabs/cofunc/functiondriver/go/spec"
@@ -13,6 +14,12 @@ var exprArg = manifest.UsageDesc{
 	Name:           "expr",
 	OptionalValues: []string{},
 	Desc:           "A cron expression, e.g. 0 0 * * *, 0 15 10 ? * *",
+	Required:       true,
+}
+
+var whichRet = manifest.UsageDesc{
+	Name: "which",
+	Desc: "The name of the event function that triggered the event",
 }
 
 var _manifest = manifest.Manifest{
@@ -26,7 +33,7 @@ var _manifest = manifest.Manifest{
 	IgnoreFailure:  false,
 	Usage: manifest.Usage{
 		Args:         []manifest.UsageDesc{exprArg},
-		ReturnValues: []manifest.UsageDesc{}},
+		ReturnValues: []manifest.UsageDesc{whichRet}},
 }
 
 func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
@@ -36,19 +43,13 @@ func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
 func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (map[string]string, error) {
 	expr := args.GetString(exprArg.Name)
 	custom := bundle.Custom.(*custom)
-	if custom.entity == nil && custom.cron == nil {
-		cron := bundle.Resources.CronTrigger
-		entity, err := cron.Add(expr, custom.waiting)
-		if err != nil {
-			return nil, err
-		}
-		custom.entity = entity
-		custom.cron = cron
+	if err := custom.add(bundle.Resources.CronTrigger, expr); err != nil {
+		return nil, err
 	}
 
 	select {
 	case <-custom.waiting:
-		return map[string]string{"which": _manifest.Name}, nil
+		return map[string]string{whichRet.Name: _manifest.Name}, nil
 	case <-ctx.Done():
 		custom.Close()
 		return nil, ctx.Err()
@@ -56,15 +57,38 @@ func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.Ent
 }
 
 type custom struct {
+	sync.Mutex
 	entity  interface{}
 	cron    resource.CronTrigger
 	waiting chan time.Time
 }
 
+// add adds the cron entry if it's not added yet
+func (c *custom) add(cron resource.CronTrigger, expr string) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.cron != nil {
+		return nil
+	}
+	entity, err := cron.Add(expr, c.waiting)
+	if err != nil {
+		return err
+	}
+	c.entity = entity
+	c.cron = cron
+	return nil
+}
+
+// Close removes the cron entry, it's called when the flow is canceled or the driver is released, so it may be
+// called multiple times. The waiting channel isn't closed, because the cron entry may be added again.
 func (c *custom) Close() error {
-	close(c.waiting)
-	c.cron.Remove(c.entity)
+	c.Lock()
+	defer c.Unlock()
+	if c.cron == nil {
+		return nil
+	}
+	err := c.cron.Remove(c.entity)
 	c.entity = nil
 	c.cron = nil
-	return nil
+	return err
 }
@@ -10,9 +10,15 @@ import (
 
 var durationArg = manifest.UsageDesc{
 	Name: "duration",
+	Type: manifest.TypeDuration,
 	Desc: "A time duration, e.g. 1s, 1m, 1h, 1m10s",
 }
 
+var whichRet = manifest.UsageDesc{
+	Name: "which",
+	Desc: "The name of the event function that triggered the event",
+}
+
 var _manifest = manifest.Manifest{
 	Category:    "event",
 	Name:        "event_tick",
@@ -24,7 +30,7 @@ var _manifest = manifest.Manifest{
 	RetryOnFailure: 0,
 	Usage: manifest.Usage{
 		Args:         []manifest.UsageDesc{durationArg},
-		ReturnValues: []manifest.UsageDesc{},
+		ReturnValues: []manifest.UsageDesc{whichRet},
 	},
 }
 
@@ -33,15 +39,15 @@ func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
 }
 
 func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (map[string]string, error) {
-	s := args.GetString(durationArg.Name)
-	v, err := time.ParseDuration(s)
+	v, err := args.GetDuration(durationArg.Name)
 	if err != nil {
 		return nil, err
 	}
 	ticker := time.NewTicker(v)
+	defer ticker.Stop()
 	select {
 	case <-ticker.C:
-		return map[string]string{"which": _manifest.Name}, nil
+		return map[string]string{whichRet.Name: _manifest.Name}, nil
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	}
@@ -22,6 +22,11 @@ var upstreamArg = manifest.UsageDesc{
 	Desc: "Specify upstream to sync, it not set, will try to find out it from 'git remote -v'",
 }
 
+var outcomeRet = manifest.UsageDesc{
+	Name: "outcome",
+	Desc: "The outcome of syncing",
+}
+
 var _manifest = manifest.Manifest{
 	Category:    "git",
 	Name:        "git_sync_upstream",
@@ -33,7 +38,7 @@ var _manifest = manifest.Manifest{
 	RetryOnFailure: 0,
 	Usage: manifest.Usage{
 		Args:         []manifest.UsageDesc{branchArg},
-		ReturnValues: []manifest.UsageDesc{},
+		ReturnValues: []manifest.UsageDesc{outcomeRet},
 	},
 }
 
@@ -55,7 +60,7 @@ func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.Ent
 		}
 	}
 	if !found {
-		return map[string]string{"outcome": "no sync: not sync this branch"}, nil
+		return map[string]string{outcomeRet.Name: "no sync: not sync this branch"}, nil
 	}
 
 	upstream, err := getUpstreamAddr(ctx)
@@ -63,7 +68,7 @@ func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.Ent
 		return nil, err
 	}
 	if upstream == "" {
-		return map[string]string{"outcome": "no sync: not found upstream"}, nil
+		return map[string]string{outcomeRet.Name: "no sync: not found upstream"}, nil
 	}
 	// git fetch --all
 	if err := fetchRemotes(ctx); err != nil {
@@ -82,7 +87,7 @@ func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.Ent
 	switch state1 {
 	case consistent:
 		if state2 == consistent {
-			return map[string]string{"outcome": "no sync: three branches are consistent"}, nil
+			return map[string]string{outcomeRet.Name: "no sync: three branches are consistent"}, nil
 		} else {
 			if err := pushOrigin(ctx, currentBranch); err != nil {
 				return nil, err
@@ -90,7 +95,7 @@ func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.Ent
 		}
 		return nil, nil
 	case conflict:
-		return map[string]string{"outcome": "no sync: branches are conflict"}, nil
+		return map[string]string{outcomeRet.Name: "no sync: branches are conflict"}, nil
 	case noConflict:
 	}
 
@@ -104,7 +109,7 @@ func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.Ent
 		return nil, err
 	}
 
-	return map[string]string{"outcome": "synced"}, nil
+	return map[string]string{outcomeRet.Name: "synced"}, nil
 }
 
 func pushOrigin(ctx context.Context, branch string) error {
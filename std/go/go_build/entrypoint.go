@@ -20,10 +20,12 @@ var (
 	}
 	binFormatArg = manifest.UsageDesc{
 		Name: "bin_format",
+		Type: manifest.TypeList,
 		Desc: "Specifies the format of the binary file that to be built",
 	}
 	mainpkgArg = manifest.UsageDesc{
 		Name: "find_mainpkg_dirs",
+		Type: manifest.TypeList,
 		Desc: `Specifies the dirs to find main package, if there are more than one, separated by ','. If not specified, it will find it from current dir.`,
 	}
 )
@@ -31,6 +33,11 @@ var (
 var (
 	outcomeRet = manifest.UsageDesc{
 		Name: "outcome",
+		Desc: "The paths of the built binaries, separated by ','",
+	}
+	artifactsRet = manifest.UsageDesc{
+		Name: "artifacts",
+		Desc: "The list of the built binaries, each has 'path', 'os' and 'arch', e.g. '$(out.artifacts[0].path)'",
 	}
 )
 
@@ -46,15 +53,15 @@ var _manifest = manifest.Manifest{
 	RetryOnFailure: 0,
 	Usage: manifest.Usage{
 		Args:         []manifest.UsageDesc{prefixArg, binFormatArg, mainpkgArg},
-		ReturnValues: []manifest.UsageDesc{outcomeRet},
+		ReturnValues: []manifest.UsageDesc{outcomeRet, artifactsRet},
 	},
 }
 
-func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
+func New() (*manifest.Manifest, spec.StructuredEntrypointFunc, spec.CreateCustomFunc) {
 	return &_manifest, Entrypoint, nil
 }
 
-func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (map[string]string, error) {
+func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (spec.Returns, error) {
 	bins, err := parseBinFormats(args.GetStringSlice(binFormatArg.Name))
 	if err != nil {
 		return nil, err
@@ -68,7 +75,10 @@ func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.Ent
 		fmt.Fprintf(bundle.Resources.Logwriter, "mod %s in %s\n", m.name, m.dir)
 	}
 
-	var outcomes []string
+	var (
+		outcomes  []string
+		artifacts = make([]interface{}, 0)
+	)
 	for _, mod := range mods {
 		for _, pkg := range mod.mainpkgs {
 			for _, bin := range bins {
@@ -88,13 +98,20 @@ func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.Ent
 				if err := cmd.Wait(); err != nil {
 					return nil, err
 				}
-				outcomes = append(outcomes, filepath.Join(mod.dir, dstbin))
+				path := filepath.Join(mod.dir, dstbin)
+				outcomes = append(outcomes, path)
+				artifacts = append(artifacts, map[string]interface{}{
+					"path": path,
+					"os":   bin.os,
+					"arch": bin.arch,
+				})
 			}
 		}
 	}
 
-	return map[string]string{
-		outcomeRet.Name: strings.Join(outcomes, ","),
+	return spec.Returns{
+		outcomeRet.Name:   strings.Join(outcomes, ","),
+		artifactsRet.Name: artifacts,
 	}, nil
 }
 
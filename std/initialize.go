@@ -18,6 +18,10 @@ import (
 	stdtime "github.com/cofunclabs/cofunc/std/time"
 )
 
+// Version is the version of the standard library, it's the version of the function that's not specified
+// the version in its manifest.
+const Version = "0.1.0"
+
 // Lookup returns the manifest object and entrypoint method of the given function name.
 func Lookup(name string) (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
 	fc, ok := builtin[name]
@@ -66,7 +70,6 @@ func init() {
 		print.New,
 		command.New,
 		stdtime.New,
-		gobuild.New,
 		gogenerate.New,
 		outcome.New,
 		syncupstream.New,
@@ -75,11 +78,31 @@ func init() {
 		eventcron.New,
 	}
 
+	// the functions that return the nested values
+	var structured = []func() (*manifest.Manifest, spec.StructuredEntrypointFunc, spec.CreateCustomFunc){
+		gobuild.New,
+	}
+	for _, New := range structured {
+		mf, sep, cr := New()
+		// the entrypoint name is got from the structured entrypoint, because the flattened one is a closure
+		mf.Entrypoint = spec.Func2Name(sep)
+		ep := spec.Flat(sep)
+		stds = append(stds, func() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
+			return mf, ep, cr
+		})
+	}
+
 	for i, New := range stds {
 		mf, ep, cr := New()
 		// Get entrypoint name from entrypointfunc, then auto register the entrypoint field of the manifest.
 		// NOTE: Automatically getted the entrypoint name is unique
-		mf.Entrypoint = spec.Func2Name(ep)
+		if mf.Entrypoint == "" {
+			mf.Entrypoint = spec.Func2Name(ep)
+		}
+
+		if mf.Version == "" {
+			mf.Version = Version
+		}
 
 		if mf.Name == "" {
 			panic(fmt.Errorf("name is empty in manifest %d", i))
@@ -14,5 +14,8 @@ func TestStdInit(t *testing.T) {
 		ep := m.Entrypoint
 		_, ok := entrypoints[ep]
 		assert.Equal(t, true, ok)
+
+		assert.NoError(t, m.Validate())
+		assert.NoError(t, m.ValidateArgs(m.ApplyDefaults(nil)))
 	}
 }
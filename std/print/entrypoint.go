@@ -10,10 +10,18 @@ import (
 	"github.com/cofunclabs/cofunc/manifest"
 )
 
+var statusRet = manifest.UsageDesc{
+	Name: "status",
+	Desc: "It's always 'ok'",
+}
+
 var _manifest = manifest.Manifest{
 	Name:        "print",
 	Driver:      "go",
 	Description: "Output string to stdout",
+	Usage: manifest.Usage{
+		ReturnValues: []manifest.UsageDesc{statusRet},
+	},
 }
 
 func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
@@ -34,6 +42,6 @@ func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.Ent
 		fmt.Fprintln(bundle.Resources.Logwriter, s)
 	}
 	return map[string]string{
-		"status": "ok",
+		statusRet.Name: "ok",
 	}, nil
 }
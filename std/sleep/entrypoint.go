@@ -10,6 +10,7 @@ import (
 
 var durationArg = manifest.UsageDesc{
 	Name: "duration",
+	Type: manifest.TypeDuration,
 	Desc: "Specify a duration to sleep, default 1s",
 }
 
@@ -32,8 +33,7 @@ func New() (*manifest.Manifest, spec.EntrypointFunc, spec.CreateCustomFunc) {
 }
 
 func Entrypoint(ctx context.Context, bundle spec.EntrypointBundle, args spec.EntrypointArgs) (map[string]string, error) {
-	s := args.GetString(durationArg.Name)
-	v, err := time.ParseDuration(s)
+	v, err := args.GetDuration(durationArg.Name)
 	if err != nil {
 		return nil, err
 	}
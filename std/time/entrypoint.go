@@ -24,6 +24,7 @@ var (
 	}
 	timestampArg = manifest.UsageDesc{
 		Name: "get_timestamp",
+		Type: manifest.TypeBool,
 		OptionalValues: []string{
 			"true",
 			"false",